@@ -132,6 +132,9 @@ func NewInfraStack(scope constructs.Construct, id string, props *InfraStackProps
 			builder.Arn("OPENAI_API_KEY"),
 			builder.Arn("SENDER_EMAIL"),
 			builder.Arn("SENDER_PASS"),
+			builder.Arn("JWT_SECRET"),
+			builder.Arn("VIEWER_HASH_KEY"),
+			builder.Arn("POW_SECRET"),
 		},
 	})
 	executionRole.AddToPolicy(ssmPolicyStatement)
@@ -157,6 +160,16 @@ func NewInfraStack(scope constructs.Construct, id string, props *InfraStackProps
 		Image:          awsecs.ContainerImage_FromEcrRepository(repo, jsii.String(latestMergedCommit)),
 		MemoryLimitMiB: jsii.Number(512),
 		Cpu:            jsii.Number(1),
+		HealthCheck: &awsecs.HealthCheck{
+			Command: jsii.Strings("CMD-SHELL", "wget -q -O /dev/null http://localhost:8080/healthz || exit 1"),
+		},
+		// proof of work challenges are checked against the shared store, or a solution could be replayed on
+		// another instance
+		Environment: &map[string]*string{
+			"RATE_LIMIT_STORE": jsii.String("mongo"),
+		},
+		// SHUTDOWN_DRAIN_DELAY and SHUTDOWN_TIMEOUT, with time to close the database
+		StopTimeout: awscdk.Duration_Seconds(jsii.Number(60)),
 		PortMappings: &[]*awsecs.PortMapping{
 			{
 				ContainerPort: jsii.Number(8080),
@@ -179,6 +192,16 @@ func NewInfraStack(scope constructs.Construct, id string, props *InfraStackProps
 		Protocol: elbv2.Protocol_TCP,
 		Port:     jsii.Number(443),
 		Targets:  &[]elbv2.INetworkLoadBalancerTarget{service},
+		// the service fails /readyz while it drains on shutdown, so it's taken out before it stops. That takes up to
+		// interval times the unhealthy threshold, SHUTDOWN_DRAIN_DELAY has to be at least as long.
+		HealthCheck: &elbv2.HealthCheck{
+			Protocol:                elbv2.Protocol_HTTP,
+			Path:                    jsii.String("/readyz"),
+			Interval:                awscdk.Duration_Seconds(jsii.Number(10)),
+			HealthyThresholdCount:   jsii.Number(2),
+			UnhealthyThresholdCount: jsii.Number(2),
+		},
+		DeregistrationDelay: awscdk.Duration_Seconds(jsii.Number(30)),
 	}
 	loadBalancer.AddListener(jsii.String("resume-service-http-listener"), &elbv2.BaseNetworkListenerProps{
 		Port: jsii.Number(80),
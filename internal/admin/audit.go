@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"context"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/logging"
+	"resume-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit records every request to the admin api once it's handled, including refused and failed ones.
+func Audit(auditStore database.AuditRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		event := model.AuditEvent{
+			ActorID:      auth.GetUserIdFromContext(c),
+			Action:       c.Request.Method + " " + c.FullPath(),
+			TargetUserID: c.Param("user_id"),
+			Query:        c.Request.URL.RawQuery,
+			Status:       apperror.Status(c),
+			IP:           c.ClientIP(),
+			Time:         time.Now(),
+		}
+		// the request context may be done by now
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := auditStore.StoreEvent(ctx, event); err != nil {
+			logging.FromContext(c).ErrorContext(ctx, "Cannot store audit event", "action", event.Action, "error", err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var (
+	errUserNotFound    = apperror.New(http.StatusNotFound, "user_not_found", "User not found")
+	errInvalidRole     = apperror.New(http.StatusBadRequest, "invalid_role", "Role must be one of: user, support, admin")
+	errCannotDemoteOwn = apperror.New(http.StatusBadRequest, "cannot_change_own_account", "Admins cannot change their own role or disable themselves")
+)
+
+type AdminController struct {
+	userStore    database.UserRepository
+	resumeStore  database.ResumeRepository
+	sessionStore database.SessionRepository
+	apiKeyStore  database.APIKeyRepository
+	auditStore   database.AuditRepository
+}
+
+func NewAdminController(userStore database.UserRepository, resumeStore database.ResumeRepository, sessionStore database.SessionRepository, apiKeyStore database.APIKeyRepository, auditStore database.AuditRepository) *AdminController {
+	return &AdminController{
+		userStore:    userStore,
+		resumeStore:  resumeStore,
+		sessionStore: sessionStore,
+		apiKeyStore:  apiKeyStore,
+		auditStore:   auditStore,
+	}
+}
+
+// BootstrapAdmins gives the admin role to the accounts that verified the given emails, so the first admins don't
+// need an admin. Whoever signed up first with an unverified email doesn't get it.
+func BootstrapAdmins(ctx context.Context, userStore database.UserRepository, emails []string) {
+	for _, email := range emails {
+		if email == "" {
+			continue
+		}
+		if err := userStore.SetRoleByEmail(ctx, email, auth.RoleAdmin); err != nil {
+			slog.ErrorContext(ctx, "Cannot bootstrap admin", "email", email, "error", err)
+		}
+	}
+}
+
+func (a *AdminController) ListUsers(c *gin.Context) {
+	limit, offset := pagination(c)
+
+	users, total, err := a.userStore.SearchUsers(c, c.Query("q"), limit, offset)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total})
+}
+
+func (a *AdminController) GetUser(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := a.userStore.GetUser(c, userId)
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
+	resumes, err := a.resumeStore.GetResumesByUserId(c, userId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user, "resume_count": len(resumes)})
+}
+
+func (a *AdminController) ListUserResumes(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	resumes, err := a.resumeStore.GetResumesByUserId(c, userId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"resumes": resumes})
+}
+
+func (a *AdminController) VerifyUserEmail(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	err := a.userStore.VerifyEmail(c, userId)
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"email_verified": true})
+}
+
+// DisableUser blocks the account and ends all its sessions & api keys.
+func (a *AdminController) DisableUser(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+	if userId == auth.GetUserIdFromContext(c) {
+		apperror.Abort(c, errCannotDemoteOwn)
+		return
+	}
+
+	err := a.userStore.SetDisabled(c, userId, true)
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
+	if err = a.sessionStore.RevokeUserSessions(c, userId); err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if err = a.apiKeyStore.RevokeUserAPIKeys(c, userId); err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"disabled": true})
+}
+
+func (a *AdminController) EnableUser(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	err := a.userStore.SetDisabled(c, userId, false)
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"disabled": false})
+}
+
+func (a *AdminController) SetUserRole(c *gin.Context) {
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	if !auth.IsValidRole(request.Role) {
+		apperror.Abort(c, errInvalidRole)
+		return
+	}
+
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+	if userId == auth.GetUserIdFromContext(c) {
+		apperror.Abort(c, errCannotDemoteOwn)
+		return
+	}
+
+	err := a.userStore.SetRole(c, userId, request.Role)
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": request.Role})
+}
+
+func (a *AdminController) Usage(c *gin.Context) {
+	users, verifiedUsers, err := a.userStore.CountUsers(c)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	resumes, temporaryResumes, err := a.resumeStore.CountResumes(c)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	sessions, err := a.sessionStore.CountActiveSessions(c)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":             users,
+		"verified_users":    verifiedUsers,
+		"resumes":           resumes,
+		"temporary_resumes": temporaryResumes,
+		"active_sessions":   sessions,
+	})
+}
+
+func (a *AdminController) ListAuditEvents(c *gin.Context) {
+	limit, offset := pagination(c)
+
+	events, err := a.auditStore.GetEvents(c, c.Query("user_id"), limit, offset)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+func pagination(c *gin.Context) (int64, int64) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)), 10, 64)
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func userIdParam(c *gin.Context) (primitive.ObjectID, bool) {
+	userId, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		apperror.Abort(c, errUserNotFound)
+		return primitive.NilObjectID, false
+	}
+	return userId, true
+}
+
+func notFoundOrError(c *gin.Context, err error) {
+	if database.IsNotFound(err) {
+		apperror.Abort(c, errUserNotFound)
+		return
+	}
+	apperror.Abort(c, apperror.Internal(err))
+}
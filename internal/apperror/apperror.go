@@ -0,0 +1,84 @@
+// Package apperror holds the errors handlers respond with. Each has a stable code clients can switch on, the
+// http status it maps to and a message that's safe to show. The cause, like a database or aws error, is only
+// logged.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	cause   error
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.cause)
+	}
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors by code, so errors.Is keeps working after Wrap or WithMessage.
+func (e *Error) Is(target error) bool {
+	var other *Error
+	return errors.As(target, &other) && other.Code == e.Code
+}
+
+// Wrap returns a copy of the error with the internal cause attached.
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// WithMessage returns a copy of the error with a more specific message.
+func (e *Error) WithMessage(format string, args ...interface{}) *Error {
+	changed := *e
+	changed.Message = fmt.Sprintf(format, args...)
+	return &changed
+}
+
+var (
+	ErrBadRequest   = New(http.StatusBadRequest, "bad_request", "The request is invalid")
+	ErrUnauthorized = New(http.StatusUnauthorized, "unauthorized", "You need to log in")
+	ErrForbidden    = New(http.StatusForbidden, "forbidden", "You are not allowed to do this")
+	ErrNotFound     = New(http.StatusNotFound, "not_found", "Not found")
+	ErrConflict     = New(http.StatusConflict, "conflict", "The request conflicts with the current state")
+	ErrRateLimited  = New(http.StatusTooManyRequests, "rate_limited", "Too many requests, try again later")
+	ErrInternal     = New(http.StatusInternalServerError, "internal_error", "Something went wrong, try again later")
+	ErrUpstream     = New(http.StatusBadGateway, "upstream_error", "A service we depend on failed, try again later")
+	ErrUnavailable  = New(http.StatusServiceUnavailable, "unavailable", "Service unavailable, try again later")
+)
+
+// Internal wraps an unexpected error, so its details are logged but never shown.
+func Internal(cause error) *Error {
+	return ErrInternal.Wrap(cause)
+}
+
+// InvalidRequest is for bodies or params that fail to bind. The binding error only names the fields that are
+// wrong, so it's safe to show.
+func InvalidRequest(cause error) *Error {
+	return ErrBadRequest.WithMessage("%v", cause).Wrap(cause)
+}
+
+// From returns err as an *Error, anything that isn't one becomes an internal error.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
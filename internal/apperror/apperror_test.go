@@ -0,0 +1,70 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errTest = New(http.StatusConflict, "test_conflict", "Already done")
+
+func TestIsMatchesWrappedErrors(t *testing.T) {
+	cause := errors.New("E11000 duplicate key")
+	err := errTest.Wrap(cause)
+
+	if !errors.Is(err, errTest) {
+		t.Errorf("Expected %v to match %v", err, errTest)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("Expected %v to unwrap to its cause", err)
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Errorf("Expected errors with different codes not to match")
+	}
+	if errTest.Unwrap() != nil {
+		t.Errorf("Expected Wrap not to change the original error")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Recovery(), Middleware())
+	r.GET("/app", func(c *gin.Context) { Abort(c, errTest.Wrap(errors.New("secret detail"))) })
+	r.GET("/plain", func(c *gin.Context) { Abort(c, errors.New("connection refused to 10.0.0.5")) })
+	r.GET("/written", func(c *gin.Context) {
+		_ = c.Error(errTest)
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.NoRoute(NoRoute)
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/app", http.StatusConflict, "test_conflict"},
+		{"/plain", http.StatusInternalServerError, "internal_error"},
+		{"/written", http.StatusOK, ""},
+		{"/panic", http.StatusInternalServerError, "internal_error"},
+		{"/missing", http.StatusNotFound, "not_found"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		var body Response
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != test.status || body.Code != test.code {
+			t.Errorf("%s: expected %d %q, got %d %s", test.path, test.status, test.code, w.Code, w.Body.String())
+		}
+		if test.code == "internal_error" && body.Message != ErrInternal.Message {
+			t.Errorf("%s: expected the cause to stay hidden, got %q", test.path, body.Message)
+		}
+	}
+}
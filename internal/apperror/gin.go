@@ -0,0 +1,59 @@
+package apperror
+
+import (
+	"net/http"
+	"resume-service/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response is the body of every error response.
+type Response struct {
+	Code    string `json:"error_code"`
+	Message string `json:"error"`
+}
+
+// Abort stops the handler chain, Middleware writes the response for err.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Middleware responds with the last error handlers passed to Abort, unless they already wrote a response.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		appErr := From(c.Errors.Last().Err)
+		if appErr.Status >= http.StatusInternalServerError {
+			logging.FromContext(c).ErrorContext(c, "Request failed", "error_code", appErr.Code, "error", appErr)
+		} else if appErr.cause != nil {
+			logging.FromContext(c).WarnContext(c, "Request refused", "error_code", appErr.Code, "error", appErr)
+		}
+		c.JSON(appErr.Status, Response{Code: appErr.Code, Message: appErr.Message})
+	}
+}
+
+// Status is the status the response has or, for middleware that runs before Middleware writes it, will have.
+func Status(c *gin.Context) int {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return c.Writer.Status()
+	}
+	return From(c.Errors.Last().Err).Status
+}
+
+// Recovery turns panics into internal errors with the same body as any other error.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		logging.FromContext(c).ErrorContext(c, "Request panicked", "panic", recovered)
+		c.AbortWithStatusJSON(ErrInternal.Status, Response{Code: ErrInternal.Code, Message: ErrInternal.Message})
+	})
+}
+
+// NoRoute answers unknown routes with a not_found error.
+func NoRoute(c *gin.Context) {
+	Abort(c, ErrNotFound)
+}
@@ -0,0 +1,33 @@
+package auth
+
+import "testing"
+
+func TestHasScope(t *testing.T) {
+	cases := []struct {
+		granted  []string
+		scope    string
+		expected bool
+	}{
+		{[]string{ScopeRead}, ScopeRead, true},
+		{[]string{ScopeRead}, ScopeResumes, false},
+		{[]string{ScopeResumes}, ScopeRead, true},
+		{[]string{ScopeGeneration}, ScopeRead, false},
+		{[]string{ScopeRead, ScopeGeneration}, ScopeGeneration, true},
+		{nil, ScopeRead, false},
+	}
+	for _, tc := range cases {
+		if hasScope(tc.granted, tc.scope) != tc.expected {
+			t.Errorf("hasScope(%v, %s) should be %v", tc.granted, tc.scope, tc.expected)
+		}
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	if !isAPIKey(key) || key[:len(prefix)] != prefix || hash != HashToken(key) {
+		t.Errorf("Unexpected key %s, prefix %s", key, prefix)
+	}
+}
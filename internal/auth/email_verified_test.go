@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/model"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEmailVerified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewUserStore()
+	ctx := context.Background()
+	verified, _ := store.CreateUser(ctx, model.User{Email: "verified@example.com", EmailVerified: true})
+	unverified, _ := store.CreateUser(ctx, model.User{Email: "unverified@example.com"})
+	disabled, _ := store.CreateUser(ctx, model.User{Email: "disabled@example.com", EmailVerified: true, Disabled: true})
+
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	r.GET("/resumes", func(c *gin.Context) { c.Set("userID", c.GetHeader("X-Test-User")) }, EmailVerified(store), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for userId, expected := range map[primitive.ObjectID]int{
+		verified.ID:             http.StatusOK,
+		unverified.ID:           ErrEmailNotVerified.Status,
+		disabled.ID:             ErrAccountDisabled.Status,
+		primitive.NewObjectID(): ErrEmailNotVerified.Status,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/resumes", nil)
+		req.Header.Set("X-Test-User", userId.Hex())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Errorf("Expected %d for user %s, got %d", expected, userId.Hex(), w.Code)
+		}
+	}
+}
@@ -1,17 +1,36 @@
 package auth
 
 import (
-	"github.com/golang-jwt/jwt"
-	"resume-service/internal/model"
+	"fmt"
+	"net/http"
+	"resume-service/internal/apperror"
 	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	accessTokenTTL    = 15 * time.Minute
+	challengeTokenTTL = 5 * time.Minute
+
+	twoFactorPurpose = "2fa"
 )
 
-const jwtSecret = "your_jwt_secret"
+// jwtSecret signs access & challenge tokens, main sets it from the config with SetJWTSecret.
+var jwtSecret string
 
-func GenerateJWTToken(user model.User) (string, error) {
+func SetJWTSecret(secret string) {
+	jwtSecret = secret
+}
+
+var ErrInvalidChallengeToken = apperror.New(http.StatusUnauthorized, "invalid_challenge", "Login challenge is invalid or expired, log in again")
+
+func GenerateJWTToken(userId primitive.ObjectID, sessionId primitive.ObjectID) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID": user.ID.Hex(),
-		"exp":    time.Now().Add(24 * time.Hour).Unix(),
+		"userID": userId.Hex(),
+		"sid":    sessionId.Hex(),
+		"exp":    time.Now().Add(accessTokenTTL).Unix(),
 	})
 
 	signedToken, err := token.SignedString([]byte(jwtSecret))
@@ -21,3 +40,39 @@ func GenerateJWTToken(user model.User) (string, error) {
 
 	return signedToken, nil
 }
+
+// GenerateChallengeToken proves the password step of a two factor login. It has no session, so the
+// auth middleware doesn't accept it.
+func GenerateChallengeToken(userId primitive.ObjectID) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID":  userId.Hex(),
+		"purpose": twoFactorPurpose,
+		"exp":     time.Now().Add(challengeTokenTTL).Unix(),
+	})
+
+	return token.SignedString([]byte(jwtSecret))
+}
+
+func ParseChallengeToken(tokenString string) (primitive.ObjectID, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return primitive.NilObjectID, ErrInvalidChallengeToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != twoFactorPurpose {
+		return primitive.NilObjectID, ErrInvalidChallengeToken
+	}
+
+	userIdStr, _ := claims["userID"].(string)
+	userId, err := primitive.ObjectIDFromHex(userIdStr)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidChallengeToken
+	}
+	return userId, nil
+}
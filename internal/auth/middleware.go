@@ -3,10 +3,14 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/database"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
@@ -14,16 +18,39 @@ const (
 	bearerTokenPrefix   = "Bearer "
 )
 
-func Middleware() gin.HandlerFunc {
+var (
+	ErrInvalidLogin      = apperror.New(http.StatusUnauthorized, "invalid_login", "You need to log in")
+	ErrInvalidAPIKey     = apperror.New(http.StatusUnauthorized, "invalid_api_key", "API key is invalid or revoked")
+	ErrSessionRevoked    = apperror.New(http.StatusUnauthorized, "session_revoked", "Your session has ended, log in again")
+	ErrEmailNotVerified  = apperror.New(http.StatusUnauthorized, "email_not_verified", "Verify your email first")
+	ErrAccountDisabled   = apperror.New(http.StatusForbidden, "account_disabled", "This account has been disabled")
+	ErrInsufficientScope = apperror.New(http.StatusForbidden, "insufficient_scope", "This API key is not allowed to do this")
+	ErrSessionRequired   = apperror.New(http.StatusForbidden, "session_required", "API keys cannot be used for this, log in instead")
+)
+
+// Middleware accepts either a session access token or an API key as bearer token, of a user that isn't disabled.
+func Middleware(users database.UserRepository, sessions database.SessionRepository, apiKeys database.APIKeyRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader(authorizationHeader)
 
 		if authHeader == "" || !strings.HasPrefix(authHeader, bearerTokenPrefix) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error_code": "invalid_login"})
+			apperror.Abort(c, ErrInvalidLogin)
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, bearerTokenPrefix)
+		if isAPIKey(tokenString) {
+			if !authenticateAPIKey(c, apiKeys, tokenString) {
+				apperror.Abort(c, ErrInvalidAPIKey)
+				return
+			}
+			if !userEnabled(c, users, ErrInvalidAPIKey) {
+				return
+			}
+			c.Next()
+			return
+		}
+
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			// Validate signing algorithm
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -33,17 +60,54 @@ func Middleware() gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error_code": "invalid_login"})
+			apperror.Abort(c, ErrInvalidLogin)
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error_code": "invalid_login"})
+			apperror.Abort(c, ErrInvalidLogin)
+			return
+		}
+
+		sessionIdStr, _ := claims["sid"].(string)
+		sessionId, err := primitive.ObjectIDFromHex(sessionIdStr)
+		if err != nil {
+			apperror.Abort(c, ErrInvalidLogin)
+			return
+		}
+
+		session, err := sessions.GetSession(c, sessionId)
+		if err != nil || session.Revoked || time.Now().After(session.ExpiresAt) || session.UserID.Hex() != claims["userID"] {
+			apperror.Abort(c, ErrSessionRevoked)
 			return
 		}
 
 		c.Set("userID", claims["userID"])
+		c.Set("sessionID", sessionIdStr)
+		if !userEnabled(c, users, ErrSessionRevoked) {
+			return
+		}
 		c.Next()
 	}
 }
+
+// userEnabled aborts with ErrAccountDisabled for disabled users, and with notFound for deleted ones. The role is
+// kept for RequirePermission.
+func userEnabled(c *gin.Context, users database.UserRepository, notFound error) bool {
+	user, err := users.GetUser(c, GetUserIdFromContext(c))
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, notFound)
+		} else {
+			apperror.Abort(c, apperror.Internal(err))
+		}
+		return false
+	}
+	if user.Disabled {
+		apperror.Abort(c, ErrAccountDisabled)
+		return false
+	}
+	c.Set(userRoleKey, user.Role)
+	return true
+}
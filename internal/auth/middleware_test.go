@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareRefusesDisabledUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetJWTSecret("test-secret")
+	users, sessions, apiKeys := memory.NewUserStore(), memory.NewSessionStore(), memory.NewAPIKeyStore()
+	ctx := context.Background()
+
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	r.GET("/resumes", Middleware(users, sessions, apiKeys), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// credentials returns an access token and an api key of a new user.
+	credentials := func(t *testing.T, email string, disabled bool) []string {
+		t.Helper()
+		user, err := users.CreateUser(ctx, model.User{Email: email, EmailVerified: true, Disabled: disabled})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := IssueTokens(ctx, sessions, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, prefix, hash, err := GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = apiKeys.CreateAPIKey(ctx, model.APIKey{UserID: user.ID, Prefix: prefix, KeyHash: hash, Scopes: []string{ScopeRead}, CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []string{tokens.AccessToken, key}
+	}
+
+	cases := map[string]struct {
+		credentials []string
+		expected    int
+	}{
+		"enabled":  {credentials(t, "enabled@example.com", false), http.StatusOK},
+		"disabled": {credentials(t, "disabled@example.com", true), ErrAccountDisabled.Status},
+	}
+	for name, tc := range cases {
+		for _, token := range tc.credentials {
+			req := httptest.NewRequest(http.MethodGet, "/resumes", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.expected {
+				t.Errorf("Expected %d for the %s user with %.8s..., got %d", tc.expected, name, token, w.Code)
+			}
+		}
+	}
+}
+
+func TestMiddlewareRecordsAPIKeyUse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users, apiKeys := memory.NewUserStore(), memory.NewAPIKeyStore()
+	ctx := context.Background()
+	user, _ := users.CreateUser(ctx, model.User{Email: "jane@example.com", EmailVerified: true})
+
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	r.GET("/resumes", Middleware(users, memory.NewSessionStore(), apiKeys), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// use authenticates with a key last used at lastUsedAt, and returns when it was last used after that.
+	use := func(lastUsedAt time.Time) time.Time {
+		key, prefix, hash, _ := GenerateAPIKey()
+		_, _ = apiKeys.CreateAPIKey(ctx, model.APIKey{UserID: user.ID, Prefix: prefix, KeyHash: hash, Scopes: []string{ScopeRead}, LastUsedAt: lastUsedAt})
+		req := httptest.NewRequest(http.MethodGet, "/resumes", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		stored, _ := apiKeys.GetActiveAPIKeyByHash(ctx, hash)
+		return stored.LastUsedAt
+	}
+
+	if used := use(time.Time{}); used.IsZero() {
+		t.Error("Expected the first use to be recorded")
+	}
+	recently := time.Now().Add(-30 * time.Second)
+	if used := use(recently); !used.Equal(recently) {
+		t.Errorf("Expected the last use to be kept within the interval, got %v", used)
+	}
+	if used := use(time.Now().Add(-time.Hour)); time.Since(used) > time.Minute {
+		t.Errorf("Expected the use to be recorded after the interval, got %v", used)
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"resume-service/internal/apperror"
+	"resume-service/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+
+	PermissionReadUsers   = "users:read"
+	PermissionManageUsers = "users:manage"
+	PermissionReadResumes = "resumes:read"
+	PermissionReadUsage   = "usage:read"
+	PermissionManageRoles = "roles:manage"
+	PermissionReadAudit   = "audit:read"
+
+	userRoleKey = "userRole"
+)
+
+var rolePermissions = map[string][]string{
+	RoleSupport: {PermissionReadUsers, PermissionReadResumes, PermissionReadUsage},
+	RoleAdmin: {
+		PermissionReadUsers, PermissionManageUsers, PermissionReadResumes,
+		PermissionReadUsage, PermissionManageRoles, PermissionReadAudit,
+	},
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleSupport || role == RoleAdmin
+}
+
+func HasPermission(role string, permission string) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission only lets users whose role has the permission through.
+// The role is looked up once per request, so these can be stacked.
+func RequirePermission(userStore database.UserRepository, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := c.Get(userRoleKey)
+		if !ok {
+			user, err := userStore.GetUser(c, GetUserIdFromContext(c))
+			if err != nil || user.Disabled {
+				apperror.Abort(c, apperror.ErrForbidden)
+				return
+			}
+			role = user.Role
+			c.Set(userRoleKey, role)
+		}
+
+		if !HasPermission(role.(string), permission) {
+			apperror.Abort(c, apperror.ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
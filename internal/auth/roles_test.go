@@ -0,0 +1,17 @@
+package auth
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	if !HasPermission(RoleAdmin, PermissionManageUsers) || !HasPermission(RoleSupport, PermissionReadUsers) {
+		t.Errorf("Expected admin & support to have their permissions")
+	}
+	if HasPermission(RoleSupport, PermissionManageUsers) || HasPermission(RoleSupport, PermissionManageRoles) {
+		t.Errorf("Expected support to only have read permissions")
+	}
+	for _, role := range []string{RoleUser, ""} {
+		if HasPermission(role, PermissionReadUsers) {
+			t.Errorf("Expected role %q to have no admin permissions", role)
+		}
+	}
+}
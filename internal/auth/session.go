@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = apperror.New(http.StatusUnauthorized, "invalid_refresh_token", "Invalid refresh token")
+	ErrRefreshTokenReused  = apperror.New(http.StatusUnauthorized, "refresh_token_reused", "Refresh token already used, session revoked")
+)
+
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// IssueTokens starts a new session for the user and returns its first access / refresh token pair.
+func IssueTokens(ctx context.Context, sessions database.SessionRepository, userId primitive.ObjectID) (Tokens, error) {
+	secret, err := GenerateSecureToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	now := time.Now()
+	session, err := sessions.CreateSession(ctx, model.Session{
+		UserID:           userId,
+		RefreshTokenHash: HashToken(secret),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return buildTokens(session, secret)
+}
+
+// RefreshTokens rotates the refresh token of a session. Presenting any refresh token the session already rotated
+// away from means it leaked (or was replayed), so the whole session is revoked. Any other wrong token is only
+// refused, otherwise anyone who knows a session id could log its user out.
+func RefreshTokens(ctx context.Context, sessions database.SessionRepository, refreshToken string) (Tokens, error) {
+	sessionId, secret, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	session, err := sessions.GetSession(ctx, sessionId)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return Tokens{}, ErrInvalidRefreshToken
+		}
+		return Tokens{}, err
+	}
+
+	if session.Revoked || time.Now().After(session.ExpiresAt) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	hash := HashToken(secret)
+	if !tokenHashEqual(hash, session.RefreshTokenHash) {
+		for _, used := range session.UsedRefreshTokenHashes {
+			if tokenHashEqual(hash, used) {
+				return Tokens{}, revokeReusedSession(ctx, sessions, session.ID)
+			}
+		}
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	newSecret, err := GenerateSecureToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	session.RefreshTokenHash = HashToken(newSecret)
+	session.ExpiresAt = time.Now().Add(refreshTokenTTL)
+	err = sessions.RotateRefreshToken(ctx, session.ID, hash, session.RefreshTokenHash, session.ExpiresAt)
+	if err != nil {
+		if database.IsNotFound(err) {
+			// lost a race against another refresh using the same token
+			return Tokens{}, revokeReusedSession(ctx, sessions, session.ID)
+		}
+		return Tokens{}, err
+	}
+
+	return buildTokens(session, newSecret)
+}
+
+// GenerateSecureToken returns a random url-safe token with 256 bits of entropy.
+func GenerateSecureToken() (string, error) {
+	buffer := make([]byte, 32)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken hashes high entropy tokens before they are stored, so a database leak doesn't leak credentials.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func tokenHashEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func revokeReusedSession(ctx context.Context, sessions database.SessionRepository, sessionId primitive.ObjectID) error {
+	err := sessions.RevokeSession(ctx, sessionId)
+	if err != nil {
+		return errors.Join(ErrRefreshTokenReused, err)
+	}
+	return ErrRefreshTokenReused
+}
+
+func buildTokens(session model.Session, secret string) (Tokens, error) {
+	accessToken, err := GenerateJWTToken(session.UserID, session.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: session.ID.Hex() + "." + secret,
+	}, nil
+}
+
+func parseRefreshToken(refreshToken string) (primitive.ObjectID, string, bool) {
+	sessionHex, secret, found := strings.Cut(refreshToken, ".")
+	if !found || secret == "" {
+		return primitive.NilObjectID, "", false
+	}
+	sessionId, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		return primitive.NilObjectID, "", false
+	}
+	return sessionId, secret, true
+}
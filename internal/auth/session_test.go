@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseRefreshToken(t *testing.T) {
+	sessionId := primitive.NewObjectID()
+	secret, err := GenerateSecureToken()
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+
+	parsedId, parsedSecret, ok := parseRefreshToken(sessionId.Hex() + "." + secret)
+	if !ok || parsedId != sessionId || parsedSecret != secret {
+		t.Errorf("Refresh token not parsed back: %v %s %v", parsedId, parsedSecret, ok)
+	}
+
+	for _, token := range []string{"", "no-separator", "not-an-id." + secret, sessionId.Hex() + "."} {
+		if _, _, ok := parseRefreshToken(token); ok {
+			t.Errorf("Expected %q to be rejected", token)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// test vectors from RFC 6238, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := TOTPCode(secret, unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("Error generating code: %v", err)
+		}
+		if code != expected {
+			t.Errorf("At %d expected %s, got %s", unix, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Error generating secret: %v", err)
+	}
+	now := time.Now()
+	step := now.Unix() / totpPeriod
+
+	previous, _ := TOTPCode(secret, step-1)
+	if matched, ok := ValidateTOTP(secret, previous, now); !ok || matched != step-1 {
+		t.Errorf("Expected code of the previous step to be accepted")
+	}
+
+	stale, _ := TOTPCode(secret, step-2)
+	if _, ok := ValidateTOTP(secret, stale, now); ok {
+		t.Errorf("Expected code from two steps ago to be refused")
+	}
+}
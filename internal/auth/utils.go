@@ -10,3 +10,9 @@ func GetUserIdFromContext(c *gin.Context) primitive.ObjectID {
 	userId, _ := primitive.ObjectIDFromHex(userIdStr)
 	return userId
 }
+
+func GetSessionIdFromContext(c *gin.Context) primitive.ObjectID {
+	sessionIdStr := c.MustGet("sessionID").(string)
+	sessionId, _ := primitive.ObjectIDFromHex(sessionIdStr)
+	return sessionId
+}
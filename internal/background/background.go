@@ -0,0 +1,72 @@
+// Package background tracks goroutines that outlive the request that started them, like sending emails or
+// building exports, so the service can wait for them before it exits.
+package background
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+type Jobs struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func NewJobs() *Jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Jobs{ctx: ctx, cancel: cancel, stopping: make(chan struct{})}
+}
+
+// Go runs job in the background. Its context is only cancelled when Shutdown gives up waiting for it.
+// Jobs started after Shutdown are dropped.
+func (j *Jobs) Go(name string, job func(ctx context.Context)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		slog.Warn("Shutting down, dropped background job", "job", name)
+		return
+	}
+
+	j.running.Add(1)
+	go func() {
+		defer j.running.Done()
+		job(j.ctx)
+	}()
+}
+
+// Stopping is closed once Shutdown starts, so long running jobs know to return.
+func (j *Jobs) Stopping() <-chan struct{} {
+	return j.stopping
+}
+
+// Shutdown refuses new jobs and waits for the running ones. When ctx is done first, the jobs are cancelled
+// and ctx's error is returned.
+func (j *Jobs) Shutdown(ctx context.Context) error {
+	j.mu.Lock()
+	if !j.closed {
+		j.closed = true
+		close(j.stopping)
+	}
+	j.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		j.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		j.cancel()
+		return nil
+	case <-ctx.Done():
+		j.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,50 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForJobs(t *testing.T) {
+	jobs := NewJobs()
+	finished := make(chan struct{})
+	jobs.Go("slow", func(ctx context.Context) {
+		time.Sleep(20 * time.Millisecond)
+		close(finished)
+	})
+
+	if err := jobs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected shutdown to succeed, got %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("Expected shutdown to wait for the job")
+	}
+
+	jobs.Go("late", func(ctx context.Context) { t.Error("Expected jobs after shutdown to be dropped") })
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestShutdownCancelsJobsAfterTimeout(t *testing.T) {
+	jobs := NewJobs()
+	cancelled := make(chan struct{})
+	jobs.Go("loop", func(ctx context.Context) {
+		<-jobs.Stopping()
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := jobs.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected shutdown to time out, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the job to be cancelled")
+	}
+}
@@ -1,26 +1,28 @@
 package email
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"os"
+	"html"
+	"log/slog"
+	"resume-service/internal/config"
+	"resume-service/internal/logging"
+	"resume-service/internal/metrics"
+	"resume-service/internal/tracing"
+	"time"
 
 	"github.com/Shopify/gomail"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type EmailClient struct {
 	d *gomail.Dialer
 }
 
-const (
-	senderEmailKey = "SENDER_EMAIL"
-	senderPassKey  = "SENDER_PASS"
-)
-
-func NewClient() (*EmailClient, error) {
-	email, password := os.Getenv(senderEmailKey), os.Getenv(senderPassKey)
-	d := gomail.NewDialer("smtp.gmail.com", 587, email, password)
+func NewClient(config config.Email) (*EmailClient, error) {
+	d := gomail.NewDialer(config.Host, config.Port, config.Username, config.Password.Value())
 	_, err := d.Dial()
 	if err != nil {
 		return nil, errors.Join(errors.New("Unable to setup email-client, check your email / password"), err)
@@ -29,12 +31,82 @@ func NewClient() (*EmailClient, error) {
 	return &EmailClient{d: d}, nil
 }
 
-func (c *EmailClient) SendMail(to string, otp string) error {
+// Ping logs in to the mail server, gomail can't take a context so it's only used for the timeout.
+func (c *EmailClient) Ping(ctx context.Context) error {
+	result := make(chan error, 1)
+	go func() {
+		sender, err := c.d.Dial()
+		if err == nil {
+			err = sender.Close()
+		}
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *EmailClient) SendMail(ctx context.Context, to string, otp string) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", c.d.Username)
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", "Welcome to resume-service!")
 	m.SetBody("text/html", fmt.Sprintf("<h1>Welcome to resume service</h1><br/><p>Your OTP is %s</p>", otp))
 
-	return c.d.DialAndSend(m)
+	return c.send(ctx, "welcome", to, m)
+}
+
+func (c *EmailClient) SendPasswordResetMail(ctx context.Context, to string, resetLink string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", c.d.Username)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", "Reset your resume-service password")
+	m.SetBody("text/html", fmt.Sprintf("<h1>Password reset</h1><br/><p>Use <a href=\"%s\">this link</a> to reset your password. It expires in 30 minutes.</p><p>If you didn't ask for a reset, you can ignore this email.</p>", resetLink))
+
+	return c.send(ctx, "password_reset", to, m)
+}
+
+func (c *EmailClient) SendEmailChangeMail(ctx context.Context, to string, otp string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", c.d.Username)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", "Confirm your new resume-service email")
+	m.SetBody("text/html", fmt.Sprintf("<h1>Confirm your new email</h1><br/><p>Your OTP is %s</p>", otp))
+
+	return c.send(ctx, "email_change", to, m)
+}
+
+func (c *EmailClient) SendShareLinkViewedMail(ctx context.Context, to string, fileName string, linkPrefix string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", c.d.Username)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", "Your resume was opened")
+	m.SetBody("text/html", fmt.Sprintf("<h1>Your resume was opened</h1><br/><p>Someone opened %s through your share link %s&hellip; for the first time.</p>",
+		html.EscapeString(fileName), html.EscapeString(linkPrefix)))
+
+	return c.send(ctx, "share_link_viewed", to, m)
+}
+
+// send delivers m and records the outcome, with the recipient masked and without the body, which holds codes and links.
+func (c *EmailClient) send(ctx context.Context, kind string, to string, m *gomail.Message) error {
+	_, span := tracing.Start(ctx, "email send", attribute.String("email.kind", kind))
+	start := time.Now()
+	err := c.d.DialAndSend(m)
+	tracing.End(span, err)
+	metrics.EmailsSent.WithLabelValues(kind, metrics.Outcome(err)).Inc()
+	attrs := []any{
+		slog.String("kind", kind),
+		slog.String("to", logging.MaskEmail(to)),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	}
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "Cannot send email", append(attrs, slog.Any("error", err))...)
+		return err
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "Sent email", attrs...)
+	return nil
 }
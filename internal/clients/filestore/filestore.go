@@ -2,49 +2,127 @@ package filestore
 
 import (
 	"bytes"
-	"github.com/aws/jsii-runtime-go"
+	"context"
 	"io"
-	"os"
-	"resume-service/internal/utils"
+	"log/slog"
+	"resume-service/internal/config"
+	"resume-service/internal/logging"
+	"resume-service/internal/metrics"
+	"resume-service/internal/tracing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/jsii-runtime-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const bucket = "resume-service-filestore"
-
 type FileStore struct {
-	s3 *s3.S3
+	s3     *s3.S3
+	bucket string
 }
 
-func NewStorageClient() *FileStore {
-	region := os.Getenv(utils.KEY_REGION)
+func NewStorageClient(config config.Storage) *FileStore {
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		Config: aws.Config{Region: jsii.String(region)},
+		Config: aws.Config{Region: jsii.String(config.Region)},
 	}))
-	return &FileStore{s3: s3.New(sess)}
+	return &FileStore{s3: s3.New(sess), bucket: config.Bucket}
 }
 
-func (s *FileStore) Upload(key string, fileContent []byte) error {
+func (s *FileStore) Upload(ctx context.Context, key string, fileContent []byte) error {
 	input := &s3.PutObjectInput{
 		Body:   bytes.NewReader(fileContent),
-		Bucket: aws.String(bucket),
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	}
 
-	_, err := s.s3.PutObject(input)
+	ctx, op := startOperation(ctx, "upload", key)
+	_, err := s.s3.PutObjectWithContext(ctx, input)
+	op.finish(ctx, err, len(fileContent))
 	return err
 }
 
-func (s *FileStore) Download(key string) ([]byte, error) {
-	result, err := s.s3.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(bucket),
+func (s *FileStore) Download(ctx context.Context, key string) ([]byte, error) {
+	ctx, op := startOperation(ctx, "download", key)
+	result, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		op.finish(ctx, err, 0)
 		return nil, err
 	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(result.Body)
+	op.finish(ctx, err, len(content))
+	return content, err
+}
+
+// Ping checks the bucket exists and is accessible.
+func (s *FileStore) Ping(ctx context.Context) error {
+	_, err := s.s3.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
 
-	return io.ReadAll(result.Body)
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	ctx, op := startOperation(ctx, "delete", key)
+	_, err := s.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	op.finish(ctx, err, 0)
+	return err
+}
+
+// PresignedDownloadURL lets anyone holding the url download the file until it expires.
+func (s *FileStore) PresignedDownloadURL(ctx context.Context, key string, fileName string, expiry time.Duration) (string, error) {
+	req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String("attachment; filename=" + fileName),
+	})
+	url, err := req.Presign(expiry)
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "Cannot presign download", "key", key, "error", err)
+	}
+	return url, err
+}
+
+// operation is an S3 call in progress.
+type operation struct {
+	name  string
+	key   string
+	start time.Time
+	span  trace.Span
+}
+
+func startOperation(ctx context.Context, name string, key string) (context.Context, *operation) {
+	ctx, span := tracing.Start(ctx, "s3 "+name, attribute.String("s3.key", key))
+	return ctx, &operation{name: name, key: key, start: time.Now(), span: span}
+}
+
+// finish ends the span, records the metrics and logs the operation at debug, or at error when it failed.
+// bytes is the amount transferred, only counted when it succeeded.
+func (o *operation) finish(ctx context.Context, err error, bytes int) {
+	metrics.StorageDuration.WithLabelValues(o.name, metrics.Outcome(err)).Observe(metrics.Since(o.start))
+	attrs := []any{
+		slog.String("operation", o.name),
+		slog.String("key", o.key),
+		slog.Int64("duration_ms", time.Since(o.start).Milliseconds()),
+	}
+	if bytes > 0 && err == nil {
+		metrics.StorageBytes.WithLabelValues(o.name).Add(float64(bytes))
+		o.span.SetAttributes(attribute.Int("s3.bytes", bytes))
+		attrs = append(attrs, slog.Int("bytes", bytes))
+	}
+	tracing.End(o.span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "s3 operation failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+	logging.FromContext(ctx).DebugContext(ctx, "s3 operation", attrs...)
 }
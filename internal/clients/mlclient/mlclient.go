@@ -3,37 +3,69 @@ package mlclient
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"resume-service/internal/config"
+	"resume-service/internal/logging"
+	"resume-service/internal/metrics"
+	"resume-service/internal/tracing"
+	"time"
+
 	"github.com/sashabaranov/go-openai"
-	"os"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type MLClient struct {
 	openAiClient *openai.Client
+	model        string
 }
 
-func NewMLClient() *MLClient {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+func NewMLClient(config config.OpenAI) *MLClient {
+	if config.APIKey == "" {
 		return nil
 	}
 
-	client := openai.NewClient(apiKey)
-	return &MLClient{openAiClient: client}
+	client := openai.NewClient(config.APIKey.Value())
+	return &MLClient{openAiClient: client, model: config.Model}
 }
 
 func (c *MLClient) GenerateCoverLetter(ctx context.Context, jobDesc, resumeText string) (string, error) {
+	ctx, span := tracing.Start(ctx, "llm generate cover letter", attribute.String("llm.model", c.model))
 	messages := createCoverletterGeneratorPrompt(jobDesc, resumeText)
+	start := time.Now()
 	response, err := c.openAiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:       openai.GPT3Dot5Turbo,
+		Model:       c.model,
 		Messages:    messages,
 		MaxTokens:   2000,
 		Temperature: 0.2,
 		Stream:      false,
 		Stop:        []string{"\n."},
 	})
+	metrics.LLMDuration.WithLabelValues(c.model, metrics.Outcome(err)).Observe(metrics.Since(start))
+	logger := logging.FromContext(ctx).With(
+		slog.String("model", c.model),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	)
 	if err != nil {
+		metrics.LLMErrors.WithLabelValues(c.model).Inc()
+		logger.ErrorContext(ctx, "Cannot generate cover letter", "error", err)
+		tracing.End(span, err)
 		return "", err
 	}
+	metrics.LLMTokens.WithLabelValues(c.model, "prompt").Add(float64(response.Usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(c.model, "completion").Add(float64(response.Usage.CompletionTokens))
+	metrics.CoverLettersGenerated.WithLabelValues(c.model).Inc()
+	span.SetAttributes(
+		attribute.Int("llm.usage.prompt_tokens", response.Usage.PromptTokens),
+		attribute.Int("llm.usage.completion_tokens", response.Usage.CompletionTokens),
+	)
+	span.End()
+
+	// prompts and completions hold resume contents, only the usage is logged
+	logger.InfoContext(ctx, "Generated cover letter", slog.Group("usage",
+		slog.Int("prompt", response.Usage.PromptTokens),
+		slog.Int("completion", response.Usage.CompletionTokens),
+		slog.Int("total", response.Usage.TotalTokens),
+	))
 	return response.Choices[0].Message.Content, nil
 }
 
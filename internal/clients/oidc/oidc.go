@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Identity is what a provider tells us about the user who logged in.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow (with PKCE) against one OAuth2 / OpenID Connect provider.
+type Provider struct {
+	config     ProviderConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]*rsa.PublicKey
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+}
+
+func NewProvider(config ProviderConfig) *Provider {
+	return &Provider{config: config, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL is where the user is sent to log in. The verifier never leaves the server, only its S256 challenge.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	authEndpoint := p.config.AuthURL
+	if p.config.Issuer != "" {
+		discovery, err := p.getDiscovery(ctx)
+		if err != nil {
+			return "", err
+		}
+		authEndpoint = discovery.AuthorizationEndpoint
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if p.config.Issuer != "" {
+		query.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(authEndpoint, "?") {
+		separator = "&"
+	}
+	return authEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the verified identity of the user.
+func (p *Provider) Exchange(ctx context.Context, code, nonce, verifier string) (Identity, error) {
+	tokenEndpoint := p.config.TokenURL
+	if p.config.Issuer != "" {
+		discovery, err := p.getDiscovery(ctx)
+		if err != nil {
+			return Identity{}, err
+		}
+		tokenEndpoint = discovery.TokenEndpoint
+	}
+
+	token, err := p.exchangeCode(ctx, tokenEndpoint, code, verifier)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if p.config.Issuer == "" {
+		// plain OAuth2 providers (github) don't issue id tokens, the profile comes from their API
+		return p.fetchGithubIdentity(ctx, token.AccessToken)
+	}
+	return p.verifyIDToken(ctx, token.IDToken, nonce)
+}
+
+func (p *Provider) exchangeCode(ctx context.Context, tokenEndpoint, code, verifier string) (tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	token := tokenResponse{}
+	err = p.doJSON(req, &token)
+	return token, err
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, idToken, nonce string) (Identity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, discovery.JwksURI, kid)
+	})
+	if err != nil || !token.Valid {
+		return Identity{}, errors.Join(ErrInvalidIDToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok ||
+		!claims.VerifyIssuer(discovery.Issuer, true) ||
+		!claims.VerifyAudience(p.config.ClientID, true) ||
+		!claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Identity{}, ErrInvalidIDToken
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return Identity{}, ErrInvalidIDToken
+	}
+
+	identity := Identity{Provider: p.config.Name}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		// some providers send it as a string
+		identity.EmailVerified = verified == "true"
+	}
+	if identity.Subject == "" {
+		return Identity{}, ErrInvalidIDToken
+	}
+	return identity, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	discovery := &discoveryDocument{}
+	if err = p.doJSON(req, discovery); err != nil {
+		return nil, err
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// getKey returns the signing key with the given id, refreshing the key set once when the id is unknown
+// (providers rotate their keys).
+func (p *Provider) getKey(ctx context.Context, jwksUri, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksUri, nil)
+	if err != nil {
+		return nil, err
+	}
+	var keySet struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err = p.doJSON(req, &keySet); err != nil {
+		return nil, err
+	}
+
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, key := range keySet.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(key.N)
+		e, errE := base64.RawURLEncoding.DecodeString(key.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		p.keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %d: %s", req.Method, req.URL.Path, res.StatusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// CodeChallenge derives the PKCE S256 challenge of a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,157 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// testProvider is a local stand-in for an OpenID Connect provider.
+type testProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// authorization requests by code: the PKCE challenge & nonce they were made with
+	challenges map[string]string
+	nonces     map[string]string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	p := &testProvider{key: key, challenges: map[string]string{}, nonces: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.FormValue("code")
+		if challenge, ok := p.challenges[code]; !ok || challenge != CodeChallenge(r.FormValue("code_verifier")) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            p.server.URL,
+			"aud":            r.FormValue("client_id"),
+			"sub":            "subject-1",
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"name":           "Jane",
+			"nonce":          p.nonces[code],
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		idToken.Header["kid"] = "test-key"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": signed})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize stands in for the user logging in at the provider, returning the code it redirects back with.
+func (p *testProvider) authorize(t *testing.T, authUrl string) string {
+	parsed, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatalf("Invalid auth url: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("Auth url is missing PKCE: %s", authUrl)
+	}
+	code := "code-" + query.Get("state")
+	p.challenges[code] = query.Get("code_challenge")
+	p.nonces[code] = query.Get("nonce")
+	return code
+}
+
+func TestExchange(t *testing.T) {
+	testProvider := newTestProvider(t)
+	provider := NewProvider(ProviderConfig{
+		Name:        "test",
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+		Scopes:      []string{"openid", "email"},
+		Issuer:      testProvider.server.URL,
+	})
+	ctx := context.Background()
+
+	authUrl, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("Error building auth url: %v", err)
+	}
+	code := testProvider.authorize(t, authUrl)
+
+	identity, err := provider.Exchange(ctx, code, "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("Error exchanging code: %v", err)
+	}
+	expected := Identity{Provider: "test", Subject: "subject-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+	if identity != expected {
+		t.Errorf("Expected %+v, got %+v", expected, identity)
+	}
+
+	if _, err = provider.Exchange(ctx, code, "nonce", "wrong-verifier"); err == nil {
+		t.Errorf("Expected exchange with the wrong PKCE verifier to fail")
+	}
+
+	if _, err = provider.Exchange(ctx, code, "other-nonce", "verifier"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Expected %v for a replayed id token, got %v", ErrInvalidIDToken, err)
+	}
+}
+
+func TestExchangeRejectsOtherAudience(t *testing.T) {
+	testProvider := newTestProvider(t)
+	config := ProviderConfig{Name: "test", ClientID: "client", Issuer: testProvider.server.URL}
+	provider := NewProvider(config)
+	ctx := context.Background()
+
+	authUrl, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("Error building auth url: %v", err)
+	}
+	code := testProvider.authorize(t, authUrl)
+
+	// the provider issues the id token for another client, which must not be accepted here
+	idTokenProvider := NewProvider(ProviderConfig{Name: "test", ClientID: "other-client", Issuer: testProvider.server.URL})
+	token, err := idTokenProvider.exchangeCode(ctx, testProvider.server.URL+"/token", code, "verifier")
+	if err != nil {
+		t.Fatalf("Error exchanging code: %v", err)
+	}
+	if _, err = provider.verifyIDToken(ctx, token.IDToken, "nonce"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Expected %v, got %v", ErrInvalidIDToken, err)
+	}
+}
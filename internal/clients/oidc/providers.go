@@ -0,0 +1,118 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"resume-service/internal/config"
+	"strconv"
+	"strings"
+)
+
+const githubAPI = "https://api.github.com"
+
+type ProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Issuer enables OpenID Connect discovery and id token verification.
+	Issuer string
+	// AuthURL and TokenURL are only used by plain OAuth2 providers, which have no issuer.
+	AuthURL  string
+	TokenURL string
+	// APIURL is where plain OAuth2 providers serve the user's profile.
+	APIURL string
+}
+
+var presets = map[string]ProviderConfig{
+	"google": {
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"microsoft": {
+		// personal accounts, configure an issuer to use a specific tenant
+		Issuer: "https://login.microsoftonline.com/consumers/v2.0",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"github": {
+		AuthURL:  "https://github.com/login/oauth/authorize",
+		TokenURL: "https://github.com/login/oauth/access_token",
+		APIURL:   githubAPI,
+		Scopes:   []string{"read:user", "user:email"},
+	},
+}
+
+// NewProviders sets up the configured providers. Providers without a preset need an issuer.
+func NewProviders(configs []config.OAuthProvider) (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+	for _, provider := range configs {
+		config := presets[provider.Name]
+		config.Name = provider.Name
+		config.ClientID = provider.ClientID
+		config.ClientSecret = provider.ClientSecret.Value()
+		config.RedirectURL = provider.RedirectURL
+		if provider.Issuer != "" {
+			config.Issuer = provider.Issuer
+		}
+		if len(provider.Scopes) > 0 {
+			config.Scopes = provider.Scopes
+		}
+		if len(config.Scopes) == 0 {
+			config.Scopes = []string{"openid", "email", "profile"}
+		}
+
+		if config.Issuer == "" && (config.AuthURL == "" || config.TokenURL == "") {
+			return nil, fmt.Errorf("oauth provider %s: issuer is required", provider.Name)
+		}
+		providers[provider.Name] = NewProvider(config)
+	}
+	return providers, nil
+}
+
+func (p *Provider) fetchGithubIdentity(ctx context.Context, accessToken string) (Identity, error) {
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getWithToken(ctx, "/user", accessToken, &profile); err != nil {
+		return Identity{}, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getWithToken(ctx, "/user/emails", accessToken, &emails); err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{Provider: p.config.Name, Subject: strconv.FormatInt(profile.ID, 10), Name: profile.Name}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	if profile.ID == 0 {
+		return Identity{}, fmt.Errorf("github returned no user id")
+	}
+	return identity, nil
+}
+
+func (p *Provider) getWithToken(ctx context.Context, path, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.config.APIURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.doJSON(req, out)
+}
@@ -0,0 +1,246 @@
+// Package config is the one place the service reads its configuration. Load layers defaults, env files,
+// the environment and SSM parameters into a Config, which main passes on to the clients that need it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"resume-service/internal/logging"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Environment is "production", or "development" to run a single instance without the secrets and shared
+	// stores that several instances need.
+	Environment string
+	Port        int
+	// MetricsPort serves /metrics apart from the api, so it isn't reachable through the load balancer. 0 turns it off.
+	MetricsPort int
+	// ShutdownTimeout is how long in-flight requests & background jobs get to finish on SIGTERM.
+	ShutdownTimeout time.Duration
+	// ShutdownDrainDelay is how long requests are still served after /readyz starts failing on SIGTERM, so the load
+	// balancer takes the instance out first. It has to be at least its health check interval times the unhealthy
+	// threshold.
+	ShutdownDrainDelay time.Duration
+	LogLevel           slog.Level
+	AppURL             string
+	Region             string
+	AdminEmails        []string
+	JWTSecret          Secret
+	// ResumeVersionsKept is how many previous versions of a resume are kept, older ones are deleted when a new
+	// version is uploaded.
+	ResumeVersionsKept int
+	// ViewerHashKey keys the hashes that tell viewers of shared resumes apart without storing their ip. It has to
+	// be shared by all instances.
+	ViewerHashKey Secret
+
+	Mongo             Mongo
+	Email             Email
+	Storage           Storage
+	OpenAI            OpenAI
+	OAuth             []OAuthProvider
+	RateLimit         RateLimit
+	HumanVerification HumanVerification
+	Tracing           Tracing
+}
+
+type Mongo struct {
+	URI      Secret
+	Database string
+	// MigrateOnStartup applies pending schema migrations before serving. Without it the service refuses to start
+	// until "migrate up" has been run.
+	MigrateOnStartup bool
+}
+
+type Email struct {
+	Host     string
+	Port     int
+	Username string
+	Password Secret
+}
+
+type Storage struct {
+	Region string
+	Bucket string
+}
+
+type OpenAI struct {
+	APIKey Secret
+	Model  string
+}
+
+// OAuthProvider leaves out what the oidc package has presets for, like the issuer of well known providers.
+type OAuthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret Secret
+	RedirectURL  string
+	Issuer       string
+	Scopes       []string
+}
+
+type RateLimit struct {
+	// Store is "mongo" to share limits between instances, or "memory" to keep them per instance in development.
+	Store string
+	// Limits overrides the default limits by name, in the format of ratelimit.ParseLimit.
+	Limits map[string]string
+}
+
+type HumanVerification struct {
+	// Method is "pow", "captcha" or "none".
+	Method string
+	// CaptchaVerifyURL defaults to hCaptcha when empty.
+	CaptchaVerifyURL string
+	CaptchaSecret    Secret
+	// PowSecret signs challenges, so it has to be shared by all instances. It can only be left empty in
+	// development, the instance makes up its own then.
+	PowSecret     Secret
+	PowDifficulty int
+	OnSignup      bool
+}
+
+type Tracing struct {
+	// Endpoint is the host:port of an OTLP/HTTP collector. Spans aren't exported when it's empty.
+	Endpoint string
+	Insecure bool
+	// SampleRatio is the share of new traces that are recorded, from 0 to 1.
+	SampleRatio float64
+}
+
+const (
+	EnvironmentProduction  = "production"
+	EnvironmentDevelopment = "development"
+)
+
+const (
+	// legacyJWTSecret was built in before JWT_SECRET had to be set, it's public so it's refused
+	legacyJWTSecret = "your_jwt_secret"
+
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreMongo  = "mongo"
+
+	HumanVerificationPow     = "pow"
+	HumanVerificationCaptcha = "captcha"
+	HumanVerificationNone    = "none"
+)
+
+// Validate reports every problem at once, so a bad deploy can be fixed in one go.
+func (c *Config) Validate() error {
+	var problems []error
+	require := func(value, key string) {
+		if value == "" {
+			problems = append(problems, fmt.Errorf("%s is required", key))
+		}
+	}
+
+	require(c.Mongo.URI.Value(), keyMongoURI)
+	require(c.Mongo.Database, keyMongoDatabase)
+	require(c.OpenAI.APIKey.Value(), keyOpenAIAPIKey)
+	require(c.Email.Username, keySenderEmail)
+	require(c.Email.Password.Value(), keySenderPass)
+	require(c.JWTSecret.Value(), keyJWTSecret)
+	if c.JWTSecret.Value() == legacyJWTSecret {
+		problems = append(problems, fmt.Errorf("%s must not be the old built in secret, tokens signed with it can be forged", keyJWTSecret))
+	}
+	require(c.Storage.Bucket, keyBucket)
+	require(c.ViewerHashKey.Value(), keyViewerHashKey)
+	if c.ViewerHashKey.Value() != "" && c.ViewerHashKey.Value() == c.JWTSecret.Value() {
+		problems = append(problems, fmt.Errorf("%s must not be the same as %s", keyViewerHashKey, keyJWTSecret))
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		problems = append(problems, fmt.Errorf("%s must be a port number", keyPort))
+	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 || c.MetricsPort == c.Port {
+		problems = append(problems, fmt.Errorf("%s must be a port number other than %s, or 0", keyMetricsPort, keyPort))
+	}
+	if c.ResumeVersionsKept < 0 {
+		problems = append(problems, fmt.Errorf("%s can't be negative", keyResumeVersionsKept))
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("%s must be a positive duration", keyShutdownTimeout))
+	}
+	if c.ShutdownDrainDelay < 0 {
+		problems = append(problems, fmt.Errorf("%s can't be negative", keyShutdownDrain))
+	}
+	if c.Email.Port <= 0 || c.Email.Port > 65535 {
+		problems = append(problems, fmt.Errorf("%s must be a port number", keySMTPPort))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Errorf("%s must be between 0 and 1", keyTracingSampleRatio))
+	}
+
+	if c.RateLimit.Store != RateLimitStoreMemory && c.RateLimit.Store != RateLimitStoreMongo {
+		problems = append(problems, fmt.Errorf("%s must be %s or %s", keyRateLimitStore, RateLimitStoreMemory, RateLimitStoreMongo))
+	}
+
+	if c.Environment != EnvironmentProduction && c.Environment != EnvironmentDevelopment {
+		problems = append(problems, fmt.Errorf("%s must be %s or %s", keyEnvironment, EnvironmentProduction, EnvironmentDevelopment))
+	}
+
+	switch c.HumanVerification.Method {
+	case HumanVerificationPow:
+		if c.HumanVerification.PowDifficulty < 0 || c.HumanVerification.PowDifficulty > 64 {
+			problems = append(problems, fmt.Errorf("%s must be a number of bits between 0 and 64", keyPowDifficulty))
+		}
+		// solutions are only refused once by the instance that saw them, unless the store is shared
+		if c.Environment != EnvironmentDevelopment {
+			require(c.HumanVerification.PowSecret.Value(), keyPowSecret)
+			if c.RateLimit.Store != RateLimitStoreMongo {
+				problems = append(problems, fmt.Errorf("%s must be %s for %s %s outside development", keyRateLimitStore, RateLimitStoreMongo, keyHumanVerification, HumanVerificationPow))
+			}
+		}
+	case HumanVerificationCaptcha:
+		require(c.HumanVerification.CaptchaSecret.Value(), keyCaptchaSecret)
+	case HumanVerificationNone:
+	default:
+		problems = append(problems, fmt.Errorf("%s must be %s, %s or %s", keyHumanVerification, HumanVerificationPow, HumanVerificationCaptcha, HumanVerificationNone))
+	}
+
+	for _, provider := range c.OAuth {
+		prefix := oauthPrefix(provider.Name)
+		require(provider.ClientID, prefix+"CLIENT_ID")
+		require(provider.RedirectURL, prefix+"REDIRECT_URL")
+	}
+
+	return errors.Join(problems...)
+}
+
+// LogValue logs the settings that tell deploys apart. Secrets are left out and email addresses masked.
+func (c Config) LogValue() slog.Value {
+	adminEmails := make([]string, len(c.AdminEmails))
+	for i, email := range c.AdminEmails {
+		adminEmails[i] = logging.MaskEmail(email)
+	}
+	providers := make([]string, len(c.OAuth))
+	for i, provider := range c.OAuth {
+		providers[i] = provider.Name
+	}
+	return slog.GroupValue(
+		slog.String("environment", c.Environment),
+		slog.Int("port", c.Port),
+		slog.Int("metrics_port", c.MetricsPort),
+		slog.Duration("shutdown_timeout", c.ShutdownTimeout),
+		slog.Duration("shutdown_drain_delay", c.ShutdownDrainDelay),
+		slog.String("log_level", c.LogLevel.String()),
+		slog.String("app_url", c.AppURL),
+		slog.String("region", c.Region),
+		slog.Any("admin_emails", adminEmails),
+		slog.Int("resume_versions_kept", c.ResumeVersionsKept),
+		slog.Group("mongo", "database", c.Mongo.Database, "migrate_on_startup", c.Mongo.MigrateOnStartup),
+		slog.Group("email", "host", c.Email.Host, "port", c.Email.Port, "sender", logging.MaskEmail(c.Email.Username)),
+		slog.Group("storage", "region", c.Storage.Region, "bucket", c.Storage.Bucket),
+		slog.String("openai_model", c.OpenAI.Model),
+		slog.Any("oauth_providers", providers),
+		slog.String("rate_limit_store", c.RateLimit.Store),
+		slog.Group("human_verification", "method", c.HumanVerification.Method, "pow_difficulty", c.HumanVerification.PowDifficulty, "on_signup", c.HumanVerification.OnSignup),
+		slog.Group("tracing", "endpoint", c.Tracing.Endpoint, "sample_ratio", c.Tracing.SampleRatio),
+	)
+}
+
+func oauthPrefix(name string) string {
+	return "OAUTH_" + strings.ToUpper(name) + "_"
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requiredValues are the defaults plus the secrets that have no default.
+func requiredValues() map[string]string {
+	return merge(defaults, map[string]string{
+		keyMongoURI:      "mongodb://localhost:27017",
+		keyJWTSecret:     "jwt-secret",
+		keyViewerHashKey: "viewer-hash-key",
+		keyOpenAIAPIKey:  "sk-openai",
+		keySenderEmail:   "noreply@interviewgrab.tech",
+		keySenderPass:    "smtp-password",
+		keyPowSecret:     "pow-secret",
+	})
+}
+
+func TestDefaultsOnlyLackSecrets(t *testing.T) {
+	config, err := FromValues(requiredValues())
+	if err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+	if config.Environment != EnvironmentProduction || config.RateLimit.Store != RateLimitStoreMongo || config.HumanVerification.Method != HumanVerificationPow {
+		t.Errorf("Expected the production defaults, got %+v", config)
+	}
+
+	_, err = FromValues(defaults)
+	if err == nil {
+		t.Fatal("Expected the defaults alone to lack the secrets")
+	}
+	for _, key := range []string{keyEnvironment, keyRateLimitStore, keyHumanVerification} {
+		if strings.Contains(err.Error(), key) {
+			t.Errorf("Expected no problem with the default %s, got %v", key, err)
+		}
+	}
+}
+
+func TestFromValuesAppliesDefaults(t *testing.T) {
+	values := requiredValues()
+	values[keyOAuthProviders] = "Google, acme"
+	values["OAUTH_GOOGLE_CLIENT_ID"] = "google-id"
+	values["OAUTH_GOOGLE_REDIRECT_URL"] = "https://interviewgrab.tech/oauth/google"
+	values["OAUTH_ACME_CLIENT_ID"] = "acme-id"
+	values["OAUTH_ACME_REDIRECT_URL"] = "https://interviewgrab.tech/oauth/acme"
+	values["OAUTH_ACME_SCOPES"] = "openid email"
+	values["RATE_LIMIT_LOGIN"] = "20/1m"
+
+	config, err := FromValues(values)
+	if err != nil {
+		t.Fatalf("Expected config to be valid, got %v", err)
+	}
+	if config.ShutdownDrainDelay != 20*time.Second {
+		t.Errorf("Expected a drain delay of two health checks, got %v", config.ShutdownDrainDelay)
+	}
+	if config.Port != 8080 || config.Mongo.Database != "resume_service" || config.Email.Host != "smtp.gmail.com" || config.Storage.Bucket != "resume-service-filestore" {
+		t.Errorf("Expected defaults, got %+v", config)
+	}
+	if len(config.OAuth) != 2 || config.OAuth[0].Name != "google" || len(config.OAuth[1].Scopes) != 2 {
+		t.Errorf("Expected two oauth providers, got %+v", config.OAuth)
+	}
+	if len(config.RateLimit.Limits) != 1 || config.RateLimit.Limits["login"] != "20/1m" {
+		t.Errorf("Expected only the login limit to be overridden, got %v", config.RateLimit.Limits)
+	}
+}
+
+func TestFromValuesReportsAllProblems(t *testing.T) {
+	values := merge(defaults, map[string]string{
+		keyEnvironment:       "staging",
+		keyPort:              "http",
+		keyLogLevel:          "loud",
+		keyRateLimitStore:    "redis",
+		keyHumanVerification: HumanVerificationCaptcha,
+	})
+
+	_, err := FromValues(values)
+	if err == nil {
+		t.Fatal("Expected an invalid config")
+	}
+	for _, key := range []string{keyEnvironment, keyPort, keyLogLevel, keyMongoURI, keyJWTSecret, keyViewerHashKey, keyOpenAIAPIKey, keySenderEmail, keySenderPass, keyRateLimitStore, keyCaptchaSecret} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected a problem with %s, got %v", key, err)
+		}
+	}
+}
+
+func TestFromValuesRefusesLegacyJWTSecret(t *testing.T) {
+	values := requiredValues()
+	values[keyJWTSecret] = legacyJWTSecret
+
+	_, err := FromValues(values)
+	if err == nil || !strings.Contains(err.Error(), keyJWTSecret) {
+		t.Errorf("Expected the built in JWT secret to be refused, got %v", err)
+	}
+}
+
+type fakeParams map[string]string
+
+func (p fakeParams) GetStringParam(param string) (string, error) {
+	value, ok := p[param]
+	if !ok {
+		return "", errors.New("ParameterNotFound")
+	}
+	return value, nil
+}
+
+func TestFromValuesRequiresSharedProofOfWork(t *testing.T) {
+	values := requiredValues()
+	values[keyPowSecret] = ""
+	values[keyRateLimitStore] = RateLimitStoreMemory
+
+	_, err := FromValues(values)
+	if err == nil || !strings.Contains(err.Error(), keyPowSecret) || !strings.Contains(err.Error(), keyRateLimitStore) {
+		t.Errorf("Expected a shared secret and store to be required, got %v", err)
+	}
+
+	values[keyEnvironment] = EnvironmentDevelopment
+	if _, err = FromValues(values); err != nil {
+		t.Errorf("Expected development to run with its own secret and store, got %v", err)
+	}
+}
+
+func TestLaterLayersWin(t *testing.T) {
+	file := map[string]string{keyMongoURI: "mongodb://file", keyAppURL: "https://file.example"}
+	env := map[string]string{keyMongoURI: "mongodb://env", keyAppURL: ""}
+	ssm := fetchParams(fakeParams{keyMongoURI: "mongodb://ssm"}, ssmParams)
+
+	values := merge(defaults, file, env, ssm)
+	if values[keyMongoURI] != "mongodb://ssm" {
+		t.Errorf("Expected ssm to win, got %s", values[keyMongoURI])
+	}
+	if values[keyAppURL] != "https://file.example" {
+		t.Errorf("Expected empty values not to override, got %s", values[keyAppURL])
+	}
+	if values[keyPort] != "8080" {
+		t.Errorf("Expected defaults to stay, got %s", values[keyPort])
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	values := requiredValues()
+	values[keyAdminEmails] = "jane@example.com"
+	config, err := FromValues(values)
+	if err != nil {
+		t.Fatalf("Expected config to be valid, got %v", err)
+	}
+
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Cannot marshal config: %v", err)
+	}
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("config", "api_key", config.OpenAI.APIKey, "config", config)
+
+	for _, output := range []string{fmt.Sprintf("%v", *config), fmt.Sprintf("%+v", config), fmt.Sprintf("%#v", *config), string(encoded), logged.String()} {
+		if strings.Contains(output, "sk-openai") || strings.Contains(output, "smtp-password") {
+			t.Errorf("Expected secrets to be redacted, got %s", output)
+		}
+	}
+	if output := logged.String(); strings.Contains(output, "jane@") || !strings.Contains(output, `"sender":"n***@interviewgrab.tech"`) {
+		t.Errorf("Expected email addresses to be logged masked, got %s", output)
+	}
+	if config.OpenAI.APIKey.Value() != "sk-openai" {
+		t.Errorf("Expected Value to return the secret")
+	}
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"resume-service/internal/clients/parameters"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	keyEnvironment     = "ENVIRONMENT"
+	keyPort            = "PORT"
+	keyMetricsPort     = "METRICS_PORT"
+	keyShutdownTimeout = "SHUTDOWN_TIMEOUT"
+	keyShutdownDrain   = "SHUTDOWN_DRAIN_DELAY"
+	keyLogLevel        = "LOG_LEVEL"
+	keyAppURL          = "APP_URL"
+	keyRegion          = "REGION"
+	keyAdminEmails     = "ADMIN_EMAILS"
+	keyJWTSecret       = "JWT_SECRET"
+
+	keyResumeVersionsKept = "RESUME_VERSIONS_KEPT"
+	keyViewerHashKey      = "VIEWER_HASH_KEY"
+
+	keyMongoURI       = "MONGO_URI"
+	keyMongoDatabase  = "MONGO_DATABASE"
+	keyMigrateOnStart = "MIGRATE_ON_STARTUP"
+	keySMTPHost       = "SMTP_HOST"
+	keySMTPPort       = "SMTP_PORT"
+	keySenderEmail    = "SENDER_EMAIL"
+	keySenderPass     = "SENDER_PASS"
+	keyBucket         = "S3_BUCKET"
+	keyOpenAIAPIKey   = "OPENAI_API_KEY"
+	keyOpenAIModel    = "OPENAI_MODEL"
+
+	keyOAuthProviders = "OAUTH_PROVIDERS"
+
+	keyRateLimitStore  = "RATE_LIMIT_STORE"
+	rateLimitKeyPrefix = "RATE_LIMIT_"
+
+	keyHumanVerification       = "HUMAN_VERIFICATION"
+	keyHumanVerificationSignup = "HUMAN_VERIFICATION_SIGNUP"
+	keyCaptchaVerifyURL        = "CAPTCHA_VERIFY_URL"
+	keyCaptchaSecret           = "CAPTCHA_SECRET"
+	keyPowSecret               = "POW_SECRET"
+	keyPowDifficulty           = "POW_DIFFICULTY"
+
+	keyTracingEndpoint    = "TRACING_ENDPOINT"
+	keyTracingInsecure    = "TRACING_INSECURE"
+	keyTracingSampleRatio = "TRACING_SAMPLE_RATIO"
+)
+
+// envFiles are read in order, later files take precedence. Neither has to exist.
+var envFiles = []string{".env", ".keys"}
+
+// ssmParams are looked up in the SSM parameter store, which takes precedence over every other source.
+// The task role can only read the parameters listed in infra.
+var ssmParams = []string{keyMongoURI, keyOpenAIAPIKey, keySenderEmail, keySenderPass, keyJWTSecret, keyViewerHashKey, keyPowSecret}
+
+var defaults = map[string]string{
+	keyEnvironment:        EnvironmentProduction,
+	keyPort:               "8080",
+	keyMetricsPort:        "9090",
+	keyShutdownTimeout:    "25s",
+	keyShutdownDrain:      "20s",
+	keyLogLevel:           "info",
+	keyAppURL:             "https://interviewgrab.tech",
+	keyResumeVersionsKept: "10",
+	keyMongoDatabase:      "resume_service",
+	keyMigrateOnStart:     "true",
+	keySMTPHost:           "smtp.gmail.com",
+	keySMTPPort:           "587",
+	keyBucket:             "resume-service-filestore",
+	keyOpenAIModel:        "gpt-3.5-turbo",
+	keyRateLimitStore:     RateLimitStoreMongo,
+	keyHumanVerification:  HumanVerificationPow,
+	keyPowDifficulty:      "20",
+	keyTracingSampleRatio: "1",
+}
+
+// ParamGetter reads a parameter from a remote store, like parameters.ParamClient does from SSM.
+type ParamGetter interface {
+	GetStringParam(param string) (string, error)
+}
+
+// Load reads the configuration from, in increasing precedence: defaults, env files, the environment and SSM.
+func Load() (*Config, error) {
+	layers := []map[string]string{defaults}
+	for _, file := range envFiles {
+		values, err := godotenv.Read(file)
+		if err != nil {
+			slog.Warn("Cannot load env file", "file", file, "error", err)
+			continue
+		}
+		layers = append(layers, values)
+	}
+	layers = append(layers, environ())
+
+	region := merge(layers...)[keyRegion]
+	params, err := parameters.NewParamClient(region)
+	if err != nil {
+		slog.Warn("Cannot create param client", "error", err)
+	} else {
+		layers = append(layers, fetchParams(params, ssmParams))
+	}
+
+	return FromValues(merge(layers...))
+}
+
+// FromValues builds and validates a Config from flat key/values, as found in the environment.
+func FromValues(values map[string]string) (*Config, error) {
+	r := reader{values: values}
+	config := &Config{
+		Environment:        r.string(keyEnvironment),
+		Port:               r.int(keyPort),
+		MetricsPort:        r.int(keyMetricsPort),
+		ShutdownTimeout:    r.duration(keyShutdownTimeout),
+		ShutdownDrainDelay: r.duration(keyShutdownDrain),
+		LogLevel:           r.level(keyLogLevel),
+		AppURL:             strings.TrimSuffix(r.string(keyAppURL), "/"),
+		Region:             r.string(keyRegion),
+		AdminEmails:        r.list(keyAdminEmails),
+		JWTSecret:          r.secret(keyJWTSecret),
+		ResumeVersionsKept: r.int(keyResumeVersionsKept),
+		ViewerHashKey:      r.secret(keyViewerHashKey),
+		Mongo: Mongo{
+			URI:              r.secret(keyMongoURI),
+			Database:         r.string(keyMongoDatabase),
+			MigrateOnStartup: r.bool(keyMigrateOnStart),
+		},
+		Email: Email{
+			Host:     r.string(keySMTPHost),
+			Port:     r.int(keySMTPPort),
+			Username: r.string(keySenderEmail),
+			Password: r.secret(keySenderPass),
+		},
+		Storage: Storage{
+			Region: r.string(keyRegion),
+			Bucket: r.string(keyBucket),
+		},
+		OpenAI: OpenAI{
+			APIKey: r.secret(keyOpenAIAPIKey),
+			Model:  r.string(keyOpenAIModel),
+		},
+		RateLimit: RateLimit{
+			Store:  r.string(keyRateLimitStore),
+			Limits: r.prefixed(rateLimitKeyPrefix, keyRateLimitStore),
+		},
+		HumanVerification: HumanVerification{
+			Method:           r.string(keyHumanVerification),
+			CaptchaVerifyURL: r.string(keyCaptchaVerifyURL),
+			CaptchaSecret:    r.secret(keyCaptchaSecret),
+			PowSecret:        r.secret(keyPowSecret),
+			PowDifficulty:    r.int(keyPowDifficulty),
+			OnSignup:         r.bool(keyHumanVerificationSignup),
+		},
+		Tracing: Tracing{
+			Endpoint:    r.string(keyTracingEndpoint),
+			Insecure:    r.bool(keyTracingInsecure),
+			SampleRatio: r.float(keyTracingSampleRatio),
+		},
+	}
+
+	for _, name := range r.list(keyOAuthProviders) {
+		name = strings.ToLower(name)
+		prefix := oauthPrefix(name)
+		config.OAuth = append(config.OAuth, OAuthProvider{
+			Name:         name,
+			ClientID:     r.string(prefix + "CLIENT_ID"),
+			ClientSecret: r.secret(prefix + "CLIENT_SECRET"),
+			RedirectURL:  r.string(prefix + "REDIRECT_URL"),
+			Issuer:       r.string(prefix + "ISSUER"),
+			Scopes:       strings.Fields(r.string(prefix + "SCOPES")),
+		})
+	}
+
+	if err := errors.Join(append(r.errs, config.Validate())...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func environ() map[string]string {
+	values := map[string]string{}
+	for _, pair := range os.Environ() {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func fetchParams(params ParamGetter, names []string) map[string]string {
+	values := map[string]string{}
+	for _, name := range names {
+		value, err := params.GetStringParam(name)
+		if err != nil {
+			slog.Warn("Cannot read param", "param", name, "error", err)
+			continue
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// merge flattens the layers, values of later layers win. Empty values count as unset.
+func merge(layers ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, layer := range layers {
+		for key, value := range layer {
+			if value != "" {
+				merged[key] = value
+			}
+		}
+	}
+	return merged
+}
+
+// reader converts values, collecting the errors so they can be reported together.
+type reader struct {
+	values map[string]string
+	errs   []error
+}
+
+func (r *reader) string(key string) string {
+	return strings.TrimSpace(r.values[key])
+}
+
+func (r *reader) secret(key string) Secret {
+	return Secret(r.string(key))
+}
+
+func (r *reader) int(key string) int {
+	value := r.string(key)
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a number, got %q", key, value))
+	}
+	return number
+}
+
+func (r *reader) float(key string) float64 {
+	value := r.string(key)
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a number, got %q", key, value))
+	}
+	return number
+}
+
+func (r *reader) duration(key string) time.Duration {
+	value := r.string(key)
+	if value == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a duration like 30s, got %q", key, value))
+	}
+	return duration
+}
+
+func (r *reader) level(key string) slog.Level {
+	var level slog.Level
+	if value := r.string(key); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be debug, info, warn or error, got %q", key, value))
+		}
+	}
+	return level
+}
+
+func (r *reader) bool(key string) bool {
+	value := r.string(key)
+	if value == "" {
+		return false
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be true or false, got %q", key, value))
+	}
+	return parsed
+}
+
+// list splits a comma separated value, dropping empty entries.
+func (r *reader) list(key string) []string {
+	var list []string
+	for _, item := range strings.Split(r.string(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// prefixed returns the values of all keys with the prefix, by the rest of the key in lower case.
+func (r *reader) prefixed(prefix string, except ...string) map[string]string {
+	values := map[string]string{}
+	for key := range r.values {
+		if !strings.HasPrefix(key, prefix) || contains(except, key) {
+			continue
+		}
+		values[strings.ToLower(strings.TrimPrefix(key, prefix))] = r.string(key)
+	}
+	return values
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+const redacted = "[redacted]"
+
+// Secret is a config value that must not end up in logs. Printing or marshalling it shows a placeholder,
+// Value returns the real thing.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"resume-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type APIKeyStore struct {
+	collection *mongo.Collection
+}
+
+const apiKeyCollection = "api_keys"
+
+// APIKeyLastUsedInterval is how often the last used time of a key is written, not on every request.
+const APIKeyLastUsedInterval = time.Minute
+
+func newAPIKeyStore(dbClient *mongo.Database) APIKeyStore {
+	return APIKeyStore{collection: dbClient.Collection(apiKeyCollection)}
+}
+
+func (s *APIKeyStore) CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error) {
+	ctx, done := instrument(ctx, "api_key", "CreateAPIKey")
+	defer done()
+
+	res, err := s.collection.InsertOne(ctx, key)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	key.ID = res.InsertedID.(primitive.ObjectID)
+	return key, nil
+}
+
+func (s *APIKeyStore) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (model.APIKey, error) {
+	ctx, done := instrument(ctx, "api_key", "GetActiveAPIKeyByHash")
+	defer done()
+
+	key := &model.APIKey{}
+	err := s.collection.FindOne(ctx, bson.M{"key_hash": keyHash, "revoked": false}).Decode(key)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	return *key, nil
+}
+
+func (s *APIKeyStore) GetAPIKeysByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.APIKey, error) {
+	ctx, done := instrument(ctx, "api_key", "GetAPIKeysByUserId")
+	defer done()
+
+	cursor, err := s.collection.Find(ctx, bson.M{"user_id": userId, "revoked": false})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []model.APIKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *APIKeyStore) TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	ctx, done := instrument(ctx, "api_key", "TouchAPIKey")
+	defer done()
+
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"last_used_at": bson.M{"$lt": usedAt.Add(-APIKeyLastUsedInterval)}},
+			bson.M{"last_used_at": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"last_used_at": usedAt}},
+	)
+	return err
+}
+
+func (s *APIKeyStore) RevokeAPIKey(ctx context.Context, userId primitive.ObjectID, id string) error {
+	ctx, done := instrument(ctx, "api_key", "RevokeAPIKey")
+	defer done()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objectId, "user_id": userId, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return result.Err()
+}
+
+func (s *APIKeyStore) RevokeUserAPIKeys(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "api_key", "RevokeUserAPIKeys")
+	defer done()
+
+	_, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userId, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (s *APIKeyStore) DeleteUserAPIKeys(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "api_key", "DeleteUserAPIKeys")
+	defer done()
+
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"resume-service/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditStore struct {
+	collection *mongo.Collection
+}
+
+const auditCollection = "audit_log"
+
+func newAuditStore(dbClient *mongo.Database) AuditStore {
+	return AuditStore{collection: dbClient.Collection(auditCollection)}
+}
+
+func (s *AuditStore) StoreEvent(ctx context.Context, event model.AuditEvent) error {
+	ctx, done := instrument(ctx, "audit", "StoreEvent")
+	defer done()
+
+	_, err := s.collection.InsertOne(ctx, event)
+	return err
+}
+
+// GetEvents returns the latest events, optionally only those about one user.
+func (s *AuditStore) GetEvents(ctx context.Context, targetUserId string, limit int64, skip int64) ([]model.AuditEvent, error) {
+	ctx, done := instrument(ctx, "audit", "GetEvents")
+	defer done()
+
+	filter := bson.M{}
+	if targetUserId != "" {
+		filter["target_user_id"] = targetUserId
+	}
+
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"time": -1}).SetLimit(limit).SetSkip(skip))
+	if err != nil {
+		return nil, err
+	}
+
+	events := []model.AuditEvent{}
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
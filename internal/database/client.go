@@ -2,58 +2,59 @@ package database
 
 import (
 	"context"
-	"log"
-	"os"
+	"resume-service/internal/config"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type DB struct {
-	client *mongo.Client
-	User   UserStore
-	Resume ResumeStore
+	client     *mongo.Client
+	name       string
+	User       UserStore
+	Resume     ResumeStore
+	Session    SessionStore
+	OAuthState OAuthStateStore
+	APIKey     APIKeyStore
+	Audit      AuditStore
+	Export     ExportStore
+	RateLimit  RateLimitStore
 }
 
-const (
-	dbName = "resume_service"
-)
-
-func NewClient(ctx context.Context) (*DB, error) {
-	mongoUri := os.Getenv("MONGO_URI")
-	if mongoUri == "" {
-		mongoUri = "mongodb://0.0.0.0:27017"
-	}
-	connection, err := createConnection(ctx, mongoUri)
-	if err != nil {
-		return nil, err
-	}
-	database := connection.Database(dbName)
-	userStore, err := newUserStore(ctx, database)
-	if err != nil {
-		return nil, err
-	}
-	resumeStore, err := newResumeStore(ctx, database)
+func NewClient(ctx context.Context, config config.Mongo) (*DB, error) {
+	connection, err := createConnection(ctx, config.URI.Value())
 	if err != nil {
 		return nil, err
 	}
+	database := connection.Database(config.Database)
 	return &DB{
-		client: connection,
-		User:   userStore,
-		Resume: resumeStore,
+		client:     connection,
+		name:       config.Database,
+		User:       newUserStore(database),
+		Resume:     newResumeStore(database),
+		Session:    newSessionStore(database),
+		OAuthState: newOAuthStateStore(database),
+		APIKey:     newAPIKeyStore(database),
+		Audit:      newAuditStore(database),
+		Export:     newExportStore(database),
+		RateLimit:  newRateLimitStore(database),
 	}, nil
 }
 
 func createConnection(ctx context.Context, uri string) (*mongo.Client, error) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+	client, err := mongo.NewClient(options.Client().ApplyURI(uri).SetMonitor(commandMonitor()))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
+	if err = client.Connect(ctx); err != nil {
+		return nil, err
 	}
-	return client, err
+	return client, nil
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return db.client.Ping(ctx, readpref.Primary())
 }
 
 func (db *DB) Disconnect(ctx context.Context) error {
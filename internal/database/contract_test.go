@@ -0,0 +1,112 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"resume-service/internal/config"
+	"resume-service/internal/database"
+	"resume-service/internal/database/migrate"
+	"resume-service/internal/database/storetest"
+	"resume-service/internal/ratelimit"
+	ratelimitstoretest "resume-service/internal/ratelimit/storetest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestDB connects to a fresh database on the server in MONGO_TEST_URI, and drops it after the test.
+func newTestDB(t *testing.T) *database.DB {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	name := "resume_service_test_" + uuid.NewString()[:8]
+	db, err := database.NewClient(ctx, config.Mongo{URI: config.Secret(uri), Database: name})
+	if err != nil {
+		t.Fatalf("Cannot connect to %s: %v", uri, err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = database.DropDatabase(ctx, db)
+		_ = db.Disconnect(ctx)
+	})
+
+	migrator, err := db.Migrator()
+	if err == nil {
+		err = migrator.Up(ctx, 0)
+	}
+	if err != nil {
+		t.Fatalf("Cannot migrate: %v", err)
+	}
+	return db
+}
+
+func TestUserStoreContract(t *testing.T) {
+	storetest.UserRepository(t, func(t *testing.T) database.UserRepository { return &newTestDB(t).User })
+}
+
+func TestResumeStoreContract(t *testing.T) {
+	storetest.ResumeRepository(t, func(t *testing.T) database.ResumeRepository { return &newTestDB(t).Resume })
+}
+
+func TestSessionStoreContract(t *testing.T) {
+	storetest.SessionRepository(t, func(t *testing.T) database.SessionRepository { return &newTestDB(t).Session })
+}
+
+func TestAPIKeyStoreContract(t *testing.T) {
+	storetest.APIKeyRepository(t, func(t *testing.T) database.APIKeyRepository { return &newTestDB(t).APIKey })
+}
+
+func TestExportStoreContract(t *testing.T) {
+	storetest.ExportRepository(t, func(t *testing.T) database.ExportRepository { return &newTestDB(t).Export })
+}
+
+func TestAuditStoreContract(t *testing.T) {
+	storetest.AuditRepository(t, func(t *testing.T) database.AuditRepository { return &newTestDB(t).Audit })
+}
+
+func TestOAuthStateStoreContract(t *testing.T) {
+	storetest.OAuthStateRepository(t, func(t *testing.T) database.OAuthStateRepository { return &newTestDB(t).OAuthState })
+}
+
+func TestRateLimitStoreContract(t *testing.T) {
+	ratelimitstoretest.Store(t, func(t *testing.T) ratelimit.Store { return &newTestDB(t).RateLimit })
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	migrator, err := db.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = migrator.Check(ctx); err != nil {
+		t.Fatalf("Expected a migrated database to pass the check, got %v", err)
+	}
+
+	if err = migrator.Down(ctx, migrator.Latest()); err != nil {
+		t.Fatalf("Cannot roll back: %v", err)
+	}
+	if err = migrator.Check(ctx); !errors.Is(err, migrate.ErrPending) {
+		t.Errorf("Expected ErrPending after rolling back, got %v", err)
+	}
+
+	if err = migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("Cannot migrate again: %v", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Unknown {
+			t.Errorf("Expected migration %d to be applied, got %+v", status.Version, status)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"resume-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExportStore struct {
+	collection *mongo.Collection
+}
+
+const exportCollection = "exports"
+
+func newExportStore(dbClient *mongo.Database) ExportStore {
+	return ExportStore{collection: dbClient.Collection(exportCollection)}
+}
+
+func (s *ExportStore) CreateExport(ctx context.Context, export model.Export) (model.Export, error) {
+	ctx, done := instrument(ctx, "export", "CreateExport")
+	defer done()
+
+	res, err := s.collection.InsertOne(ctx, export)
+	if err != nil {
+		return model.Export{}, err
+	}
+	export.ID = res.InsertedID.(primitive.ObjectID)
+	return export, nil
+}
+
+func (s *ExportStore) UpdateExportStatus(ctx context.Context, id primitive.ObjectID, status string, key string) error {
+	ctx, done := instrument(ctx, "export", "UpdateExportStatus")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"status": status, "key": key}},
+	)
+	return result.Err()
+}
+
+func (s *ExportStore) GetLatestExport(ctx context.Context, userId primitive.ObjectID) (model.Export, error) {
+	ctx, done := instrument(ctx, "export", "GetLatestExport")
+	defer done()
+
+	export := &model.Export{}
+	err := s.collection.FindOne(
+		ctx,
+		bson.M{"user_id": userId},
+		options.FindOne().SetSort(bson.M{"created_at": -1}),
+	).Decode(export)
+	if err != nil {
+		return model.Export{}, err
+	}
+	return *export, nil
+}
+
+func (s *ExportStore) GetExpiredExports(ctx context.Context, now time.Time) ([]model.Export, error) {
+	ctx, done := instrument(ctx, "export", "GetExpiredExports")
+	defer done()
+
+	return s.find(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+}
+
+func (s *ExportStore) GetExportsByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.Export, error) {
+	ctx, done := instrument(ctx, "export", "GetExportsByUserId")
+	defer done()
+
+	return s.find(ctx, bson.M{"user_id": userId})
+}
+
+func (s *ExportStore) DeleteExport(ctx context.Context, id primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "export", "DeleteExport")
+	defer done()
+
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (s *ExportStore) find(ctx context.Context, filter bson.M) ([]model.Export, error) {
+	ctx, done := instrument(ctx, "export", "find")
+	defer done()
+
+	cursor, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := []model.Export{}
+	if err = cursor.All(ctx, &exports); err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
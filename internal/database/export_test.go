@@ -0,0 +1,8 @@
+package database
+
+import "context"
+
+// DropDatabase lets the contract tests clean up after themselves.
+func DropDatabase(ctx context.Context, db *DB) error {
+	return db.client.Database(db.name).Drop(ctx)
+}
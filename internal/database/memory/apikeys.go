@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKeyStore struct {
+	mu   sync.Mutex
+	keys map[primitive.ObjectID]model.APIKey
+}
+
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{keys: map[primitive.ObjectID]model.APIKey{}}
+}
+
+var _ database.APIKeyRepository = (*APIKeyStore)(nil)
+
+func (s *APIKeyStore) CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.ID = newId(key.ID)
+	if _, exists := s.keys[key.ID]; exists {
+		return model.APIKey{}, database.ErrDuplicateKey
+	}
+	s.keys[key.ID] = key
+	return key, nil
+}
+
+func (s *APIKeyStore) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.KeyHash == keyHash && !key.Revoked {
+			return key, nil
+		}
+	}
+	return model.APIKey{}, database.ErrNotFound
+}
+
+func (s *APIKeyStore) GetAPIKeysByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []model.APIKey{}
+	for _, key := range s.keys {
+		if key.UserID == userId && !key.Revoked {
+			keys = append(keys, key)
+		}
+	}
+	sortById(keys, func(key model.APIKey) primitive.ObjectID { return key.ID }, false)
+	return keys, nil
+}
+
+func (s *APIKeyStore) TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if ok && (key.LastUsedAt.IsZero() || key.LastUsedAt.Before(usedAt.Add(-database.APIKeyLastUsedInterval))) {
+		key.LastUsedAt = usedAt
+		s.keys[id] = key
+	}
+	return nil
+}
+
+func (s *APIKeyStore) RevokeAPIKey(ctx context.Context, userId primitive.ObjectID, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[objectId]
+	if !ok || key.UserID != userId || key.Revoked {
+		return database.ErrNotFound
+	}
+	key.Revoked = true
+	s.keys[objectId] = key
+	return nil
+}
+
+func (s *APIKeyStore) RevokeUserAPIKeys(ctx context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, key := range s.keys {
+		if key.UserID == userId {
+			key.Revoked = true
+			s.keys[id] = key
+		}
+	}
+	return nil
+}
+
+func (s *APIKeyStore) DeleteUserAPIKeys(ctx context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, key := range s.keys {
+		if key.UserID == userId {
+			delete(s.keys, id)
+		}
+	}
+	return nil
+}
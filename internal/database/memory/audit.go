@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"sort"
+	"sync"
+)
+
+type AuditStore struct {
+	mu     sync.Mutex
+	events []model.AuditEvent
+}
+
+func NewAuditStore() *AuditStore {
+	return &AuditStore{}
+}
+
+var _ database.AuditRepository = (*AuditStore)(nil)
+
+func (s *AuditStore) StoreEvent(ctx context.Context, event model.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = newId(event.ID)
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *AuditStore) GetEvents(ctx context.Context, targetUserId string, limit int64, skip int64) ([]model.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []model.AuditEvent{}
+	for _, event := range s.events {
+		if targetUserId == "" || event.TargetUserID == targetUserId {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	return page(events, limit, skip), nil
+}
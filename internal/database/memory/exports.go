@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExportStore struct {
+	mu      sync.Mutex
+	exports map[primitive.ObjectID]model.Export
+}
+
+func NewExportStore() *ExportStore {
+	return &ExportStore{exports: map[primitive.ObjectID]model.Export{}}
+}
+
+var _ database.ExportRepository = (*ExportStore)(nil)
+
+func (s *ExportStore) CreateExport(ctx context.Context, export model.Export) (model.Export, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export.ID = newId(export.ID)
+	if _, exists := s.exports[export.ID]; exists {
+		return model.Export{}, database.ErrDuplicateKey
+	}
+	s.exports[export.ID] = export
+	return export, nil
+}
+
+func (s *ExportStore) UpdateExportStatus(ctx context.Context, id primitive.ObjectID, status string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, ok := s.exports[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	export.Status, export.Key = status, key
+	s.exports[id] = export
+	return nil
+}
+
+func (s *ExportStore) GetLatestExport(ctx context.Context, userId primitive.ObjectID) (model.Export, error) {
+	exports := s.find(func(export model.Export) bool { return export.UserID == userId })
+	if len(exports) == 0 {
+		return model.Export{}, database.ErrNotFound
+	}
+	latest := exports[0]
+	for _, export := range exports[1:] {
+		if export.CreatedAt.After(latest.CreatedAt) {
+			latest = export
+		}
+	}
+	return latest, nil
+}
+
+func (s *ExportStore) GetExpiredExports(ctx context.Context, now time.Time) ([]model.Export, error) {
+	return s.find(func(export model.Export) bool { return !export.ExpiresAt.After(now) }), nil
+}
+
+func (s *ExportStore) GetExportsByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.Export, error) {
+	return s.find(func(export model.Export) bool { return export.UserID == userId }), nil
+}
+
+func (s *ExportStore) DeleteExport(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.exports, id)
+	return nil
+}
+
+func (s *ExportStore) find(matches func(model.Export) bool) []model.Export {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exports := []model.Export{}
+	for _, export := range s.exports {
+		if matches(export) {
+			exports = append(exports, export)
+		}
+	}
+	sortById(exports, func(export model.Export) primitive.ObjectID { return export.ID }, false)
+	return exports
+}
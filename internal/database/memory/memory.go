@@ -0,0 +1,41 @@
+// Package memory implements the repositories of package database in memory, for tests. It mirrors what the
+// mongo stores do, unique indexes and conditional updates included.
+package memory
+
+import (
+	"bytes"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newId assigns an id to documents that don't have one, like the driver does on insert.
+func newId(id primitive.ObjectID) primitive.ObjectID {
+	if id.IsZero() {
+		return primitive.NewObjectID()
+	}
+	return id
+}
+
+func sortById[T any](items []T, id func(T) primitive.ObjectID, descending bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := id(items[i]), id(items[j])
+		compared := bytes.Compare(a[:], b[:])
+		if descending {
+			return compared > 0
+		}
+		return compared < 0
+	})
+}
+
+// page applies skip and limit like mongo, a limit of 0 means no limit.
+func page[T any](items []T, limit int64, skip int64) []T {
+	if skip >= int64(len(items)) {
+		return []T{}
+	}
+	items = items[skip:]
+	if limit > 0 && limit < int64(len(items)) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"resume-service/internal/database"
+	"resume-service/internal/database/storetest"
+	"testing"
+)
+
+func TestUserStore(t *testing.T) {
+	storetest.UserRepository(t, func(t *testing.T) database.UserRepository { return NewUserStore() })
+}
+
+func TestResumeStore(t *testing.T) {
+	storetest.ResumeRepository(t, func(t *testing.T) database.ResumeRepository { return NewResumeStore() })
+}
+
+func TestSessionStore(t *testing.T) {
+	storetest.SessionRepository(t, func(t *testing.T) database.SessionRepository { return NewSessionStore() })
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	storetest.APIKeyRepository(t, func(t *testing.T) database.APIKeyRepository { return NewAPIKeyStore() })
+}
+
+func TestExportStore(t *testing.T) {
+	storetest.ExportRepository(t, func(t *testing.T) database.ExportRepository { return NewExportStore() })
+}
+
+func TestAuditStore(t *testing.T) {
+	storetest.AuditRepository(t, func(t *testing.T) database.AuditRepository { return NewAuditStore() })
+}
+
+func TestOAuthStateStore(t *testing.T) {
+	storetest.OAuthStateRepository(t, func(t *testing.T) database.OAuthStateRepository { return NewOAuthStateStore() })
+}
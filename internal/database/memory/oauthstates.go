@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"sync"
+	"time"
+)
+
+type OAuthStateStore struct {
+	mu     sync.Mutex
+	states map[string]model.OAuthState
+}
+
+func NewOAuthStateStore() *OAuthStateStore {
+	return &OAuthStateStore{states: map[string]model.OAuthState{}}
+}
+
+var _ database.OAuthStateRepository = (*OAuthStateStore)(nil)
+
+func (s *OAuthStateStore) StoreState(ctx context.Context, state model.OAuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.states[state.ID]; exists {
+		return database.ErrDuplicateKey
+	}
+	s.states[state.ID] = state
+	return nil
+}
+
+func (s *OAuthStateStore) ConsumeState(ctx context.Context, id string, provider string) (model.OAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	if !ok || state.Provider != provider || !state.ExpiresAt.After(time.Now()) {
+		return model.OAuthState{}, database.ErrNotFound
+	}
+	delete(s.states, id)
+	return state, nil
+}
@@ -0,0 +1,627 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ResumeStore struct {
+	mu               sync.Mutex
+	resumes          map[primitive.ObjectID]model.Resume
+	temporaryResumes map[primitive.ObjectID]model.TemporaryResume
+	// versions are the previous versions by resume, oldest first
+	versions   map[primitive.ObjectID][]model.ResumeVersion
+	shareLinks map[primitive.ObjectID]model.ShareLink
+	views      []model.ResumeView
+}
+
+func NewResumeStore() *ResumeStore {
+	return &ResumeStore{
+		resumes:          map[primitive.ObjectID]model.Resume{},
+		temporaryResumes: map[primitive.ObjectID]model.TemporaryResume{},
+		versions:         map[primitive.ObjectID][]model.ResumeVersion{},
+		shareLinks:       map[primitive.ObjectID]model.ShareLink{},
+	}
+}
+
+var _ database.ResumeRepository = (*ResumeStore)(nil)
+
+func (s *ResumeStore) StoreResume(ctx context.Context, resume model.Resume) (model.Resume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resume = copyResume(resume)
+	resume.ID = newId(resume.ID)
+	if resume.Version == 0 {
+		resume.Version = 1
+	}
+	if _, exists := s.resumes[resume.ID]; exists {
+		return model.Resume{}, database.ErrDuplicateKey
+	}
+	for _, other := range s.resumes {
+		if other.Key == resume.Key {
+			return model.Resume{}, database.ErrDuplicateKey
+		}
+	}
+	s.resumes[resume.ID] = resume
+	return copyResume(resume), nil
+}
+
+func (s *ResumeStore) GetResume(ctx context.Context, id string) (model.Resume, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Resume{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resume, ok := s.resumes[objectId]
+	if !ok {
+		return model.Resume{}, database.ErrNotFound
+	}
+	return copyResume(resume), nil
+}
+
+func (s *ResumeStore) GetResumesByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.Resume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resumes := []model.Resume{}
+	for _, resume := range s.resumes {
+		if resume.UserID == userId {
+			resumes = append(resumes, copyResume(resume))
+		}
+	}
+	sortById(resumes, func(resume model.Resume) primitive.ObjectID { return resume.ID }, false)
+	return resumes, nil
+}
+
+func (s *ResumeStore) ListResumes(ctx context.Context, userId primitive.ObjectID, query database.ResumeQuery) (database.ResumePage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resumes := []model.Resume{}
+	for _, resume := range s.resumes {
+		if resume.UserID == userId && matchesQuery(resume, query) {
+			resume = copyResume(resume)
+			resume.Content = ""
+			resumes = append(resumes, resume)
+		}
+	}
+	sort.Slice(resumes, func(i, j int) bool {
+		compared := compareResumes(resumes[i], database.CursorOf(resumes[j]), query.Sort)
+		if query.Descending {
+			return compared > 0
+		}
+		return compared < 0
+	})
+
+	page := database.ResumePage{Resumes: resumes}
+	if query.Limit > 0 && int64(len(resumes)) > query.Limit {
+		page.Resumes = resumes[:query.Limit]
+		page.Next = database.CursorOf(page.Resumes[query.Limit-1])
+	}
+	return page, nil
+}
+
+func matchesQuery(resume model.Resume, query database.ResumeQuery) bool {
+	for _, tag := range query.Tags {
+		if !slices.Contains(resume.Tags, tag) {
+			return false
+		}
+	}
+	if query.Public != nil && resume.Public != *query.Public {
+		return false
+	}
+	if !query.UploadedAfter.IsZero() && resume.UploadDate.Before(query.UploadedAfter) {
+		return false
+	}
+	if !query.UploadedBefore.IsZero() && !resume.UploadDate.Before(query.UploadedBefore) {
+		return false
+	}
+	if query.Search != "" && !matchesSearch(strings.Join([]string{resume.Title, resume.Description, resume.FileName, resume.Content}, " "), query.Search) {
+		return false
+	}
+	if query.After != nil {
+		compared := compareResumes(resume, query.After, query.Sort)
+		if query.Descending {
+			return compared < 0
+		}
+		return compared > 0
+	}
+	return true
+}
+
+// compareResumes orders by the sort field, then by id like the mongo store.
+func compareResumes(resume model.Resume, other *database.ResumeCursor, by database.ResumeSort) int {
+	compared := 0
+	if by == database.SortByFileName {
+		compared = strings.Compare(resume.FileName, other.FileName)
+	} else {
+		compared = resume.UploadDate.Compare(other.UploadDate)
+	}
+	if compared != 0 {
+		return compared
+	}
+	return bytes.Compare(resume.ID[:], other.ID[:])
+}
+
+// matchesSearch is a rough take on a mongo text search: any of the words, ignoring case and without stemming.
+func matchesSearch(text, search string) bool {
+	words := map[string]bool{}
+	for _, word := range splitWords(text) {
+		words[word] = true
+	}
+	for _, word := range splitWords(search) {
+		if words[word] {
+			return true
+		}
+	}
+	return false
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+func (s *ResumeStore) UpdateUserResumeIsPublic(ctx context.Context, userId primitive.ObjectID, id string, isPublic bool) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resume, ok := s.resumes[objectId]
+	if !ok || resume.UserID != userId {
+		return database.ErrNotFound
+	}
+	resume.Public = isPublic
+	s.resumes[objectId] = resume
+	return nil
+}
+
+func (s *ResumeStore) UpdateResume(ctx context.Context, userId primitive.ObjectID, id string, update database.ResumeUpdate) (model.Resume, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Resume{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resume, ok := s.resumes[objectId]
+	if !ok || resume.UserID != userId {
+		return model.Resume{}, database.ErrNotFound
+	}
+	if update.FileName != nil {
+		resume.FileName = *update.FileName
+	}
+	if update.Title != nil {
+		resume.Title = *update.Title
+	}
+	if update.Description != nil {
+		resume.Description = *update.Description
+	}
+	if update.Tags != nil {
+		resume.Tags = slices.Clone(update.Tags)
+	}
+	if update.Public != nil {
+		resume.Public = *update.Public
+	}
+	s.resumes[objectId] = resume
+
+	resume = copyResume(resume)
+	resume.Content = ""
+	return resume, nil
+}
+
+func (s *ResumeStore) AddResumeVersion(ctx context.Context, userId primitive.ObjectID, id string, file model.ResumeVersion) (model.Resume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resume, err := s.ownResume(userId, id)
+	if err != nil {
+		return model.Resume{}, err
+	}
+
+	previous := database.CurrentVersion(resume)
+	previous.ID = primitive.NewObjectID()
+	previous.Current = false
+	s.versions[resume.ID] = append(s.versions[resume.ID], previous)
+
+	resume.FileName = file.FileName
+	resume.Key = file.Key
+	resume.UploadDate = file.UploadDate
+	resume.Content = file.Content
+	resume.Version++
+	s.resumes[resume.ID] = resume
+
+	resume = copyResume(resume)
+	resume.Content = ""
+	return resume, nil
+}
+
+func (s *ResumeStore) GetResumeVersion(ctx context.Context, userId primitive.ObjectID, id string, version int) (model.ResumeVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resume, err := s.ownResume(userId, id)
+	if err != nil {
+		return model.ResumeVersion{}, err
+	}
+	if version == resume.Version {
+		return database.CurrentVersion(resume), nil
+	}
+	for _, previous := range s.versions[resume.ID] {
+		if previous.Version == version {
+			return previous, nil
+		}
+	}
+	return model.ResumeVersion{}, database.ErrNotFound
+}
+
+func (s *ResumeStore) ListResumeVersions(ctx context.Context, userId primitive.ObjectID, id string) ([]model.ResumeVersion, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newestVersions(userId, objectId), nil
+}
+
+func (s *ResumeStore) PruneResumeVersions(ctx context.Context, userId primitive.ObjectID, id string, keep int) ([]model.ResumeVersion, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := s.newestVersions(userId, objectId)
+	if keep >= len(versions) {
+		return []model.ResumeVersion{}, nil
+	}
+	pruned := versions[keep:]
+	s.versions[objectId] = slices.DeleteFunc(s.versions[objectId], func(version model.ResumeVersion) bool {
+		return slices.ContainsFunc(pruned, func(p model.ResumeVersion) bool { return p.ID == version.ID })
+	})
+	return pruned, nil
+}
+
+// ownResume returns the resume if the user owns it, the caller holds the lock.
+func (s *ResumeStore) ownResume(userId primitive.ObjectID, id string) (model.Resume, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Resume{}, err
+	}
+	resume, ok := s.resumes[objectId]
+	if !ok || resume.UserID != userId {
+		return model.Resume{}, database.ErrNotFound
+	}
+	return resume, nil
+}
+
+// newestVersions returns copies of the previous versions without their content, the caller holds the lock.
+func (s *ResumeStore) newestVersions(userId, resumeId primitive.ObjectID) []model.ResumeVersion {
+	current, exists := s.resumes[resumeId]
+	versions := []model.ResumeVersion{}
+	for _, version := range s.versions[resumeId] {
+		// like mongo, versions of the current file are never previous ones
+		if exists && (version.Version >= current.Version || version.Key == current.Key) {
+			continue
+		}
+		if version.UserID == userId {
+			version.Content = ""
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions
+}
+
+func (s *ResumeStore) CreateShareLink(ctx context.Context, link model.ShareLink) (model.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.shareLinks {
+		if other.TokenHash == link.TokenHash {
+			return model.ShareLink{}, database.ErrDuplicateKey
+		}
+	}
+	link.ID = primitive.NewObjectID()
+	s.shareLinks[link.ID] = copyShareLink(link)
+	return link, nil
+}
+
+func (s *ResumeStore) GetShareLinkByHash(ctx context.Context, tokenHash string) (model.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, link := range s.shareLinks {
+		if link.TokenHash == tokenHash && !link.Revoked {
+			return copyShareLink(link), nil
+		}
+	}
+	return model.ShareLink{}, database.ErrNotFound
+}
+
+func (s *ResumeStore) UseShareLink(ctx context.Context, id primitive.ObjectID, now time.Time) (model.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.shareLinks[id]
+	if !ok || !database.ShareLinkActive(link, now) {
+		return model.ShareLink{}, database.ErrNotFound
+	}
+	link.Downloads++
+	s.shareLinks[id] = link
+	return copyShareLink(link), nil
+}
+
+func (s *ResumeStore) ListShareLinks(ctx context.Context, userId primitive.ObjectID, resumeId string, now time.Time) ([]model.ShareLink, error) {
+	var resumeObjectId primitive.ObjectID
+	if resumeId != "" {
+		var err error
+		if resumeObjectId, err = primitive.ObjectIDFromHex(resumeId); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links := []model.ShareLink{}
+	for _, link := range s.shareLinks {
+		if link.UserID == userId && database.ShareLinkActive(link, now) && (resumeId == "" || link.ResumeID == resumeObjectId) {
+			links = append(links, copyShareLink(link))
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if !links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].CreatedAt.After(links[j].CreatedAt)
+		}
+		return bytes.Compare(links[i].ID[:], links[j].ID[:]) > 0
+	})
+	return links, nil
+}
+
+func (s *ResumeStore) RevokeShareLink(ctx context.Context, userId primitive.ObjectID, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.shareLinks[objectId]
+	if !ok || link.UserID != userId || link.Revoked {
+		return database.ErrNotFound
+	}
+	link.Revoked = true
+	s.shareLinks[objectId] = link
+	return nil
+}
+
+func (s *ResumeStore) RecordResumeView(ctx context.Context, view model.ResumeView) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	view.ID = primitive.NewObjectID()
+	s.views = append(s.views, view)
+	return nil
+}
+
+func (s *ResumeStore) ResumeAnalytics(ctx context.Context, userId primitive.ObjectID, resumeId string, since time.Time) (database.ResumeAnalytics, error) {
+	objectId, err := primitive.ObjectIDFromHex(resumeId)
+	if err != nil {
+		return database.ResumeAnalytics{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	analytics := database.ResumeAnalytics{Days: []database.DayViews{}, Clients: map[string]int64{}}
+	viewers := map[string]bool{}
+	dayViewers := map[string]map[string]bool{}
+	dayViews := map[string]int64{}
+	for _, view := range s.views {
+		if view.UserID != userId || view.ResumeID != objectId || view.Time.Before(since) {
+			continue
+		}
+		analytics.Views++
+		viewers[view.ViewerHash] = true
+		analytics.Clients[view.Client]++
+		day := view.Time.UTC().Format(database.DayFormat)
+		if dayViewers[day] == nil {
+			dayViewers[day] = map[string]bool{}
+		}
+		dayViewers[day][view.ViewerHash] = true
+		dayViews[day]++
+	}
+	analytics.UniqueViewers = int64(len(viewers))
+	for day, views := range dayViews {
+		analytics.Days = append(analytics.Days, database.DayViews{Date: day, Views: views, UniqueViewers: int64(len(dayViewers[day]))})
+	}
+	sort.Slice(analytics.Days, func(i, j int) bool { return analytics.Days[i].Date < analytics.Days[j].Date })
+	return analytics, nil
+}
+
+// copyShareLink keeps callers from changing the stored expiry through the pointer.
+func copyShareLink(link model.ShareLink) model.ShareLink {
+	if link.ExpiresAt != nil {
+		expiresAt := *link.ExpiresAt
+		link.ExpiresAt = &expiresAt
+	}
+	return link
+}
+
+func (s *ResumeStore) ListTags(ctx context.Context, userId primitive.ObjectID) ([]database.TagCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int64{}
+	for _, resume := range s.resumes {
+		if resume.UserID == userId {
+			for _, tag := range resume.Tags {
+				counts[tag]++
+			}
+		}
+	}
+	tags := []database.TagCount{}
+	for tag, count := range counts {
+		tags = append(tags, database.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+func (s *ResumeStore) RenameTag(ctx context.Context, userId primitive.ObjectID, from, to string) (int64, error) {
+	if from == to {
+		return 0, nil
+	}
+	return s.updateTags(userId, from, func(tags []string) []string {
+		if slices.Contains(tags, to) {
+			return slices.DeleteFunc(tags, func(tag string) bool { return tag == from })
+		}
+		tags[slices.Index(tags, from)] = to
+		return tags
+	})
+}
+
+func (s *ResumeStore) DeleteTag(ctx context.Context, userId primitive.ObjectID, tag string) (int64, error) {
+	return s.updateTags(userId, tag, func(tags []string) []string {
+		return slices.DeleteFunc(tags, func(existing string) bool { return existing == tag })
+	})
+}
+
+// updateTags changes the tags of the user's resumes that have tag, returning how many it changed.
+func (s *ResumeStore) updateTags(userId primitive.ObjectID, tag string, change func(tags []string) []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed int64
+	for id, resume := range s.resumes {
+		if resume.UserID != userId || !slices.Contains(resume.Tags, tag) {
+			continue
+		}
+		resume.Tags = change(slices.Clone(resume.Tags))
+		s.resumes[id] = resume
+		changed++
+	}
+	return changed, nil
+}
+
+func (s *ResumeStore) DeleteResume(ctx context.Context, userId primitive.ObjectID, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resume, ok := s.resumes[objectId]; ok && resume.UserID == userId {
+		delete(s.resumes, objectId)
+		delete(s.versions, objectId)
+	}
+	for id, link := range s.shareLinks {
+		if link.ResumeID == objectId && link.UserID == userId {
+			delete(s.shareLinks, id)
+		}
+	}
+	s.views = slices.DeleteFunc(s.views, func(view model.ResumeView) bool {
+		return view.ResumeID == objectId && view.UserID == userId
+	})
+	return nil
+}
+
+func (s *ResumeStore) DeleteResumesByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, resume := range s.resumes {
+		if resume.UserID == userId {
+			delete(s.resumes, id)
+			delete(s.versions, id)
+		}
+	}
+	for id, link := range s.shareLinks {
+		if link.UserID == userId {
+			delete(s.shareLinks, id)
+		}
+	}
+	s.views = slices.DeleteFunc(s.views, func(view model.ResumeView) bool { return view.UserID == userId })
+	return nil
+}
+
+func (s *ResumeStore) GetResumesWithoutContent(ctx context.Context, after primitive.ObjectID, limit int64) ([]model.Resume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resumes := []model.Resume{}
+	for _, resume := range s.resumes {
+		if resume.Content == "" && bytes.Compare(resume.ID[:], after[:]) > 0 {
+			resumes = append(resumes, copyResume(resume))
+		}
+	}
+	sortById(resumes, func(resume model.Resume) primitive.ObjectID { return resume.ID }, false)
+	return page(resumes, limit, 0), nil
+}
+
+func (s *ResumeStore) SetResumeContent(ctx context.Context, id primitive.ObjectID, key string, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resume, ok := s.resumes[id]
+	if !ok || resume.Key != key {
+		return database.ErrNotFound
+	}
+	resume.Content = content
+	s.resumes[id] = resume
+	return nil
+}
+
+func (s *ResumeStore) CountResumes(ctx context.Context) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.resumes)), int64(len(s.temporaryResumes)), nil
+}
+
+func (s *ResumeStore) StoreTemporaryResume(ctx context.Context, resume model.TemporaryResume) (model.TemporaryResume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resume.ID = newId(resume.ID)
+	if _, exists := s.temporaryResumes[resume.ID]; exists {
+		return model.TemporaryResume{}, database.ErrDuplicateKey
+	}
+	s.temporaryResumes[resume.ID] = resume
+	return resume, nil
+}
+
+func (s *ResumeStore) GetTemporaryResume(ctx context.Context, id string) (model.TemporaryResume, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.TemporaryResume{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resume, ok := s.temporaryResumes[objectId]
+	if !ok {
+		return model.TemporaryResume{}, database.ErrNotFound
+	}
+	return resume, nil
+}
+
+func copyResume(resume model.Resume) model.Resume {
+	resume.Tags = slices.Clone(resume.Tags)
+	return resume
+}
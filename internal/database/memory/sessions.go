@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[primitive.ObjectID]model.Session
+}
+
+func NewSessionStore() *SessionStore {
+	return &SessionStore{sessions: map[primitive.ObjectID]model.Session{}}
+}
+
+var _ database.SessionRepository = (*SessionStore)(nil)
+
+// updateAll applies change to every session that matches, like an UpdateMany.
+func (s *SessionStore) updateAll(matches func(model.Session) bool, change func(*model.Session)) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := 0
+	for id, session := range s.sessions {
+		if matches(session) {
+			change(&session)
+			s.sessions[id] = session
+			updated++
+		}
+	}
+	return updated
+}
+
+func (s *SessionStore) CreateSession(ctx context.Context, session model.Session) (model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.ID = newId(session.ID)
+	if _, exists := s.sessions[session.ID]; exists {
+		return model.Session{}, database.ErrDuplicateKey
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+func (s *SessionStore) GetSession(ctx context.Context, id primitive.ObjectID) (model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return model.Session{}, database.ErrNotFound
+	}
+	return session, nil
+}
+
+func (s *SessionStore) RotateRefreshToken(ctx context.Context, id primitive.ObjectID, currentHash, newHash string, expiresAt time.Time) error {
+	updated := s.updateAll(
+		func(session model.Session) bool {
+			return session.ID == id && session.RefreshTokenHash == currentHash && !session.Revoked
+		},
+		func(session *model.Session) {
+			session.UsedRefreshTokenHashes = append(slices.Clone(session.UsedRefreshTokenHashes), session.RefreshTokenHash)
+			session.RefreshTokenHash = newHash
+			session.ExpiresAt = expiresAt
+		},
+	)
+	if updated == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SessionStore) RevokeSession(ctx context.Context, id primitive.ObjectID) error {
+	s.updateAll(func(session model.Session) bool { return session.ID == id }, revoke)
+	return nil
+}
+
+func (s *SessionStore) RevokeUserSessions(ctx context.Context, userId primitive.ObjectID) error {
+	s.updateAll(func(session model.Session) bool { return session.UserID == userId }, revoke)
+	return nil
+}
+
+func (s *SessionStore) RevokeOtherUserSessions(ctx context.Context, userId primitive.ObjectID, keep primitive.ObjectID) error {
+	s.updateAll(func(session model.Session) bool { return session.UserID == userId && session.ID != keep }, revoke)
+	return nil
+}
+
+func (s *SessionStore) CountActiveSessions(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var active int64
+	for _, session := range s.sessions {
+		if !session.Revoked && session.ExpiresAt.After(now) {
+			active++
+		}
+	}
+	return active, nil
+}
+
+func (s *SessionStore) DeleteUserSessions(ctx context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userId {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func revoke(session *model.Session) {
+	session.Revoked = true
+}
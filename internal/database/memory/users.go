@@ -0,0 +1,349 @@
+package memory
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserStore struct {
+	mu    sync.Mutex
+	users map[primitive.ObjectID]model.User
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{users: map[primitive.ObjectID]model.User{}}
+}
+
+var _ database.UserRepository = (*UserStore)(nil)
+
+// update applies change to the user if it matches, like a FindOneAndUpdate. It returns copies of the user
+// before and after the change.
+func (s *UserStore) update(userId primitive.ObjectID, matches func(model.User) bool, change func(*model.User)) (model.User, model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok || (matches != nil && !matches(user)) {
+		return model.User{}, model.User{}, database.ErrNotFound
+	}
+	before, after := copyUser(user), copyUser(user)
+	change(&after)
+	if err := s.checkUnique(after); err != nil {
+		return model.User{}, model.User{}, err
+	}
+	s.users[userId] = after
+	return before, copyUser(after), nil
+}
+
+// checkUnique enforces the unique indexes on email and linked identities. The caller holds the lock.
+func (s *UserStore) checkUnique(user model.User) error {
+	for id, other := range s.users {
+		if id == user.ID {
+			continue
+		}
+		if other.Email == user.Email {
+			return database.ErrDuplicateKey
+		}
+		for _, identity := range user.Identities {
+			if slices.ContainsFunc(other.Identities, func(linked model.LinkedIdentity) bool {
+				return linked.Provider == identity.Provider && linked.Subject == identity.Subject
+			}) {
+				return database.ErrDuplicateKey
+			}
+		}
+	}
+	return nil
+}
+
+func (s *UserStore) find(matches func(model.User) bool) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if matches(user) {
+			return copyUser(user), nil
+		}
+	}
+	return model.User{}, database.ErrNotFound
+}
+
+func (s *UserStore) GetUser(ctx context.Context, userId primitive.ObjectID) (model.User, error) {
+	return s.find(func(user model.User) bool { return user.ID == userId })
+}
+
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	return s.find(func(user model.User) bool { return user.Email == email })
+}
+
+func (s *UserStore) GetUserByIdentity(ctx context.Context, provider string, subject string) (model.User, error) {
+	return s.find(func(user model.User) bool {
+		return slices.Contains(user.Identities, model.LinkedIdentity{Provider: provider, Subject: subject})
+	})
+}
+
+func (s *UserStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user = copyUser(user)
+	user.ID = newId(user.ID)
+	if _, exists := s.users[user.ID]; exists {
+		return model.User{}, database.ErrDuplicateKey
+	}
+	if err := s.checkUnique(user); err != nil {
+		return model.User{}, err
+	}
+	s.users[user.ID] = user
+	return copyUser(user), nil
+}
+
+func (s *UserStore) ResetEmailOTP(ctx context.Context, userId primitive.ObjectID, otp model.EmailOTP, previousIssuedAt time.Time) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool { return user.OTP.IssuedAt.Equal(previousIssuedAt) },
+		func(user *model.User) { user.OTP = otp },
+	)
+	return err
+}
+
+func (s *UserStore) RegisterOTPAttempt(ctx context.Context, userId primitive.ObjectID, maxAttempts int) (model.User, error) {
+	_, user, err := s.update(userId,
+		func(user model.User) bool { return user.OTP.Attempts < maxAttempts },
+		func(user *model.User) { user.OTP.Attempts++ },
+	)
+	return user, err
+}
+
+func (s *UserStore) VerifyEmail(ctx context.Context, userId primitive.ObjectID) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) {
+		user.EmailVerified = true
+		user.OTP = model.EmailOTP{}
+	})
+	return err
+}
+
+func (s *UserStore) LinkIdentity(ctx context.Context, userId primitive.ObjectID, identity model.LinkedIdentity) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) {
+		if !slices.Contains(user.Identities, identity) {
+			user.Identities = append(user.Identities, identity)
+		}
+		user.EmailVerified = true
+		user.OTP = model.EmailOTP{}
+	})
+	return err
+}
+
+func (s *UserStore) SetPasswordResetToken(ctx context.Context, userId primitive.ObjectID, tokenHash string, expiry time.Time) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) {
+		user.PasswordResetHash = tokenHash
+		user.PasswordResetExpiry = expiry
+	})
+	return err
+}
+
+func (s *UserStore) ResetPassword(ctx context.Context, tokenHash string, hashedPassword string) (model.User, error) {
+	user, err := s.find(func(user model.User) bool {
+		return user.PasswordResetHash == tokenHash && user.PasswordResetExpiry.After(time.Now())
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+	before, _, err := s.update(user.ID,
+		func(user model.User) bool { return user.PasswordResetHash == tokenHash },
+		func(user *model.User) {
+			user.Password = hashedPassword
+			user.PasswordResetHash = ""
+			user.PasswordResetExpiry = time.Time{}
+		},
+	)
+	return before, err
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, userId primitive.ObjectID, hashedPassword string) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.Password = hashedPassword })
+	return err
+}
+
+func (s *UserStore) SetPendingTwoFactor(ctx context.Context, userId primitive.ObjectID, secret string) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.TwoFactor.PendingSecret = secret })
+	return err
+}
+
+func (s *UserStore) EnableTwoFactor(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodeHashes []string, step int64) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool { return user.TwoFactor.PendingSecret == secret },
+		func(user *model.User) {
+			user.TwoFactor = model.TwoFactor{
+				Enabled:       true,
+				Secret:        secret,
+				RecoveryCodes: slices.Clone(recoveryCodeHashes),
+				LastUsedStep:  step,
+			}
+		},
+	)
+	return err
+}
+
+func (s *UserStore) DisableTwoFactor(ctx context.Context, userId primitive.ObjectID) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.TwoFactor = model.TwoFactor{} })
+	return err
+}
+
+func (s *UserStore) SetRecoveryCodes(ctx context.Context, userId primitive.ObjectID, recoveryCodeHashes []string) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool { return user.TwoFactor.Enabled },
+		func(user *model.User) { user.TwoFactor.RecoveryCodes = slices.Clone(recoveryCodeHashes) },
+	)
+	return err
+}
+
+func (s *UserStore) UseTOTPStep(ctx context.Context, userId primitive.ObjectID, step int64) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool { return user.TwoFactor.Enabled && user.TwoFactor.LastUsedStep < step },
+		func(user *model.User) { user.TwoFactor.LastUsedStep = step },
+	)
+	return err
+}
+
+func (s *UserStore) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool {
+			return user.TwoFactor.Enabled && slices.Contains(user.TwoFactor.RecoveryCodes, codeHash)
+		},
+		func(user *model.User) {
+			user.TwoFactor.RecoveryCodes = slices.DeleteFunc(user.TwoFactor.RecoveryCodes, func(code string) bool { return code == codeHash })
+		},
+	)
+	return err
+}
+
+func (s *UserStore) SearchUsers(ctx context.Context, query string, limit int64, skip int64) ([]model.User, int64, error) {
+	query = strings.ToLower(query)
+	users := s.filter(func(user model.User) bool {
+		return strings.Contains(strings.ToLower(user.Email), query) || strings.Contains(strings.ToLower(user.Name), query)
+	})
+	sortById(users, func(user model.User) primitive.ObjectID { return user.ID }, true)
+	return page(users, limit, skip), int64(len(users)), nil
+}
+
+func (s *UserStore) CountUsers(ctx context.Context) (int64, int64, error) {
+	all := s.filter(func(model.User) bool { return true })
+	verified := s.filter(func(user model.User) bool { return user.EmailVerified })
+	return int64(len(all)), int64(len(verified)), nil
+}
+
+func (s *UserStore) SetRole(ctx context.Context, userId primitive.ObjectID, role string) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.Role = role })
+	return err
+}
+
+func (s *UserStore) SetRoleByEmail(ctx context.Context, email string, role string) error {
+	user, err := s.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerified {
+		return database.ErrNotFound
+	}
+	return s.SetRole(ctx, user.ID, role)
+}
+
+func (s *UserStore) SetDisabled(ctx context.Context, userId primitive.ObjectID, disabled bool) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.Disabled = disabled })
+	return err
+}
+
+func (s *UserStore) UpdateProfile(ctx context.Context, userId primitive.ObjectID, name string) (model.User, error) {
+	_, user, err := s.update(userId, nil, func(user *model.User) { user.Name = name })
+	return user, err
+}
+
+func (s *UserStore) SetPendingEmail(ctx context.Context, userId primitive.ObjectID, pending model.PendingEmail) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.PendingEmail = &pending })
+	return err
+}
+
+func (s *UserStore) RegisterEmailChangeAttempt(ctx context.Context, userId primitive.ObjectID, maxAttempts int) (model.User, error) {
+	_, user, err := s.update(userId,
+		func(user model.User) bool {
+			return user.PendingEmail != nil && user.PendingEmail.OTP.Attempts < maxAttempts
+		},
+		func(user *model.User) { user.PendingEmail.OTP.Attempts++ },
+	)
+	return user, err
+}
+
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, userId primitive.ObjectID, email string) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool { return user.PendingEmail != nil && user.PendingEmail.Email == email },
+		func(user *model.User) {
+			user.Email = email
+			user.EmailVerified = true
+			user.PendingEmail = nil
+			user.OTP = model.EmailOTP{}
+		},
+	)
+	return err
+}
+
+func (s *UserStore) ScheduleDeletion(ctx context.Context, userId primitive.ObjectID, at time.Time) error {
+	_, _, err := s.update(userId, nil, func(user *model.User) { user.DeletionScheduledAt = &at })
+	return err
+}
+
+func (s *UserStore) CancelDeletion(ctx context.Context, userId primitive.ObjectID) error {
+	_, _, err := s.update(userId,
+		func(user model.User) bool { return user.DeletionScheduledAt != nil },
+		func(user *model.User) { user.DeletionScheduledAt = nil },
+	)
+	return err
+}
+
+func (s *UserStore) GetUsersDueForDeletion(ctx context.Context, now time.Time, limit int64) ([]model.User, error) {
+	users := s.filter(func(user model.User) bool {
+		return user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now)
+	})
+	sortById(users, func(user model.User) primitive.ObjectID { return user.ID }, false)
+	return page(users, limit, 0), nil
+}
+
+func (s *UserStore) DeleteUser(ctx context.Context, userId primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, userId)
+	return nil
+}
+
+func (s *UserStore) filter(matches func(model.User) bool) []model.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []model.User{}
+	for _, user := range s.users {
+		if matches(user) {
+			users = append(users, copyUser(user))
+		}
+	}
+	return users
+}
+
+// copyUser copies what the user refers to as well, so callers can't change stored users.
+func copyUser(user model.User) model.User {
+	user.Identities = slices.Clone(user.Identities)
+	user.TwoFactor.RecoveryCodes = slices.Clone(user.TwoFactor.RecoveryCodes)
+	if user.PendingEmail != nil {
+		pending := *user.PendingEmail
+		user.PendingEmail = &pending
+	}
+	if user.DeletionScheduledAt != nil {
+		at := *user.DeletionScheduledAt
+		user.DeletionScheduledAt = &at
+	}
+	return user
+}
@@ -0,0 +1,359 @@
+// Package migrate applies versioned schema changes, like indexes and document backfills, to a mongo database.
+// Applied versions are recorded in the schema_migrations collection, and a lease in schema_migrations_lock keeps
+// instances starting at the same time from applying a migration twice.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	recordCollection = "schema_migrations"
+	lockCollection   = "schema_migrations_lock"
+	lockID           = "lock"
+
+	// lockTTL bounds how long an instance that died mid migration blocks the others. The holder renews the lease
+	// every lockRenewInterval while it migrates, so migrations may take longer.
+	lockTTL           = 10 * time.Minute
+	lockRenewInterval = lockTTL / 5
+	lockRetryWait     = time.Second
+)
+
+// errLockLost cancels a migration whose lease couldn't be renewed, another instance may have taken the lock.
+var errLockLost = errors.New("migration lock lost")
+
+var (
+	// ErrSchemaTooNew means the database has migrations applied that this build doesn't know, it was migrated by
+	// a newer release and running against it could corrupt data.
+	ErrSchemaTooNew = errors.New("database schema is newer than this build")
+	// ErrPending means the database is missing migrations this build expects.
+	ErrPending = errors.New("database schema has pending migrations")
+	// ErrIrreversible is returned when a rollback reaches a migration without Down.
+	ErrIrreversible = errors.New("migration cannot be rolled back")
+)
+
+// Migration changes the schema from Version-1 to Version. A crash between applying and recording a migration
+// runs it again on the next attempt, so Up should be safe to repeat.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	// Down undoes Up, it is left nil when that is not possible.
+	Down func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status is a migration known to this build, or one only recorded in the database.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Unknown migrations were applied by a newer build.
+	Unknown bool
+}
+
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	// owner tells the lock holders apart
+	owner string
+}
+
+// New checks that migrations are in ascending order of version, without gaps in the numbering.
+func New(db *mongo.Database, migrations []Migration) (*Migrator, error) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %q has version %d, expected %d", migration.Name, migration.Version, i+1)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up", migration.Version)
+		}
+	}
+	host, _ := os.Hostname()
+	return &Migrator{db: db, migrations: migrations, owner: host + "/" + uuid.NewString()}, nil
+}
+
+// Latest is the version the schema has once every migration of this build is applied.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Status lists the known migrations, then any unknown ones the database has recorded.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range unknown(m.migrations, applied) {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, Status{Version: record.Version, Name: record.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	return statuses, nil
+}
+
+// Check fails with ErrSchemaTooNew or ErrPending unless the database is at exactly the latest version.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	missing, err := pending(m.migrations, applied, 0)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %d missing, the first is %d %q", ErrPending, len(missing), missing[0].Version, missing[0].Name)
+	}
+	return nil
+}
+
+// Up applies up to steps pending migrations in order, all of them when steps is 0.
+func (m *Migrator) Up(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(applied map[int]record) error {
+		migrations, err := pending(m.migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if err := m.apply(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return errors.New("steps must be at least 1")
+	}
+	return m.locked(ctx, func(applied map[int]record) error {
+		migrations, err := rollback(m.migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if err := m.revert(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	start := time.Now()
+	if err := migration.Up(ctx, m.db); err != nil {
+		return fmt.Errorf("apply migration %d %q: %w", migration.Version, migration.Name, err)
+	}
+	record := record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}
+	if _, err := m.db.Collection(recordCollection).InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+	slog.InfoContext(ctx, "Applied migration", "version", migration.Version, "name", migration.Name,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	start := time.Now()
+	if err := migration.Down(ctx, m.db); err != nil {
+		return fmt.Errorf("roll back migration %d %q: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := m.db.Collection(recordCollection).DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+		return fmt.Errorf("unrecord migration %d: %w", migration.Version, err)
+	}
+	slog.InfoContext(ctx, "Rolled back migration", "version", migration.Version, "name", migration.Name,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.db.Collection(recordCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// locked runs fn with the migration lock held, waiting for other instances until ctx is done. fn gets the
+// migrations applied by the time the lock was taken.
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int]record) error) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		m.renew(ctx, cancel)
+	}()
+	defer func() {
+		cancel(nil)
+		<-renewed
+	}()
+
+	applied, err := m.applied(ctx)
+	if err == nil {
+		err = fn(applied)
+	}
+	if err != nil && errors.Is(context.Cause(ctx), errLockLost) {
+		return fmt.Errorf("%w: %w", context.Cause(ctx), err)
+	}
+	return err
+}
+
+// renew extends the lease until ctx is done. It cancels ctx when the lease is gone, or about to expire because
+// renewing keeps failing.
+func (m *Migrator) renew(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+	expiresAt := time.Now().Add(lockTTL)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		result, err := m.db.Collection(lockCollection).UpdateOne(ctx,
+			bson.M{"_id": lockID, "owner": m.owner},
+			bson.M{"$set": bson.M{"expires_at": now.Add(lockTTL)}},
+		)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err == nil && result.MatchedCount == 0:
+			cancel(errLockLost)
+			return
+		case err == nil:
+			expiresAt = now.Add(lockTTL)
+		case now.Add(lockRenewInterval).After(expiresAt):
+			cancel(fmt.Errorf("%w: %w", errLockLost, err))
+			return
+		default:
+			slog.WarnContext(ctx, "Cannot renew the migration lock", "error", err)
+		}
+	}
+}
+
+func (m *Migrator) lock(ctx context.Context) error {
+	locks := m.db.Collection(lockCollection)
+	for {
+		now := time.Now()
+		// matches an expired lock, or inserts one. A held lock fails the insert on the _id.
+		_, err := locks.UpdateOne(ctx,
+			bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": m.owner, "acquired_at": now, "expires_at": now.Add(lockTTL)}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("take migration lock: %w", err)
+		}
+
+		slog.InfoContext(ctx, "Waiting for the migration lock")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("take migration lock: %w", ctx.Err())
+		case <-time.After(lockRetryWait):
+		}
+	}
+}
+
+func (m *Migrator) unlock() {
+	// released even when the migration's context is done, otherwise the others wait for the lease to expire
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := m.db.Collection(lockCollection).DeleteOne(ctx, bson.M{"_id": lockID, "owner": m.owner})
+	if err != nil {
+		slog.Error("Cannot release the migration lock", "error", err)
+	}
+}
+
+// pending returns up to steps known migrations that aren't applied, all of them when steps is 0.
+func pending(migrations []Migration, applied map[int]record, steps int) ([]Migration, error) {
+	if newer := unknown(migrations, applied); len(newer) > 0 {
+		return nil, fmt.Errorf("%w: migration %d %q is applied, this build knows up to %d",
+			ErrSchemaTooNew, newer[0].Version, newer[0].Name, len(migrations))
+	}
+	var missing []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			missing = append(missing, migration)
+		}
+	}
+	if steps > 0 && steps < len(missing) {
+		missing = missing[:steps]
+	}
+	return missing, nil
+}
+
+// rollback returns the last steps applied migrations, newest first.
+func rollback(migrations []Migration, applied map[int]record, steps int) ([]Migration, error) {
+	if newer := unknown(migrations, applied); len(newer) > 0 {
+		return nil, fmt.Errorf("%w: migration %d %q is applied, only the build that added it can roll it back",
+			ErrSchemaTooNew, newer[0].Version, newer[0].Name)
+	}
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if _, ok := applied[migrations[i].Version]; !ok {
+			continue
+		}
+		if migrations[i].Down == nil {
+			return nil, fmt.Errorf("%w: %d %q", ErrIrreversible, migrations[i].Version, migrations[i].Name)
+		}
+		reverted = append(reverted, migrations[i])
+	}
+	return reverted, nil
+}
+
+// unknown returns the applied migrations that aren't in migrations, by version.
+func unknown(migrations []Migration, applied map[int]record) []record {
+	var records []record
+	for version, record := range applied {
+		if version < 1 || version > len(migrations) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Version < records[j].Version })
+	return records
+}
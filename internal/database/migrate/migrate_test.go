@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func noop(context.Context, *mongo.Database) error { return nil }
+
+func testMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "one", Up: noop, Down: noop},
+		{Version: 2, Name: "two", Up: noop},
+		{Version: 3, Name: "three", Up: noop, Down: noop},
+	}
+}
+
+func versions(migrations []Migration) []int {
+	var versions []int
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func appliedUpTo(version int) map[int]record {
+	applied := map[int]record{}
+	for v := 1; v <= version; v++ {
+		applied[v] = record{Version: v}
+	}
+	return applied
+}
+
+func TestNewRejectsMisnumberedMigrations(t *testing.T) {
+	migrations := testMigrations()
+	migrations[1].Version = 3
+	if _, err := New(nil, migrations); err == nil {
+		t.Error("Expected an error for a gap in the versions")
+	}
+	if _, err := New(nil, []Migration{{Version: 1, Name: "one"}}); err == nil {
+		t.Error("Expected an error for a migration without Up")
+	}
+}
+
+func TestPending(t *testing.T) {
+	migrations := testMigrations()
+	tests := []struct {
+		name    string
+		applied map[int]record
+		steps   int
+		want    []int
+	}{
+		{"fresh database", appliedUpTo(0), 0, []int{1, 2, 3}},
+		{"one step", appliedUpTo(0), 1, []int{1}},
+		{"partly applied", appliedUpTo(1), 0, []int{2, 3}},
+		{"up to date", appliedUpTo(3), 0, nil},
+		{"gap", map[int]record{1: {Version: 1}, 3: {Version: 3}}, 0, []int{2}},
+	}
+	for _, test := range tests {
+		got, err := pending(migrations, test.applied, test.steps)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if gotVersions := versions(got); !equal(gotVersions, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, gotVersions)
+		}
+	}
+}
+
+func TestPendingRefusesNewerSchema(t *testing.T) {
+	_, err := pending(testMigrations(), appliedUpTo(4), 0)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	migrations := testMigrations()
+	got, err := rollback(migrations, appliedUpTo(3), 1)
+	if err != nil || !equal(versions(got), []int{3}) {
+		t.Errorf("Expected to roll back 3, got %v, %v", versions(got), err)
+	}
+
+	if _, err = rollback(migrations, appliedUpTo(3), 2); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Expected ErrIrreversible past a migration without Down, got %v", err)
+	}
+
+	got, err = rollback(migrations, map[int]record{1: {Version: 1}, 3: {Version: 3}}, 2)
+	if err != nil || !equal(versions(got), []int{3, 1}) {
+		t.Errorf("Expected to skip the unapplied migration, got %v, %v", versions(got), err)
+	}
+
+	if _, err = rollback(migrations, appliedUpTo(4), 1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
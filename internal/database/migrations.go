@@ -0,0 +1,292 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resume-service/internal/database/migrate"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrations are the schema changes of the stores, in order. A released migration is not edited anymore, later
+// changes go into a new one.
+var migrations = []migrate.Migration{
+	{Version: 1, Name: "create initial indexes", Up: createIndexes(initialIndexes), Down: dropIndexes(initialIndexes)},
+	{Version: 2, Name: "index resume listing", Up: createIndexes(resumeListIndexes), Down: dropIndexes(resumeListIndexes)},
+	{Version: 3, Name: "search resume titles", Up: replaceIndexes(resumeListIndexes, resumeSearchIndexes), Down: replaceIndexes(resumeSearchIndexes, resumeListIndexes)},
+	{Version: 4, Name: "lower case resume tags", Up: lowerCaseTags, Down: noChange},
+	{Version: 5, Name: "resume versions", Up: addResumeVersions, Down: dropIndexes(resumeVersionIndexes)},
+	{Version: 6, Name: "resume share links", Up: createIndexes(shareLinkIndexes), Down: dropIndexes(shareLinkIndexes)},
+	{Version: 7, Name: "resume views", Up: createIndexes(resumeViewIndexes), Down: dropIndexes(resumeViewIndexes)},
+}
+
+// Migrator applies the migrations to the store's database.
+func (db *DB) Migrator() (*migrate.Migrator, error) {
+	return migrate.New(db.client.Database(db.name), migrations)
+}
+
+// initialIndexes were created by the stores on every startup before there were migrations. Creating an index
+// that already exists with the same keys and options is a no-op, so databases from back then migrate cleanly.
+var initialIndexes = map[string][]mongo.IndexModel{
+	userCollection: {
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "password_reset_hash", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "deletion_scheduled_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	},
+	resumeCollection: {
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	},
+	sessionCollection: {
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+		{
+			// expired sessions are removed by mongo
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	},
+	oauthStateCollection: {
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	},
+	apiKeyCollection: {
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	},
+	auditCollection: {
+		{
+			Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "time", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "target_user_id", Value: 1}, {Key: "time", Value: -1}},
+		},
+	},
+	exportCollection: {
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "expires_at", Value: 1}},
+		},
+	},
+	rateLimitCollection: {
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	},
+}
+
+// resumeListIndexes back the sorts, the tag filter and the search of ResumeStore.ListResumes.
+var resumeListIndexes = map[string][]mongo.IndexModel{
+	resumeCollection: {
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "upload_date", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "file_name", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "tags", Value: 1}},
+		},
+		{
+			// searches always name the user, which lets the text index start with it
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "file_name", Value: "text"}, {Key: "content", Value: "text"}},
+		},
+	},
+}
+
+// resumeSearchIndexes adds the title and description to the search. A collection has only one text index, so
+// it replaces the one of resumeListIndexes.
+var resumeSearchIndexes = map[string][]mongo.IndexModel{
+	resumeCollection: {
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "title", Value: "text"},
+				{Key: "description", Value: "text"},
+				{Key: "file_name", Value: "text"},
+				{Key: "content", Value: "text"},
+			},
+		},
+	},
+}
+
+func createIndexes(indexes map[string][]mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for collection, models := range indexes {
+			if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+				return fmt.Errorf("create %s indexes: %w", collection, err)
+			}
+		}
+		return nil
+	}
+}
+
+func dropIndexes(indexes map[string][]mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for collection, models := range indexes {
+			for _, model := range models {
+				_, err := db.Collection(collection).Indexes().DropOne(ctx, indexName(model.Keys.(bson.D)))
+				if err != nil && !isIndexNotFound(err) {
+					return fmt.Errorf("drop %s indexes: %w", collection, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// replaceIndexes drops the text indexes of old before creating new, other indexes of old are kept.
+func replaceIndexes(old, new map[string][]mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if err := dropIndexes(textIndexes(old))(ctx, db); err != nil {
+			return err
+		}
+		return createIndexes(new)(ctx, db)
+	}
+}
+
+func textIndexes(indexes map[string][]mongo.IndexModel) map[string][]mongo.IndexModel {
+	text := map[string][]mongo.IndexModel{}
+	for collection, models := range indexes {
+		for _, model := range models {
+			for _, key := range model.Keys.(bson.D) {
+				if key.Value == "text" {
+					text[collection] = append(text[collection], model)
+					break
+				}
+			}
+		}
+	}
+	return text
+}
+
+// lowerCaseTags brings the tags stored before tags were normalized in line, merging the ones that only differed
+// in case.
+func lowerCaseTags(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(resumeCollection).UpdateMany(ctx,
+		bson.M{"tags.0": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			// drops the tags that were only different by case, keeping the first in place
+			"tags": bson.M{"$reduce": bson.M{
+				"input":        bson.M{"$map": bson.M{"input": "$tags", "in": bson.M{"$toLower": "$$this"}}},
+				"initialValue": bson.A{},
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$in": bson.A{"$$this", "$$value"}},
+					"$$value",
+					bson.M{"$concatArrays": bson.A{"$$value", bson.A{"$$this"}}},
+				}},
+			}},
+		}}}},
+	)
+	return err
+}
+
+var resumeVersionIndexes = map[string][]mongo.IndexModel{
+	resumeVersionCollection: {
+		{
+			Keys:    bson.D{{Key: "resume_id", Value: 1}, {Key: "version", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	},
+}
+
+var shareLinkIndexes = map[string][]mongo.IndexModel{
+	shareLinkCollection: {
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "resume_id", Value: 1}},
+		},
+	},
+}
+
+// resumeViewRetention is how long views are kept for analytics.
+const resumeViewRetention = 400 * 24 * time.Hour
+
+var resumeViewIndexes = map[string][]mongo.IndexModel{
+	resumeViewCollection: {
+		{
+			Keys: bson.D{{Key: "resume_id", Value: 1}, {Key: "time", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+		{
+			// old views are removed by mongo
+			Keys:    bson.D{{Key: "time", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(resumeViewRetention.Seconds())),
+		},
+	},
+}
+
+// addResumeVersions numbers the files resumes have from before versions as their first. Rolling back leaves the
+// numbers, older builds ignore them.
+func addResumeVersions(ctx context.Context, db *mongo.Database) error {
+	if err := createIndexes(resumeVersionIndexes)(ctx, db); err != nil {
+		return err
+	}
+	_, err := db.Collection(resumeCollection).UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}},
+	)
+	return err
+}
+
+// noChange is the Down of migrations whose changes older builds handle as they are.
+func noChange(context.Context, *mongo.Database) error {
+	return nil
+}
+
+// indexName is the name mongo gives an index unless told otherwise, like "actor_id_1_time_-1".
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func isIndexNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	// IndexNotFound, or NamespaceNotFound when the collection was never created
+	return errors.As(err, &commandErr) && (commandErr.Code == 27 || commandErr.Code == 26)
+}
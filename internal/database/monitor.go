@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"resume-service/internal/logging"
+	"resume-service/internal/metrics"
+	"resume-service/internal/tracing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slowCommand is how long a command may take before it is logged as slow.
+const slowCommand = 500 * time.Millisecond
+
+// commandMonitor logs mongo commands with the logger of the request that issued them. Commands and replies
+// are left out, they hold user data.
+func commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			duration := time.Duration(evt.DurationNanos)
+			level := slog.LevelDebug
+			if duration >= slowCommand {
+				level = slog.LevelWarn
+			}
+			logging.FromContext(ctx).Log(ctx, level, "mongo command",
+				slog.String("command", evt.CommandName),
+				slog.Int64("duration_ms", duration.Milliseconds()),
+			)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			metrics.DBCommandErrors.WithLabelValues(evt.CommandName).Inc()
+			trace.SpanFromContext(ctx).SetStatus(codes.Error, evt.CommandName+": "+evt.Failure)
+			logging.FromContext(ctx).WarnContext(ctx, "mongo command failed",
+				slog.String("command", evt.CommandName),
+				slog.Int64("duration_ms", time.Duration(evt.DurationNanos).Milliseconds()),
+				slog.String("error", evt.Failure),
+			)
+		},
+	}
+}
+
+// instrument traces a store method and records its latency, the returned func has to be called once it's done.
+// Failed commands mark the span through the command monitor.
+func instrument(ctx context.Context, store string, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "db "+store+"."+method, semconv.DBSystemMongoDB, semconv.DBOperation(method))
+	return ctx, func() {
+		metrics.DBDuration.WithLabelValues(store, method).Observe(metrics.Since(start))
+		span.End()
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"resume-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type OAuthStateStore struct {
+	collection *mongo.Collection
+}
+
+const oauthStateCollection = "oauth_states"
+
+func newOAuthStateStore(dbClient *mongo.Database) OAuthStateStore {
+	return OAuthStateStore{collection: dbClient.Collection(oauthStateCollection)}
+}
+
+func (s *OAuthStateStore) StoreState(ctx context.Context, state model.OAuthState) error {
+	ctx, done := instrument(ctx, "oauth_state", "StoreState")
+	defer done()
+
+	_, err := s.collection.InsertOne(ctx, state)
+	return err
+}
+
+// ConsumeState returns and deletes an unexpired state, so every state can only be used once.
+func (s *OAuthStateStore) ConsumeState(ctx context.Context, id string, provider string) (model.OAuthState, error) {
+	ctx, done := instrument(ctx, "oauth_state", "ConsumeState")
+	defer done()
+
+	state := &model.OAuthState{}
+	err := s.collection.FindOneAndDelete(
+		ctx,
+		bson.M{"_id": id, "provider": provider, "expires_at": bson.M{"$gt": time.Now()}},
+	).Decode(state)
+	if err != nil {
+		return model.OAuthState{}, err
+	}
+	return *state, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"resume-service/internal/ratelimit"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RateLimitStore shares rate limit state between instances, see ratelimit.Store.
+type RateLimitStore struct {
+	collection *mongo.Collection
+}
+
+type rateLimitDocument struct {
+	Key             string `bson:"_id"`
+	Version         int64  `bson:"version"`
+	ratelimit.State `bson:",inline"`
+}
+
+const rateLimitCollection = "rate_limits"
+
+func newRateLimitStore(dbClient *mongo.Database) RateLimitStore {
+	return RateLimitStore{collection: dbClient.Collection(rateLimitCollection)}
+}
+
+func (s *RateLimitStore) Get(ctx context.Context, key string) (ratelimit.State, int64, error) {
+	ctx, done := instrument(ctx, "rate_limit", "Get")
+	defer done()
+
+	document := &rateLimitDocument{}
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(document)
+	if err != nil {
+		if IsNotFound(err) {
+			return ratelimit.State{}, 0, nil
+		}
+		return ratelimit.State{}, 0, err
+	}
+	if time.Now().After(document.ExpiresAt) {
+		// the ttl index removes expired documents eventually, until then they are swapped like missing ones
+		return ratelimit.State{}, document.Version, nil
+	}
+	return document.State, document.Version, nil
+}
+
+func (s *RateLimitStore) Swap(ctx context.Context, key string, version int64, state ratelimit.State) (bool, error) {
+	ctx, done := instrument(ctx, "rate_limit", "Swap")
+	defer done()
+
+	if version == 0 {
+		_, err := s.collection.InsertOne(ctx, rateLimitDocument{Key: key, Version: 1, State: state})
+		if IsDuplicateKey(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	result, err := s.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": key, "version": version},
+		rateLimitDocument{Key: key, Version: version + 1, State: state},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (s *RateLimitStore) Delete(ctx context.Context, key string) error {
+	ctx, done := instrument(ctx, "rate_limit", "Delete")
+	defer done()
+
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"resume-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when no document matches, including by updates whose conditions don't hold.
+var ErrNotFound = mongo.ErrNoDocuments
+
+// ErrDuplicateKey is returned by implementations other than mongo where a unique index would refuse a write.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// ErrVersionConflict is returned when another version of a resume was added at the same time.
+var ErrVersionConflict = errors.New("resume version conflict")
+
+// UserRepository persists users. UserStore keeps them in mongo, memory.UserStore in memory for tests;
+// storetest.UserRepository checks both behave the same.
+type UserRepository interface {
+	GetUser(ctx context.Context, userId primitive.ObjectID) (model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	GetUserByIdentity(ctx context.Context, provider string, subject string) (model.User, error)
+	// CreateUser refuses emails and linked identities that another user has.
+	CreateUser(ctx context.Context, user model.User) (model.User, error)
+
+	ResetEmailOTP(ctx context.Context, userId primitive.ObjectID, otp model.EmailOTP, previousIssuedAt time.Time) error
+	RegisterOTPAttempt(ctx context.Context, userId primitive.ObjectID, maxAttempts int) (model.User, error)
+	VerifyEmail(ctx context.Context, userId primitive.ObjectID) error
+	LinkIdentity(ctx context.Context, userId primitive.ObjectID, identity model.LinkedIdentity) error
+
+	SetPasswordResetToken(ctx context.Context, userId primitive.ObjectID, tokenHash string, expiry time.Time) error
+	// ResetPassword returns the user as it was before the reset.
+	ResetPassword(ctx context.Context, tokenHash string, hashedPassword string) (model.User, error)
+	UpdatePassword(ctx context.Context, userId primitive.ObjectID, hashedPassword string) error
+
+	SetPendingTwoFactor(ctx context.Context, userId primitive.ObjectID, secret string) error
+	EnableTwoFactor(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodeHashes []string, step int64) error
+	DisableTwoFactor(ctx context.Context, userId primitive.ObjectID) error
+	SetRecoveryCodes(ctx context.Context, userId primitive.ObjectID, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, userId primitive.ObjectID, step int64) error
+	UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) error
+
+	SearchUsers(ctx context.Context, query string, limit int64, skip int64) ([]model.User, int64, error)
+	CountUsers(ctx context.Context) (int64, int64, error)
+	SetRole(ctx context.Context, userId primitive.ObjectID, role string) error
+	// SetRoleByEmail only changes the role of an account that verified the email
+	SetRoleByEmail(ctx context.Context, email string, role string) error
+	SetDisabled(ctx context.Context, userId primitive.ObjectID, disabled bool) error
+
+	UpdateProfile(ctx context.Context, userId primitive.ObjectID, name string) (model.User, error)
+	SetPendingEmail(ctx context.Context, userId primitive.ObjectID, pending model.PendingEmail) error
+	RegisterEmailChangeAttempt(ctx context.Context, userId primitive.ObjectID, maxAttempts int) (model.User, error)
+	ConfirmEmailChange(ctx context.Context, userId primitive.ObjectID, email string) error
+
+	ScheduleDeletion(ctx context.Context, userId primitive.ObjectID, at time.Time) error
+	CancelDeletion(ctx context.Context, userId primitive.ObjectID) error
+	GetUsersDueForDeletion(ctx context.Context, now time.Time, limit int64) ([]model.User, error)
+	DeleteUser(ctx context.Context, userId primitive.ObjectID) error
+}
+
+// ResumeRepository persists resumes and the temporary resumes of the public endpoints. Ids are taken as hex
+// strings, invalid ones return primitive.ErrInvalidHex.
+type ResumeRepository interface {
+	StoreResume(ctx context.Context, resume model.Resume) (model.Resume, error)
+	GetResume(ctx context.Context, id string) (model.Resume, error)
+	GetResumesByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.Resume, error)
+	// ListResumes pages through a user's resumes, leaving out their Content.
+	ListResumes(ctx context.Context, userId primitive.ObjectID, query ResumeQuery) (ResumePage, error)
+	UpdateUserResumeIsPublic(ctx context.Context, userId primitive.ObjectID, id string, isPublic bool) error
+	// UpdateResume returns the updated resume without its Content, or ErrNotFound unless the user owns it.
+	UpdateResume(ctx context.Context, userId primitive.ObjectID, id string, update ResumeUpdate) (model.Resume, error)
+	// AddResumeVersion makes file the current version of the user's resume, keeping the file it replaces as a
+	// previous version. ResumeID, UserID and Version of file are set by the store.
+	AddResumeVersion(ctx context.Context, userId primitive.ObjectID, id string, file model.ResumeVersion) (model.Resume, error)
+	// GetResumeVersion returns a version of the user's resume, the current one included.
+	GetResumeVersion(ctx context.Context, userId primitive.ObjectID, id string, version int) (model.ResumeVersion, error)
+	// ListResumeVersions lists the previous versions of the user's resume, newest first and without Content.
+	ListResumeVersions(ctx context.Context, userId primitive.ObjectID, id string) ([]model.ResumeVersion, error)
+	// PruneResumeVersions deletes all but the newest keep previous versions, returning the deleted ones.
+	PruneResumeVersions(ctx context.Context, userId primitive.ObjectID, id string, keep int) ([]model.ResumeVersion, error)
+	// ListTags counts the user's tags, most used first.
+	ListTags(ctx context.Context, userId primitive.ObjectID) ([]TagCount, error)
+	// RenameTag renames a tag on all of the user's resumes, merging it into resumes that already have the new
+	// name. It returns the number of resumes changed.
+	RenameTag(ctx context.Context, userId primitive.ObjectID, from, to string) (int64, error)
+	// DeleteTag removes a tag from all of the user's resumes, returning the number of resumes changed.
+	DeleteTag(ctx context.Context, userId primitive.ObjectID, tag string) (int64, error)
+	CreateShareLink(ctx context.Context, link model.ShareLink) (model.ShareLink, error)
+	// GetShareLinkByHash returns the link unless it was revoked, expired ones included.
+	GetShareLinkByHash(ctx context.Context, tokenHash string) (model.ShareLink, error)
+	// UseShareLink counts a download, or returns ErrNotFound when the link can't be used anymore.
+	UseShareLink(ctx context.Context, id primitive.ObjectID, now time.Time) (model.ShareLink, error)
+	// ListShareLinks lists the user's links that can still be used, newest first. An empty resumeId lists the
+	// links of all their resumes.
+	ListShareLinks(ctx context.Context, userId primitive.ObjectID, resumeId string, now time.Time) ([]model.ShareLink, error)
+	// RevokeShareLink returns ErrNotFound unless the user has the link and it isn't revoked yet.
+	RevokeShareLink(ctx context.Context, userId primitive.ObjectID, id string) error
+	RecordResumeView(ctx context.Context, view model.ResumeView) error
+	// ResumeAnalytics sums up the views of the user's resume since a time, all zero when there are none.
+	ResumeAnalytics(ctx context.Context, userId primitive.ObjectID, resumeId string, since time.Time) (ResumeAnalytics, error)
+	// DeleteResume deletes the resume, its versions, share links and views if the user owns it, deleting nothing isn't an error.
+	DeleteResume(ctx context.Context, userId primitive.ObjectID, id string) error
+	DeleteResumesByUserId(ctx context.Context, userId primitive.ObjectID) error
+	// GetResumesWithoutContent pages through the resumes of all users that have no Content, by id after the given
+	// one.
+	GetResumesWithoutContent(ctx context.Context, after primitive.ObjectID, limit int64) ([]model.Resume, error)
+	// SetResumeContent returns ErrNotFound when the resume's file isn't the one with key anymore.
+	SetResumeContent(ctx context.Context, id primitive.ObjectID, key string, content string) error
+	CountResumes(ctx context.Context) (int64, int64, error)
+
+	StoreTemporaryResume(ctx context.Context, resume model.TemporaryResume) (model.TemporaryResume, error)
+	GetTemporaryResume(ctx context.Context, id string) (model.TemporaryResume, error)
+}
+
+// SessionRepository persists login sessions and the hashes of their refresh tokens.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session model.Session) (model.Session, error)
+	GetSession(ctx context.Context, id primitive.ObjectID) (model.Session, error)
+	// RotateRefreshToken keeps currentHash as the previous hash, or returns ErrNotFound unless the session is
+	// active and currentHash still matches.
+	RotateRefreshToken(ctx context.Context, id primitive.ObjectID, currentHash, newHash string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id primitive.ObjectID) error
+	RevokeUserSessions(ctx context.Context, userId primitive.ObjectID) error
+	RevokeOtherUserSessions(ctx context.Context, userId primitive.ObjectID, keep primitive.ObjectID) error
+	CountActiveSessions(ctx context.Context) (int64, error)
+	DeleteUserSessions(ctx context.Context, userId primitive.ObjectID) error
+}
+
+// APIKeyRepository persists api keys and the hashes they are looked up by.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error)
+	GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (model.APIKey, error)
+	// GetAPIKeysByUserId lists the keys of the user that aren't revoked.
+	GetAPIKeysByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.APIKey, error)
+	TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error
+	// RevokeAPIKey returns ErrNotFound unless the user has the key and it isn't revoked yet.
+	RevokeAPIKey(ctx context.Context, userId primitive.ObjectID, id string) error
+	RevokeUserAPIKeys(ctx context.Context, userId primitive.ObjectID) error
+	DeleteUserAPIKeys(ctx context.Context, userId primitive.ObjectID) error
+}
+
+// ExportRepository persists the data exports of users.
+type ExportRepository interface {
+	CreateExport(ctx context.Context, export model.Export) (model.Export, error)
+	UpdateExportStatus(ctx context.Context, id primitive.ObjectID, status string, key string) error
+	// GetLatestExport returns the export of the user created last.
+	GetLatestExport(ctx context.Context, userId primitive.ObjectID) (model.Export, error)
+	GetExpiredExports(ctx context.Context, now time.Time) ([]model.Export, error)
+	GetExportsByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.Export, error)
+	DeleteExport(ctx context.Context, id primitive.ObjectID) error
+}
+
+// AuditRepository persists the audit log of the admin api.
+type AuditRepository interface {
+	StoreEvent(ctx context.Context, event model.AuditEvent) error
+	// GetEvents pages through the events newest first, only those about one user unless targetUserId is empty.
+	GetEvents(ctx context.Context, targetUserId string, limit int64, skip int64) ([]model.AuditEvent, error)
+}
+
+// OAuthStateRepository persists the states of OAuth logins that are under way.
+type OAuthStateRepository interface {
+	StoreState(ctx context.Context, state model.OAuthState) error
+	// ConsumeState returns and deletes the state, or returns ErrNotFound when it's unknown, expired or of another
+	// provider.
+	ConsumeState(ctx context.Context, id string, provider string) (model.OAuthState, error)
+}
+
+var (
+	_ UserRepository       = (*UserStore)(nil)
+	_ ResumeRepository     = (*ResumeStore)(nil)
+	_ SessionRepository    = (*SessionStore)(nil)
+	_ APIKeyRepository     = (*APIKeyStore)(nil)
+	_ ExportRepository     = (*ExportStore)(nil)
+	_ AuditRepository      = (*AuditStore)(nil)
+	_ OAuthStateRepository = (*OAuthStateStore)(nil)
+)
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"resume-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type ResumeSort string
+
+const (
+	SortByUploadDate ResumeSort = "upload_date"
+	SortByFileName   ResumeSort = "file_name"
+)
+
+// ResumeQuery selects a page of a user's resumes. Zero values don't filter.
+type ResumeQuery struct {
+	// Tags the resumes must all have
+	Tags           []string
+	Public         *bool
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+	// Search matches words of the file name and the extracted text
+	Search string
+
+	Sort       ResumeSort
+	Descending bool
+	// After continues from the last resume of the previous page
+	After *ResumeCursor
+	Limit int64
+}
+
+// ResumePage has a Next cursor when there are more resumes after it.
+type ResumePage struct {
+	Resumes []model.Resume
+	Next    *ResumeCursor
+}
+
+// ResumeCursor is the position of a resume in the listing, it holds the values of every sort so a cursor works
+// with either of them.
+type ResumeCursor struct {
+	ID         primitive.ObjectID `json:"id"`
+	UploadDate time.Time          `json:"upload_date"`
+	FileName   string             `json:"file_name"`
+}
+
+func CursorOf(resume model.Resume) *ResumeCursor {
+	return &ResumeCursor{ID: resume.ID, UploadDate: resume.UploadDate, FileName: resume.FileName}
+}
+
+// Encode makes the cursor opaque to clients, who get it back with DecodeResumeCursor.
+func (c *ResumeCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeResumeCursor(value string) (*ResumeCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &ResumeCursor{}
+	if err = json.Unmarshal(data, cursor); err != nil || cursor.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// ResumeUpdate changes the fields that are set, Tags replaces all tags unless nil.
+type ResumeUpdate struct {
+	FileName    *string
+	Title       *string
+	Description *string
+	Tags        []string
+	Public      *bool
+}
+
+// TagCount is how many of a user's resumes have a tag.
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// CurrentVersion describes the file a resume has now as a version.
+func CurrentVersion(resume model.Resume) model.ResumeVersion {
+	return model.ResumeVersion{
+		ResumeID:   resume.ID,
+		UserID:     resume.UserID,
+		Version:    resume.Version,
+		FileName:   resume.FileName,
+		Key:        resume.Key,
+		UploadDate: resume.UploadDate,
+		Content:    resume.Content,
+		Current:    true,
+	}
+}
+
+// ShareLinkActive tells whether the link can still be used.
+func ShareLinkActive(link model.ShareLink, now time.Time) bool {
+	return !link.Revoked &&
+		(link.ExpiresAt == nil || now.Before(*link.ExpiresAt)) &&
+		(link.MaxDownloads == 0 || link.Downloads < link.MaxDownloads)
+}
+
+// ResumeAnalytics sums up the views of a resume.
+type ResumeAnalytics struct {
+	Views         int64 `json:"views"`
+	UniqueViewers int64 `json:"unique_viewers"`
+	// Days are the days with views, oldest first
+	Days []DayViews `json:"days"`
+	// Clients counts the views by kind of user agent
+	Clients map[string]int64 `json:"clients"`
+}
+
+// DayViews are the views of a day in UTC, formatted like 2006-01-02.
+type DayViews struct {
+	Date          string `bson:"_id" json:"date"`
+	Views         int64  `bson:"views" json:"views"`
+	UniqueViewers int64  `bson:"unique_viewers" json:"unique_viewers"`
+}
+
+const DayFormat = "2006-01-02"
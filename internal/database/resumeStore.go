@@ -2,7 +2,9 @@ package database
 
 import (
 	"context"
+	"resume-service/internal/metrics"
 	"resume-service/internal/model"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,44 +15,47 @@ import (
 type ResumeStore struct {
 	collection           *mongo.Collection
 	tempResumeCollection *mongo.Collection
+	versionCollection    *mongo.Collection
+	shareLinkCollection  *mongo.Collection
+	viewCollection       *mongo.Collection
 }
 
 const resumeCollection = "resumeCollection"
 const tempResumeCollection = "tempResumeCollection"
+const resumeVersionCollection = "resume_versions"
+const shareLinkCollection = "resume_share_links"
+const resumeViewCollection = "resume_views"
 
-func newResumeStore(ctx context.Context, dbClient *mongo.Database) (ResumeStore, error) {
-	collection := dbClient.Collection(resumeCollection)
-	tempResumeCollection := dbClient.Collection(tempResumeCollection)
-	err := createResumeIndexes(ctx, collection)
-	if err != nil {
-		return ResumeStore{}, err
-	}
+func newResumeStore(dbClient *mongo.Database) ResumeStore {
 	return ResumeStore{
-		collection:           collection,
-		tempResumeCollection: tempResumeCollection,
-	}, nil
-}
-
-func createResumeIndexes(ctx context.Context, collection *mongo.Collection) error {
-	mod := mongo.IndexModel{
-		Keys:    bson.M{"key": 1},
-		Options: options.Index().SetUnique(true),
+		collection:           dbClient.Collection(resumeCollection),
+		tempResumeCollection: dbClient.Collection(tempResumeCollection),
+		versionCollection:    dbClient.Collection(resumeVersionCollection),
+		shareLinkCollection:  dbClient.Collection(shareLinkCollection),
+		viewCollection:       dbClient.Collection(resumeViewCollection),
 	}
-
-	_, err := collection.Indexes().CreateOne(ctx, mod)
-	return err
 }
 
 func (s *ResumeStore) StoreResume(ctx context.Context, resume model.Resume) (model.Resume, error) {
+	ctx, done := instrument(ctx, "resume", "StoreResume")
+	defer done()
+
+	if resume.Version == 0 {
+		resume.Version = 1
+	}
 	storeResult, err := s.collection.InsertOne(ctx, resume)
 	if err != nil {
 		return model.Resume{}, err
 	}
 	resume.ID = storeResult.InsertedID.(primitive.ObjectID)
+	metrics.ResumesUploaded.WithLabelValues("user").Inc()
 	return resume, err
 }
 
 func (s *ResumeStore) GetResume(ctx context.Context, id string) (model.Resume, error) {
+	ctx, done := instrument(ctx, "resume", "GetResume")
+	defer done()
+
 	resume := &model.Resume{}
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -64,15 +69,22 @@ func (s *ResumeStore) GetResume(ctx context.Context, id string) (model.Resume, e
 }
 
 func (s *ResumeStore) StoreTemporaryResume(ctx context.Context, resume model.TemporaryResume) (model.TemporaryResume, error) {
+	ctx, done := instrument(ctx, "resume", "StoreTemporaryResume")
+	defer done()
+
 	storeResult, err := s.tempResumeCollection.InsertOne(ctx, resume)
 	if err != nil {
 		return model.TemporaryResume{}, err
 	}
 	resume.ID = storeResult.InsertedID.(primitive.ObjectID)
+	metrics.ResumesUploaded.WithLabelValues("temporary").Inc()
 	return resume, err
 }
 
 func (s *ResumeStore) GetTemporaryResume(ctx context.Context, id string) (model.TemporaryResume, error) {
+	ctx, done := instrument(ctx, "resume", "GetTemporaryResume")
+	defer done()
+
 	resume := &model.TemporaryResume{}
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -86,6 +98,9 @@ func (s *ResumeStore) GetTemporaryResume(ctx context.Context, id string) (model.
 }
 
 func (s *ResumeStore) DeleteResume(ctx context.Context, userId primitive.ObjectID, id string) error {
+	ctx, done := instrument(ctx, "resume", "DeleteResume")
+	defer done()
+
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
@@ -95,10 +110,22 @@ func (s *ResumeStore) DeleteResume(ctx context.Context, userId primitive.ObjectI
 	if err != nil {
 		return err
 	}
+	_, err = s.versionCollection.DeleteMany(ctx, bson.M{"resume_id": objectId, "user_id": userId})
+	if err != nil {
+		return err
+	}
+	_, err = s.shareLinkCollection.DeleteMany(ctx, bson.M{"resume_id": objectId, "user_id": userId})
+	if err != nil {
+		return err
+	}
+	_, err = s.viewCollection.DeleteMany(ctx, bson.M{"resume_id": objectId, "user_id": userId})
 	return err
 }
 
 func (s *ResumeStore) GetResumesByUserId(ctx context.Context, userId primitive.ObjectID) ([]model.Resume, error) {
+	ctx, done := instrument(ctx, "resume", "GetResumesByUserId")
+	defer done()
+
 	cursor, err := s.collection.Find(ctx, bson.M{"user_id": userId})
 	if err != nil {
 		return nil, err
@@ -112,7 +139,85 @@ func (s *ResumeStore) GetResumesByUserId(ctx context.Context, userId primitive.O
 	return resumes, nil
 }
 
+func (s *ResumeStore) ListResumes(ctx context.Context, userId primitive.ObjectID, query ResumeQuery) (ResumePage, error) {
+	ctx, done := instrument(ctx, "resume", "ListResumes")
+	defer done()
+
+	direction := 1
+	if query.Descending {
+		direction = -1
+	}
+	sortField := string(query.Sort)
+	if query.Sort == "" {
+		sortField = string(SortByUploadDate)
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}}).
+		// the extracted text is only there to be searched
+		SetProjection(bson.M{"content": 0})
+	if query.Limit > 0 {
+		// one more tells if there is a next page
+		opts.SetLimit(query.Limit + 1)
+	}
+
+	cursor, err := s.collection.Find(ctx, resumeFilter(userId, query, sortField), opts)
+	if err != nil {
+		return ResumePage{}, err
+	}
+	page := ResumePage{Resumes: []model.Resume{}}
+	if err = cursor.All(ctx, &page.Resumes); err != nil {
+		return ResumePage{}, err
+	}
+	if query.Limit > 0 && int64(len(page.Resumes)) > query.Limit {
+		page.Resumes = page.Resumes[:query.Limit]
+		page.Next = CursorOf(page.Resumes[query.Limit-1])
+	}
+	return page, nil
+}
+
+func resumeFilter(userId primitive.ObjectID, query ResumeQuery, sortField string) bson.M {
+	filter := bson.M{"user_id": userId}
+	if len(query.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": query.Tags}
+	}
+	if query.Public != nil {
+		filter["public"] = *query.Public
+	}
+	uploaded := bson.M{}
+	if !query.UploadedAfter.IsZero() {
+		uploaded["$gte"] = query.UploadedAfter
+	}
+	if !query.UploadedBefore.IsZero() {
+		uploaded["$lt"] = query.UploadedBefore
+	}
+	if len(uploaded) > 0 {
+		filter["upload_date"] = uploaded
+	}
+	if query.Search != "" {
+		filter["$text"] = bson.M{"$search": query.Search}
+	}
+	if query.After != nil {
+		var value any = query.After.UploadDate
+		if sortField == string(SortByFileName) {
+			value = query.After.FileName
+		}
+		comparison := "$gt"
+		if query.Descending {
+			comparison = "$lt"
+		}
+		// resumes with the same value are ordered by id
+		filter["$or"] = bson.A{
+			bson.M{sortField: bson.M{comparison: value}},
+			bson.M{sortField: value, "_id": bson.M{comparison: query.After.ID}},
+		}
+	}
+	return filter
+}
+
 func (s *ResumeStore) UpdateUserResumeIsPublic(ctx context.Context, userId primitive.ObjectID, id string, isPublic bool) error {
+	ctx, done := instrument(ctx, "resume", "UpdateUserResumeIsPublic")
+	defer done()
+
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
@@ -124,3 +229,476 @@ func (s *ResumeStore) UpdateUserResumeIsPublic(ctx context.Context, userId primi
 	)
 	return result.Err()
 }
+
+func (s *ResumeStore) UpdateResume(ctx context.Context, userId primitive.ObjectID, id string, update ResumeUpdate) (model.Resume, error) {
+	ctx, done := instrument(ctx, "resume", "UpdateResume")
+	defer done()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Resume{}, err
+	}
+	set := bson.M{}
+	if update.FileName != nil {
+		set["file_name"] = *update.FileName
+	}
+	if update.Title != nil {
+		set["title"] = *update.Title
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Tags != nil {
+		set["tags"] = update.Tags
+	}
+	if update.Public != nil {
+		set["public"] = *update.Public
+	}
+	filter := bson.M{"_id": objectId, "user_id": userId}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"content": 0})
+
+	resume := model.Resume{}
+	if len(set) == 0 {
+		err = s.collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"content": 0})).Decode(&resume)
+	} else {
+		err = s.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&resume)
+	}
+	if err != nil {
+		return model.Resume{}, err
+	}
+	return resume, nil
+}
+
+func (s *ResumeStore) AddResumeVersion(ctx context.Context, userId primitive.ObjectID, id string, file model.ResumeVersion) (model.Resume, error) {
+	ctx, done := instrument(ctx, "resume", "AddResumeVersion")
+	defer done()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Resume{}, err
+	}
+	resume := model.Resume{}
+	if err = s.collection.FindOne(ctx, bson.M{"_id": objectId, "user_id": userId}).Decode(&resume); err != nil {
+		return model.Resume{}, err
+	}
+
+	// an upsert, so a version kept by an attempt that failed to update the resume doesn't block the next one
+	previous := CurrentVersion(resume)
+	_, err = s.versionCollection.UpdateOne(ctx,
+		bson.M{"resume_id": resume.ID, "version": resume.Version},
+		bson.M{"$setOnInsert": bson.M{
+			"user_id":     previous.UserID,
+			"file_name":   previous.FileName,
+			"key":         previous.Key,
+			"upload_date": previous.UploadDate,
+			"content":     previous.Content,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return model.Resume{}, err
+	}
+
+	resume.FileName = file.FileName
+	resume.Key = file.Key
+	resume.UploadDate = file.UploadDate
+	resume.Content = file.Content
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": resume.ID, "user_id": userId, "version": resume.Version},
+		bson.M{
+			"$set": bson.M{"file_name": resume.FileName, "key": resume.Key, "upload_date": resume.UploadDate, "content": resume.Content},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return model.Resume{}, err
+	}
+	if result.MatchedCount == 0 {
+		return model.Resume{}, ErrVersionConflict
+	}
+	resume.Version++
+	resume.Content = ""
+	return resume, nil
+}
+
+func (s *ResumeStore) GetResumeVersion(ctx context.Context, userId primitive.ObjectID, id string, version int) (model.ResumeVersion, error) {
+	ctx, done := instrument(ctx, "resume", "GetResumeVersion")
+	defer done()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.ResumeVersion{}, err
+	}
+	resume := model.Resume{}
+	if err = s.collection.FindOne(ctx, bson.M{"_id": objectId, "user_id": userId}).Decode(&resume); err != nil {
+		return model.ResumeVersion{}, err
+	}
+	if version == resume.Version {
+		return CurrentVersion(resume), nil
+	}
+
+	previous := model.ResumeVersion{}
+	err = s.versionCollection.FindOne(ctx, bson.M{"resume_id": objectId, "user_id": userId, "version": version}).Decode(&previous)
+	if err != nil {
+		return model.ResumeVersion{}, err
+	}
+	return previous, nil
+}
+
+func (s *ResumeStore) ListResumeVersions(ctx context.Context, userId primitive.ObjectID, id string) ([]model.ResumeVersion, error) {
+	ctx, done := instrument(ctx, "resume", "ListResumeVersions")
+	defer done()
+
+	filter, err := s.previousVersions(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.findVersions(ctx, filter, 0)
+}
+
+func (s *ResumeStore) PruneResumeVersions(ctx context.Context, userId primitive.ObjectID, id string, keep int) ([]model.ResumeVersion, error) {
+	ctx, done := instrument(ctx, "resume", "PruneResumeVersions")
+	defer done()
+
+	filter, err := s.previousVersions(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	pruned, err := s.findVersions(ctx, filter, int64(keep))
+	if err != nil || len(pruned) == 0 {
+		return pruned, err
+	}
+	ids := bson.A{}
+	for _, version := range pruned {
+		ids = append(ids, version.ID)
+	}
+	if _, err = s.versionCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
+
+// previousVersions filters the versions before the current one. AddResumeVersion keeps the current file as a
+// version before it updates the resume, when the update fails that version is the current file. It mustn't be
+// listed or pruned, pruning would delete the file of the resume.
+func (s *ResumeStore) previousVersions(ctx context.Context, userId primitive.ObjectID, id string) (bson.M, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	resume := model.Resume{}
+	opts := options.FindOne().SetProjection(bson.M{"version": 1, "key": 1})
+	err = s.collection.FindOne(ctx, bson.M{"_id": objectId, "user_id": userId}, opts).Decode(&resume)
+	if IsNotFound(err) {
+		// versions are deleted with their resume, nothing to find
+		return bson.M{"resume_id": objectId, "user_id": userId}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"resume_id": objectId, "user_id": userId, "version": bson.M{"$lt": resume.Version}, "key": bson.M{"$ne": resume.Key}}, nil
+}
+
+// findVersions returns the matching versions newest first and without their content, skipping the first skip.
+func (s *ResumeStore) findVersions(ctx context.Context, filter bson.M, skip int64) ([]model.ResumeVersion, error) {
+	opts := options.Find().SetSort(bson.M{"version": -1}).SetSkip(skip).SetProjection(bson.M{"content": 0})
+	cursor, err := s.versionCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	versions := []model.ResumeVersion{}
+	if err = cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (s *ResumeStore) CreateShareLink(ctx context.Context, link model.ShareLink) (model.ShareLink, error) {
+	ctx, done := instrument(ctx, "resume", "CreateShareLink")
+	defer done()
+
+	res, err := s.shareLinkCollection.InsertOne(ctx, link)
+	if err != nil {
+		return model.ShareLink{}, err
+	}
+	link.ID = res.InsertedID.(primitive.ObjectID)
+	return link, nil
+}
+
+func (s *ResumeStore) GetShareLinkByHash(ctx context.Context, tokenHash string) (model.ShareLink, error) {
+	ctx, done := instrument(ctx, "resume", "GetShareLinkByHash")
+	defer done()
+
+	link := model.ShareLink{}
+	err := s.shareLinkCollection.FindOne(ctx, bson.M{"token_hash": tokenHash, "revoked": false}).Decode(&link)
+	if err != nil {
+		return model.ShareLink{}, err
+	}
+	return link, nil
+}
+
+func (s *ResumeStore) UseShareLink(ctx context.Context, id primitive.ObjectID, now time.Time) (model.ShareLink, error) {
+	ctx, done := instrument(ctx, "resume", "UseShareLink")
+	defer done()
+
+	// checked and counted in one update, so concurrent downloads can't go past the limit
+	filter := activeShareLinks(now)
+	filter["_id"] = id
+	link := model.ShareLink{}
+	err := s.shareLinkCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"downloads": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&link)
+	if err != nil {
+		return model.ShareLink{}, err
+	}
+	return link, nil
+}
+
+func (s *ResumeStore) ListShareLinks(ctx context.Context, userId primitive.ObjectID, resumeId string, now time.Time) ([]model.ShareLink, error) {
+	ctx, done := instrument(ctx, "resume", "ListShareLinks")
+	defer done()
+
+	filter := activeShareLinks(now)
+	filter["user_id"] = userId
+	if resumeId != "" {
+		objectId, err := primitive.ObjectIDFromHex(resumeId)
+		if err != nil {
+			return nil, err
+		}
+		filter["resume_id"] = objectId
+	}
+	cursor, err := s.shareLinkCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	links := []model.ShareLink{}
+	if err = cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (s *ResumeStore) RevokeShareLink(ctx context.Context, userId primitive.ObjectID, id string) error {
+	ctx, done := instrument(ctx, "resume", "RevokeShareLink")
+	defer done()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result := s.shareLinkCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objectId, "user_id": userId, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return result.Err()
+}
+
+func (s *ResumeStore) RecordResumeView(ctx context.Context, view model.ResumeView) error {
+	ctx, done := instrument(ctx, "resume", "RecordResumeView")
+	defer done()
+
+	_, err := s.viewCollection.InsertOne(ctx, view)
+	return err
+}
+
+func (s *ResumeStore) ResumeAnalytics(ctx context.Context, userId primitive.ObjectID, resumeId string, since time.Time) (ResumeAnalytics, error) {
+	ctx, done := instrument(ctx, "resume", "ResumeAnalytics")
+	defer done()
+
+	objectId, err := primitive.ObjectIDFromHex(resumeId)
+	if err != nil {
+		return ResumeAnalytics{}, err
+	}
+	viewers := bson.M{"$addToSet": "$viewer_hash"}
+	countViewers := bson.M{"$project": bson.M{"views": 1, "unique_viewers": bson.M{"$size": "$viewers"}}}
+	cursor, err := s.viewCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId, "resume_id": objectId, "time": bson.M{"$gte": since}}}},
+		{{Key: "$facet", Value: bson.M{
+			"totals": bson.A{
+				bson.M{"$group": bson.M{"_id": nil, "views": bson.M{"$sum": 1}, "viewers": viewers}},
+				countViewers,
+			},
+			"days": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":     bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$time"}},
+					"views":   bson.M{"$sum": 1},
+					"viewers": viewers,
+				}},
+				countViewers,
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+			"clients": bson.A{
+				bson.M{"$group": bson.M{"_id": "$client", "views": bson.M{"$sum": 1}}},
+			},
+		}}},
+	})
+	if err != nil {
+		return ResumeAnalytics{}, err
+	}
+
+	var results []struct {
+		Totals  []DayViews `bson:"totals"`
+		Days    []DayViews `bson:"days"`
+		Clients []struct {
+			Client string `bson:"_id"`
+			Views  int64  `bson:"views"`
+		} `bson:"clients"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return ResumeAnalytics{}, err
+	}
+	analytics := ResumeAnalytics{Days: []DayViews{}, Clients: map[string]int64{}}
+	if len(results) == 0 {
+		return analytics, nil
+	}
+	if totals := results[0].Totals; len(totals) > 0 {
+		analytics.Views = totals[0].Views
+		analytics.UniqueViewers = totals[0].UniqueViewers
+	}
+	analytics.Days = append(analytics.Days, results[0].Days...)
+	for _, client := range results[0].Clients {
+		analytics.Clients[client.Client] = client.Views
+	}
+	return analytics, nil
+}
+
+// activeShareLinks matches the links ShareLinkActive is true for.
+func activeShareLinks(now time.Time) bson.M {
+	return bson.M{
+		"revoked": false,
+		"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": now}}}},
+			bson.M{"$or": bson.A{bson.M{"max_downloads": 0}, bson.M{"$expr": bson.M{"$lt": bson.A{"$downloads", "$max_downloads"}}}}},
+		},
+	}
+}
+
+func (s *ResumeStore) ListTags(ctx context.Context, userId primitive.ObjectID) ([]TagCount, error) {
+	ctx, done := instrument(ctx, "resume", "ListTags")
+	defer done()
+
+	cursor, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := []TagCount{}
+	if err = cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *ResumeStore) RenameTag(ctx context.Context, userId primitive.ObjectID, from, to string) (int64, error) {
+	ctx, done := instrument(ctx, "resume", "RenameTag")
+	defer done()
+
+	if from == to {
+		return 0, nil
+	}
+	// resumes that have both only lose the old tag, so none ends up with the new one twice. Others keep the
+	// order of their tags.
+	result, err := s.collection.UpdateMany(ctx,
+		bson.M{"user_id": userId, "tags": from},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"tags": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{to, "$tags"}},
+				bson.M{"$filter": bson.M{"input": "$tags", "cond": bson.M{"$ne": bson.A{"$$this", from}}}},
+				bson.M{"$map": bson.M{"input": "$tags", "in": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$$this", from}}, to, "$$this"}}}},
+			}},
+		}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (s *ResumeStore) DeleteTag(ctx context.Context, userId primitive.ObjectID, tag string) (int64, error) {
+	ctx, done := instrument(ctx, "resume", "DeleteTag")
+	defer done()
+
+	result, err := s.collection.UpdateMany(ctx,
+		bson.M{"user_id": userId, "tags": tag},
+		bson.M{"$pull": bson.M{"tags": tag}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (s *ResumeStore) CountResumes(ctx context.Context) (int64, int64, error) {
+	ctx, done := instrument(ctx, "resume", "CountResumes")
+	defer done()
+
+	resumes, err := s.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	temporaryResumes, err := s.tempResumeCollection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	return resumes, temporaryResumes, nil
+}
+
+func (s *ResumeStore) DeleteResumesByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "resume", "DeleteResumesByUserId")
+	defer done()
+
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return err
+	}
+	_, err = s.versionCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return err
+	}
+	_, err = s.shareLinkCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return err
+	}
+	_, err = s.viewCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
+
+func (s *ResumeStore) GetResumesWithoutContent(ctx context.Context, after primitive.ObjectID, limit int64) ([]model.Resume, error) {
+	ctx, done := instrument(ctx, "resume", "GetResumesWithoutContent")
+	defer done()
+
+	cursor, err := s.collection.Find(
+		ctx,
+		bson.M{"_id": bson.M{"$gt": after}, "content": bson.M{"$in": bson.A{nil, ""}}},
+		options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resumes := []model.Resume{}
+	if err = cursor.All(ctx, &resumes); err != nil {
+		return nil, err
+	}
+	return resumes, nil
+}
+
+func (s *ResumeStore) SetResumeContent(ctx context.Context, id primitive.ObjectID, key string, content string) error {
+	ctx, done := instrument(ctx, "resume", "SetResumeContent")
+	defer done()
+
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "key": key}, bson.M{"$set": bson.M{"content": content}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"resume-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SessionStore struct {
+	collection *mongo.Collection
+}
+
+const sessionCollection = "sessions"
+
+func newSessionStore(dbClient *mongo.Database) SessionStore {
+	return SessionStore{collection: dbClient.Collection(sessionCollection)}
+}
+
+func (s *SessionStore) CreateSession(ctx context.Context, session model.Session) (model.Session, error) {
+	ctx, done := instrument(ctx, "session", "CreateSession")
+	defer done()
+
+	res, err := s.collection.InsertOne(ctx, session)
+	if err != nil {
+		return model.Session{}, err
+	}
+	session.ID = res.InsertedID.(primitive.ObjectID)
+	return session, nil
+}
+
+func (s *SessionStore) GetSession(ctx context.Context, id primitive.ObjectID) (model.Session, error) {
+	ctx, done := instrument(ctx, "session", "GetSession")
+	defer done()
+
+	session := &model.Session{}
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(session)
+	if err != nil {
+		return model.Session{}, err
+	}
+	return *session, nil
+}
+
+// RotateRefreshToken swaps the refresh token hash of an active session, only if the current hash still matches.
+// The current hash is added to the used ones, to tell reuse from a wrong token. A concurrent rotation with the same
+// token leaves no matching document and returns mongo.ErrNoDocuments.
+func (s *SessionStore) RotateRefreshToken(ctx context.Context, id primitive.ObjectID, currentHash, newHash string, expiresAt time.Time) error {
+	ctx, done := instrument(ctx, "session", "RotateRefreshToken")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "refresh_token_hash": currentHash, "revoked": false},
+		bson.M{
+			"$set":  bson.M{"refresh_token_hash": newHash, "expires_at": expiresAt},
+			"$push": bson.M{"used_refresh_token_hashes": currentHash},
+		},
+	)
+	return result.Err()
+}
+
+func (s *SessionStore) RevokeSession(ctx context.Context, id primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "session", "RevokeSession")
+	defer done()
+
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (s *SessionStore) RevokeUserSessions(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "session", "RevokeUserSessions")
+	defer done()
+
+	_, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userId, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (s *SessionStore) RevokeOtherUserSessions(ctx context.Context, userId primitive.ObjectID, keep primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "session", "RevokeOtherUserSessions")
+	defer done()
+
+	_, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userId, "_id": bson.M{"$ne": keep}, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (s *SessionStore) CountActiveSessions(ctx context.Context) (int64, error) {
+	ctx, done := instrument(ctx, "session", "CountActiveSessions")
+	defer done()
+
+	return s.collection.CountDocuments(ctx, bson.M{"revoked": false, "expires_at": bson.M{"$gt": time.Now()}})
+}
+
+func (s *SessionStore) DeleteUserSessions(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "session", "DeleteUserSessions")
+	defer done()
+
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
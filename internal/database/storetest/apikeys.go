@@ -0,0 +1,75 @@
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyRepository runs the contract tests for api key persistence, newStore has to return an empty store.
+func APIKeyRepository(t *testing.T, newStore func(t *testing.T) database.APIKeyRepository) {
+	ctx := context.Background()
+	createKey := func(t *testing.T, store database.APIKeyRepository, userId primitive.ObjectID, hash string) model.APIKey {
+		t.Helper()
+		key, err := store.CreateAPIKey(ctx, model.APIKey{UserID: userId, Name: hash, Prefix: hash, KeyHash: hash, Scopes: []string{"resumes:read"}, CreatedAt: now()})
+		expectOk(t, err, "CreateAPIKey")
+		return key
+	}
+
+	t.Run("revoke", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		key := createKey(t, store, userId, "first")
+		createKey(t, store, userId, "second")
+
+		if found, err := store.GetActiveAPIKeyByHash(ctx, "first"); err != nil || found.ID != key.ID {
+			t.Errorf("Expected the key by its hash, got %+v %v", found, err)
+		}
+		expectNotFound(t, store.RevokeAPIKey(ctx, primitive.NewObjectID(), key.ID.Hex()), "RevokeAPIKey of another user's key")
+		expectOk(t, store.RevokeAPIKey(ctx, userId, key.ID.Hex()), "RevokeAPIKey")
+		expectNotFound(t, store.RevokeAPIKey(ctx, userId, key.ID.Hex()), "RevokeAPIKey of a revoked key")
+		_, err := store.GetActiveAPIKeyByHash(ctx, "first")
+		expectNotFound(t, err, "GetActiveAPIKeyByHash of a revoked key")
+		if keys, err := store.GetAPIKeysByUserId(ctx, userId); err != nil || len(keys) != 1 || keys[0].KeyHash != "second" {
+			t.Errorf("Expected only the key that isn't revoked, got %+v %v", keys, err)
+		}
+
+		expectOk(t, store.RevokeUserAPIKeys(ctx, userId), "RevokeUserAPIKeys")
+		if keys, err := store.GetAPIKeysByUserId(ctx, userId); err != nil || len(keys) != 0 {
+			t.Errorf("Expected every key of the user to be revoked, got %+v %v", keys, err)
+		}
+	})
+
+	t.Run("touch", func(t *testing.T) {
+		store := newStore(t)
+		key := createKey(t, store, primitive.NewObjectID(), "key")
+		usedAt := now()
+
+		expectOk(t, store.TouchAPIKey(ctx, key.ID, usedAt), "TouchAPIKey")
+		expectOk(t, store.TouchAPIKey(ctx, key.ID, usedAt.Add(time.Second)), "TouchAPIKey within the interval")
+		if touched, _ := store.GetActiveAPIKeyByHash(ctx, "key"); !touched.LastUsedAt.Equal(usedAt) {
+			t.Errorf("Expected the first use to be kept within the interval, got %v", touched.LastUsedAt)
+		}
+		expectOk(t, store.TouchAPIKey(ctx, key.ID, usedAt.Add(time.Hour)), "TouchAPIKey after the interval")
+		if touched, _ := store.GetActiveAPIKeyByHash(ctx, "key"); !touched.LastUsedAt.Equal(usedAt.Add(time.Hour)) {
+			t.Errorf("Expected the use to be recorded after the interval, got %v", touched.LastUsedAt)
+		}
+	})
+
+	t.Run("delete user keys", func(t *testing.T) {
+		store := newStore(t)
+		userId, otherId := primitive.NewObjectID(), primitive.NewObjectID()
+		createKey(t, store, userId, "deleted")
+		createKey(t, store, otherId, "kept")
+
+		expectOk(t, store.DeleteUserAPIKeys(ctx, userId), "DeleteUserAPIKeys")
+		_, err := store.GetActiveAPIKeyByHash(ctx, "deleted")
+		expectNotFound(t, err, "GetActiveAPIKeyByHash of a deleted key")
+		_, err = store.GetActiveAPIKeyByHash(ctx, "kept")
+		expectOk(t, err, "GetActiveAPIKeyByHash of another user's key")
+	})
+}
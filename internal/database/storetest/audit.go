@@ -0,0 +1,38 @@
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditRepository runs the contract tests for audit log persistence, newStore has to return an empty store.
+func AuditRepository(t *testing.T, newStore func(t *testing.T) database.AuditRepository) {
+	ctx := context.Background()
+
+	t.Run("events", func(t *testing.T) {
+		store := newStore(t)
+		actorId, targetId := primitive.NewObjectID(), primitive.NewObjectID().Hex()
+		for i, target := range []string{targetId, "", targetId} {
+			event := model.AuditEvent{ActorID: actorId, Action: "GET /api/admin", TargetUserID: target, Status: 200, IP: "127.0.0.1", Time: now().Add(time.Duration(i) * time.Minute)}
+			expectOk(t, store.StoreEvent(ctx, event), "StoreEvent")
+		}
+
+		events, err := store.GetEvents(ctx, "", 0, 0)
+		if err != nil || len(events) != 3 || !events[0].Time.After(events[1].Time) || !events[1].Time.After(events[2].Time) {
+			t.Errorf("Expected every event newest first, got %+v %v", events, err)
+		}
+		events, err = store.GetEvents(ctx, targetId, 0, 0)
+		if err != nil || len(events) != 2 || events[0].TargetUserID != targetId || events[1].TargetUserID != targetId {
+			t.Errorf("Expected only the events about the user, got %+v %v", events, err)
+		}
+		paged, err := store.GetEvents(ctx, "", 1, 1)
+		if err != nil || len(paged) != 1 || paged[0].TargetUserID != "" {
+			t.Errorf("Expected the second newest event, got %+v %v", paged, err)
+		}
+	})
+}
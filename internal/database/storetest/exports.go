@@ -0,0 +1,55 @@
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExportRepository runs the contract tests for export persistence, newStore has to return an empty store.
+func ExportRepository(t *testing.T, newStore func(t *testing.T) database.ExportRepository) {
+	ctx := context.Background()
+	createExport := func(t *testing.T, store database.ExportRepository, userId primitive.ObjectID, createdAt time.Time) model.Export {
+		t.Helper()
+		export, err := store.CreateExport(ctx, model.Export{UserID: userId, Status: model.ExportPending, CreatedAt: createdAt, ExpiresAt: createdAt.Add(time.Hour)})
+		expectOk(t, err, "CreateExport")
+		return export
+	}
+
+	t.Run("latest export", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		_, err := store.GetLatestExport(ctx, userId)
+		expectNotFound(t, err, "GetLatestExport without exports")
+
+		latest := createExport(t, store, userId, now())
+		createExport(t, store, userId, now().Add(-time.Minute))
+		createExport(t, store, primitive.NewObjectID(), now().Add(time.Minute))
+		expectOk(t, store.UpdateExportStatus(ctx, latest.ID, model.ExportReady, "export.zip"), "UpdateExportStatus")
+		expectNotFound(t, store.UpdateExportStatus(ctx, primitive.NewObjectID(), model.ExportReady, ""), "UpdateExportStatus of an unknown export")
+
+		found, err := store.GetLatestExport(ctx, userId)
+		if err != nil || found.ID != latest.ID || found.Status != model.ExportReady || found.Key != "export.zip" {
+			t.Errorf("Expected the export created last with its new status, got %+v %v", found, err)
+		}
+	})
+
+	t.Run("expired and deleted exports", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		expired := createExport(t, store, userId, now().Add(-2*time.Hour))
+		current := createExport(t, store, userId, now())
+
+		if exports, err := store.GetExpiredExports(ctx, now()); err != nil || len(exports) != 1 || exports[0].ID != expired.ID {
+			t.Errorf("Expected only the expired export, got %+v %v", exports, err)
+		}
+		expectOk(t, store.DeleteExport(ctx, expired.ID), "DeleteExport")
+		if exports, err := store.GetExportsByUserId(ctx, userId); err != nil || len(exports) != 1 || exports[0].ID != current.ID {
+			t.Errorf("Expected the deleted export to be gone, got %+v %v", exports, err)
+		}
+	})
+}
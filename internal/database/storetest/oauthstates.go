@@ -0,0 +1,43 @@
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+)
+
+// OAuthStateRepository runs the contract tests for oauth state persistence, newStore has to return an empty store.
+func OAuthStateRepository(t *testing.T, newStore func(t *testing.T) database.OAuthStateRepository) {
+	ctx := context.Background()
+	storeState := func(t *testing.T, store database.OAuthStateRepository, id string, expiresAt time.Time) {
+		t.Helper()
+		state := model.OAuthState{ID: id, Provider: "google", Nonce: "nonce-" + id, Verifier: "verifier-" + id, ExpiresAt: expiresAt}
+		expectOk(t, store.StoreState(ctx, state), "StoreState")
+	}
+
+	t.Run("consume once", func(t *testing.T) {
+		store := newStore(t)
+		storeState(t, store, "state", now().Add(time.Minute))
+
+		_, err := store.ConsumeState(ctx, "state", "github")
+		expectNotFound(t, err, "ConsumeState for another provider")
+		state, err := store.ConsumeState(ctx, "state", "google")
+		if err != nil || state.Nonce != "nonce-state" || state.Verifier != "verifier-state" {
+			t.Errorf("Expected the stored state, got %+v %v", state, err)
+		}
+		_, err = store.ConsumeState(ctx, "state", "google")
+		expectNotFound(t, err, "ConsumeState of a used state")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		store := newStore(t)
+		storeState(t, store, "expired", now().Add(-time.Second))
+
+		_, err := store.ConsumeState(ctx, "expired", "google")
+		expectNotFound(t, err, "ConsumeState of an expired state")
+		_, err = store.ConsumeState(ctx, "unknown", "google")
+		expectNotFound(t, err, "ConsumeState of an unknown state")
+	})
+}
@@ -0,0 +1,460 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func storeResume(t *testing.T, store database.ResumeRepository, userId primitive.ObjectID, key string) model.Resume {
+	t.Helper()
+	resume, err := store.StoreResume(context.Background(), model.Resume{
+		UserID:     userId,
+		FileName:   key + ".pdf",
+		Key:        key,
+		UploadDate: now(),
+		Tags:       []string{"backend"},
+	})
+	if err != nil {
+		t.Fatalf("Cannot store resume: %v", err)
+	}
+	return resume
+}
+
+// ResumeRepository runs the contract tests for resume persistence, newStore has to return an empty store.
+func ResumeRepository(t *testing.T, newStore func(t *testing.T) database.ResumeRepository) {
+	ctx := context.Background()
+
+	t.Run("store and get", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume := storeResume(t, store, userId, "user-1")
+		if resume.ID.IsZero() {
+			t.Fatal("Expected the resume to get an id")
+		}
+
+		fetched, err := store.GetResume(ctx, resume.ID.Hex())
+		expectOk(t, err, "GetResume")
+		if fetched.UserID != userId || fetched.Key != "user-1" || !fetched.UploadDate.Equal(resume.UploadDate) || len(fetched.Tags) != 1 {
+			t.Errorf("Expected the stored resume, got %+v", fetched)
+		}
+
+		_, err = store.GetResume(ctx, primitive.NewObjectID().Hex())
+		expectNotFound(t, err, "GetResume of an unknown id")
+		if _, err = store.GetResume(ctx, "not-an-id"); !errors.Is(err, primitive.ErrInvalidHex) {
+			t.Errorf("Expected an invalid id to be refused, got %v", err)
+		}
+
+		_, err = store.StoreResume(ctx, model.Resume{UserID: userId, Key: "user-1"})
+		if !database.IsDuplicateKey(err) {
+			t.Errorf("Expected a duplicate key to be refused, got %v", err)
+		}
+	})
+
+	t.Run("list by user", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		first := storeResume(t, store, userId, "user-1")
+		second := storeResume(t, store, userId, "user-2")
+		storeResume(t, store, primitive.NewObjectID(), "other-1")
+
+		resumes, err := store.GetResumesByUserId(ctx, userId)
+		expectOk(t, err, "GetResumesByUserId")
+		if len(resumes) != 2 || resumes[0].ID != first.ID || resumes[1].ID != second.ID {
+			t.Errorf("Expected the user's resumes in upload order, got %+v", resumes)
+		}
+		if resumes, _ = store.GetResumesByUserId(ctx, primitive.NewObjectID()); resumes == nil || len(resumes) != 0 {
+			t.Errorf("Expected an empty list for users without resumes, got %#v", resumes)
+		}
+	})
+
+	t.Run("visibility", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume := storeResume(t, store, userId, "user-1")
+
+		expectOk(t, store.UpdateUserResumeIsPublic(ctx, userId, resume.ID.Hex(), true), "UpdateUserResumeIsPublic")
+		expectNotFound(t, store.UpdateUserResumeIsPublic(ctx, primitive.NewObjectID(), resume.ID.Hex(), false), "UpdateUserResumeIsPublic by another user")
+		if fetched, _ := store.GetResume(ctx, resume.ID.Hex()); !fetched.Public {
+			t.Errorf("Expected the resume to be public, got %+v", fetched)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume := storeResume(t, store, userId, "user-1")
+		storeResume(t, store, userId, "user-2")
+		kept := storeResume(t, store, primitive.NewObjectID(), "other-1")
+
+		expectOk(t, store.DeleteResume(ctx, primitive.NewObjectID(), resume.ID.Hex()), "DeleteResume by another user")
+		if _, err := store.GetResume(ctx, resume.ID.Hex()); err != nil {
+			t.Errorf("Expected other users not to delete the resume, got %v", err)
+		}
+		expectOk(t, store.DeleteResume(ctx, userId, resume.ID.Hex()), "DeleteResume")
+		_, err := store.GetResume(ctx, resume.ID.Hex())
+		expectNotFound(t, err, "GetResume of a deleted resume")
+
+		expectOk(t, store.DeleteResumesByUserId(ctx, userId), "DeleteResumesByUserId")
+		if resumes, _ := store.GetResumesByUserId(ctx, userId); len(resumes) != 0 {
+			t.Errorf("Expected all of the user's resumes to be deleted, got %+v", resumes)
+		}
+		if _, err = store.GetResume(ctx, kept.ID.Hex()); err != nil {
+			t.Errorf("Expected other users' resumes to be kept, got %v", err)
+		}
+	})
+
+	t.Run("list with query", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		start := now()
+		var stored []model.Resume
+		for i, resume := range []model.Resume{
+			{FileName: "b-backend.pdf", Tags: []string{"backend", "go"}, Public: true, Content: "Gopher building payment services"},
+			{FileName: "a-frontend.pdf", Tags: []string{"frontend"}, Content: "React and typescript"},
+			{FileName: "c-backend.pdf", Tags: []string{"backend"}, Content: "Kotlin microservices"},
+		} {
+			resume.UserID = userId
+			resume.Key = "user-" + resume.FileName
+			resume.UploadDate = start.Add(time.Duration(i) * time.Minute)
+			created, err := store.StoreResume(ctx, resume)
+			expectOk(t, err, "StoreResume")
+			stored = append(stored, created)
+		}
+		storeResume(t, store, primitive.NewObjectID(), "other-1")
+
+		list := func(query database.ResumeQuery) database.ResumePage {
+			t.Helper()
+			page, err := store.ListResumes(ctx, userId, query)
+			expectOk(t, err, "ListResumes")
+			return page
+		}
+		expectFiles := func(page database.ResumePage, files ...string) {
+			t.Helper()
+			var got []string
+			for _, resume := range page.Resumes {
+				got = append(got, resume.FileName)
+				if resume.Content != "" {
+					t.Errorf("Expected the listing to leave out the content, got %q", resume.Content)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(files, ",") {
+				t.Errorf("Expected %v, got %v", files, got)
+			}
+		}
+
+		first := list(database.ResumeQuery{Sort: database.SortByUploadDate, Descending: true, Limit: 2})
+		expectFiles(first, "c-backend.pdf", "a-frontend.pdf")
+		if first.Next == nil {
+			t.Fatal("Expected a cursor to the next page")
+		}
+		second := list(database.ResumeQuery{Sort: database.SortByUploadDate, Descending: true, Limit: 2, After: first.Next})
+		expectFiles(second, "b-backend.pdf")
+		if second.Next != nil {
+			t.Errorf("Expected the last page to have no cursor, got %+v", second.Next)
+		}
+
+		expectFiles(list(database.ResumeQuery{Sort: database.SortByFileName}), "a-frontend.pdf", "b-backend.pdf", "c-backend.pdf")
+		expectFiles(list(database.ResumeQuery{Sort: database.SortByFileName, After: database.CursorOf(stored[1])}), "b-backend.pdf", "c-backend.pdf")
+		expectFiles(list(database.ResumeQuery{Tags: []string{"backend", "go"}}), "b-backend.pdf")
+		public := false
+		expectFiles(list(database.ResumeQuery{Public: &public}), "a-frontend.pdf", "c-backend.pdf")
+		expectFiles(list(database.ResumeQuery{UploadedAfter: stored[1].UploadDate, UploadedBefore: stored[2].UploadDate}), "a-frontend.pdf")
+		expectFiles(list(database.ResumeQuery{Search: "typescript"}), "a-frontend.pdf")
+		expectFiles(list(database.ResumeQuery{Search: "kotlin gopher"}), "b-backend.pdf", "c-backend.pdf")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume, err := store.StoreResume(ctx, model.Resume{UserID: userId, FileName: "cv.pdf", Key: "user-1", UploadDate: now(), Tags: []string{"go"}, Content: "text"})
+		expectOk(t, err, "StoreResume")
+
+		title, public := "Backend", true
+		updated, err := store.UpdateResume(ctx, userId, resume.ID.Hex(), database.ResumeUpdate{Title: &title, Tags: []string{"go", "backend"}, Public: &public})
+		expectOk(t, err, "UpdateResume")
+		if updated.Title != title || updated.FileName != "cv.pdf" || len(updated.Tags) != 2 || !updated.Public || updated.Content != "" {
+			t.Errorf("Expected the updated resume without its content, got %+v", updated)
+		}
+		if fetched, _ := store.GetResume(ctx, resume.ID.Hex()); fetched.Title != title || fetched.Content != "text" {
+			t.Errorf("Expected the update to be stored and the content kept, got %+v", fetched)
+		}
+
+		_, err = store.UpdateResume(ctx, primitive.NewObjectID(), resume.ID.Hex(), database.ResumeUpdate{Title: &title})
+		expectNotFound(t, err, "UpdateResume by another user")
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume, err := store.StoreResume(ctx, model.Resume{UserID: userId, FileName: "v1.pdf", Key: "user-1", UploadDate: now(), Tags: []string{"go"}, Content: "first"})
+		expectOk(t, err, "StoreResume")
+		if resume.Version != 1 {
+			t.Fatalf("Expected a new resume to be version 1, got %d", resume.Version)
+		}
+		id := resume.ID.Hex()
+
+		for i := 2; i <= 4; i++ {
+			updated, err := store.AddResumeVersion(ctx, userId, id, model.ResumeVersion{
+				FileName: fmt.Sprintf("v%d.pdf", i), Key: fmt.Sprint("user-", i), UploadDate: now(), Content: fmt.Sprint("text ", i),
+			})
+			expectOk(t, err, "AddResumeVersion")
+			if updated.Version != i || updated.Key != fmt.Sprint("user-", i) || len(updated.Tags) != 1 || updated.Content != "" {
+				t.Fatalf("Expected version %d to be current and the tags kept, got %+v", i, updated)
+			}
+		}
+		_, err = store.AddResumeVersion(ctx, primitive.NewObjectID(), id, model.ResumeVersion{FileName: "x.pdf", Key: "other-1", UploadDate: now()})
+		expectNotFound(t, err, "AddResumeVersion by another user")
+
+		versions, err := store.ListResumeVersions(ctx, userId, id)
+		expectOk(t, err, "ListResumeVersions")
+		if len(versions) != 3 || versions[0].Version != 3 || versions[2].Version != 1 || versions[2].Key != "user-1" || versions[0].Content != "" {
+			t.Errorf("Expected the previous versions newest first, got %+v", versions)
+		}
+		if others, _ := store.ListResumeVersions(ctx, primitive.NewObjectID(), id); len(others) != 0 {
+			t.Errorf("Expected no versions for other users, got %+v", others)
+		}
+
+		first, err := store.GetResumeVersion(ctx, userId, id, 1)
+		expectOk(t, err, "GetResumeVersion")
+		if first.FileName != "v1.pdf" || first.Content != "first" || first.Current {
+			t.Errorf("Expected the first version with its content, got %+v", first)
+		}
+		current, err := store.GetResumeVersion(ctx, userId, id, 4)
+		expectOk(t, err, "GetResumeVersion of the current version")
+		if current.Key != "user-4" || !current.Current {
+			t.Errorf("Expected the current version, got %+v", current)
+		}
+		_, err = store.GetResumeVersion(ctx, userId, id, 7)
+		expectNotFound(t, err, "GetResumeVersion of an unknown version")
+
+		pruned, err := store.PruneResumeVersions(ctx, userId, id, 1)
+		expectOk(t, err, "PruneResumeVersions")
+		if len(pruned) != 2 || pruned[0].Version != 2 || pruned[1].Key != "user-1" {
+			t.Errorf("Expected versions 2 and 1 to be pruned, got %+v", pruned)
+		}
+		if versions, _ = store.ListResumeVersions(ctx, userId, id); len(versions) != 1 || versions[0].Version != 3 {
+			t.Errorf("Expected only version 3 to be left, got %+v", versions)
+		}
+
+		expectOk(t, store.DeleteResume(ctx, userId, id), "DeleteResume")
+		if versions, _ = store.ListResumeVersions(ctx, userId, id); len(versions) != 0 {
+			t.Errorf("Expected the versions to be deleted with the resume, got %+v", versions)
+		}
+	})
+
+	t.Run("share links", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume := storeResume(t, store, userId, "user-shared")
+		other := storeResume(t, store, userId, "user-other")
+		expired := now().Add(-time.Hour)
+
+		link, err := store.CreateShareLink(ctx, model.ShareLink{ResumeID: resume.ID, UserID: userId, TokenHash: "hash-1", Prefix: "abc", MaxDownloads: 2, CreatedAt: now()})
+		expectOk(t, err, "CreateShareLink")
+		_, err = store.CreateShareLink(ctx, model.ShareLink{ResumeID: resume.ID, UserID: userId, TokenHash: "hash-2", ExpiresAt: &expired, CreatedAt: now()})
+		expectOk(t, err, "CreateShareLink expired")
+		otherLink, err := store.CreateShareLink(ctx, model.ShareLink{ResumeID: other.ID, UserID: userId, TokenHash: "hash-3", CreatedAt: now().Add(time.Second)})
+		expectOk(t, err, "CreateShareLink of another resume")
+
+		found, err := store.GetShareLinkByHash(ctx, "hash-1")
+		expectOk(t, err, "GetShareLinkByHash")
+		if found.ID != link.ID || found.ResumeID != resume.ID || found.MaxDownloads != 2 || found.ExpiresAt != nil {
+			t.Errorf("Expected the stored link, got %+v", found)
+		}
+		if _, err = store.GetShareLinkByHash(ctx, "hash-2"); err != nil {
+			t.Errorf("Expected expired links to be found, got %v", err)
+		}
+
+		links, err := store.ListShareLinks(ctx, userId, "", now())
+		expectOk(t, err, "ListShareLinks")
+		if len(links) != 2 || links[0].ID != otherLink.ID || links[1].ID != link.ID {
+			t.Errorf("Expected the active links newest first, got %+v", links)
+		}
+		if links, _ = store.ListShareLinks(ctx, userId, resume.ID.Hex(), now()); len(links) != 1 || links[0].ID != link.ID {
+			t.Errorf("Expected the active link of the resume, got %+v", links)
+		}
+		if links, _ = store.ListShareLinks(ctx, primitive.NewObjectID(), "", now()); len(links) != 0 {
+			t.Errorf("Expected no links of other users, got %+v", links)
+		}
+
+		for i := 1; i <= 2; i++ {
+			used, err := store.UseShareLink(ctx, link.ID, now())
+			expectOk(t, err, "UseShareLink")
+			if used.Downloads != i {
+				t.Errorf("Expected %d downloads, got %d", i, used.Downloads)
+			}
+		}
+		_, err = store.UseShareLink(ctx, link.ID, now())
+		expectNotFound(t, err, "UseShareLink past the limit")
+		if links, _ = store.ListShareLinks(ctx, userId, resume.ID.Hex(), now()); len(links) != 0 {
+			t.Errorf("Expected used up links not to be listed, got %+v", links)
+		}
+
+		expectNotFound(t, store.RevokeShareLink(ctx, primitive.NewObjectID(), otherLink.ID.Hex()), "RevokeShareLink by another user")
+		expectOk(t, store.RevokeShareLink(ctx, userId, otherLink.ID.Hex()), "RevokeShareLink")
+		expectNotFound(t, store.RevokeShareLink(ctx, userId, otherLink.ID.Hex()), "RevokeShareLink twice")
+		_, err = store.GetShareLinkByHash(ctx, "hash-3")
+		expectNotFound(t, err, "GetShareLinkByHash of a revoked link")
+		_, err = store.UseShareLink(ctx, otherLink.ID, now())
+		expectNotFound(t, err, "UseShareLink of a revoked link")
+
+		expectOk(t, store.DeleteResume(ctx, userId, resume.ID.Hex()), "DeleteResume")
+		_, err = store.GetShareLinkByHash(ctx, "hash-1")
+		expectNotFound(t, err, "GetShareLinkByHash after deleting the resume")
+	})
+
+	t.Run("views", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		resume := storeResume(t, store, userId, "user-viewed")
+		other := storeResume(t, store, userId, "user-unviewed")
+		day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		linkId := primitive.NewObjectID()
+
+		for _, view := range []model.ResumeView{
+			{Time: day.Add(-48 * time.Hour), Client: "desktop", ViewerHash: "old"},
+			{Time: day, Client: "desktop", ViewerHash: "a", ShareLinkID: &linkId},
+			{Time: day.Add(time.Hour), Client: "desktop", ViewerHash: "a"},
+			{Time: day.Add(2 * time.Hour), Client: "mobile", ViewerHash: "b"},
+			{Time: day.Add(24 * time.Hour), Client: "bot", ViewerHash: "a"},
+		} {
+			view.ResumeID = resume.ID
+			view.UserID = userId
+			expectOk(t, store.RecordResumeView(ctx, view), "RecordResumeView")
+		}
+
+		analytics, err := store.ResumeAnalytics(ctx, userId, resume.ID.Hex(), day.Add(-time.Hour))
+		expectOk(t, err, "ResumeAnalytics")
+		if analytics.Views != 4 || analytics.UniqueViewers != 2 {
+			t.Errorf("Expected 4 views by 2 viewers since the day, got %+v", analytics)
+		}
+		expectedDays := []database.DayViews{{Date: "2024-03-01", Views: 3, UniqueViewers: 2}, {Date: "2024-03-02", Views: 1, UniqueViewers: 1}}
+		if !reflect.DeepEqual(analytics.Days, expectedDays) {
+			t.Errorf("Expected the views by day %+v, got %+v", expectedDays, analytics.Days)
+		}
+		if !reflect.DeepEqual(analytics.Clients, map[string]int64{"desktop": 2, "mobile": 1, "bot": 1}) {
+			t.Errorf("Expected the views by client, got %+v", analytics.Clients)
+		}
+
+		none, err := store.ResumeAnalytics(ctx, userId, other.ID.Hex(), day.Add(-time.Hour))
+		expectOk(t, err, "ResumeAnalytics without views")
+		if none.Views != 0 || len(none.Days) != 0 || none.Clients == nil {
+			t.Errorf("Expected no views, got %+v", none)
+		}
+		if others, _ := store.ResumeAnalytics(ctx, primitive.NewObjectID(), resume.ID.Hex(), day.Add(-time.Hour)); others.Views != 0 {
+			t.Errorf("Expected no views for other users, got %+v", others)
+		}
+
+		expectOk(t, store.DeleteResume(ctx, userId, resume.ID.Hex()), "DeleteResume")
+		if deleted, _ := store.ResumeAnalytics(ctx, userId, resume.ID.Hex(), time.Time{}); deleted.Views != 0 {
+			t.Errorf("Expected the views to be deleted with the resume, got %+v", deleted)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		for i, tags := range [][]string{{"go", "golang"}, {"golang", "backend"}, {"go"}} {
+			_, err := store.StoreResume(ctx, model.Resume{UserID: userId, Key: fmt.Sprint("user-", i), UploadDate: now(), Tags: tags})
+			expectOk(t, err, "StoreResume")
+		}
+		_, err := store.StoreResume(ctx, model.Resume{UserID: primitive.NewObjectID(), Key: "other-1", UploadDate: now(), Tags: []string{"golang"}})
+		expectOk(t, err, "StoreResume")
+
+		expectTags := func(want string) {
+			t.Helper()
+			tags, err := store.ListTags(ctx, userId)
+			expectOk(t, err, "ListTags")
+			var got []string
+			for _, tag := range tags {
+				got = append(got, fmt.Sprintf("%s:%d", tag.Tag, tag.Count))
+			}
+			if strings.Join(got, ",") != want {
+				t.Errorf("Expected %s, got %v", want, got)
+			}
+		}
+		expectTags("go:2,golang:2,backend:1")
+
+		changed, err := store.RenameTag(ctx, userId, "golang", "go")
+		expectOk(t, err, "RenameTag")
+		if changed != 2 {
+			t.Errorf("Expected 2 resumes to change, got %d", changed)
+		}
+		expectTags("go:3,backend:1")
+		resumes, err := store.GetResumesByUserId(ctx, userId)
+		expectOk(t, err, "GetResumesByUserId")
+		for _, resume := range resumes {
+			if resume.Key == "user-1" && strings.Join(resume.Tags, ",") != "go,backend" {
+				t.Errorf("Expected the renamed tag to keep its place, got %v", resume.Tags)
+			}
+		}
+
+		changed, err = store.DeleteTag(ctx, userId, "go")
+		expectOk(t, err, "DeleteTag")
+		if changed != 3 {
+			t.Errorf("Expected 3 resumes to change, got %d", changed)
+		}
+		expectTags("backend:1")
+
+		if tags, _ := store.ListTags(ctx, primitive.NewObjectID()); tags == nil || len(tags) != 0 {
+			t.Errorf("Expected no tags for users without resumes, got %#v", tags)
+		}
+	})
+
+	t.Run("content backfill", func(t *testing.T) {
+		store := newStore(t)
+		userId := primitive.NewObjectID()
+		first := storeResume(t, store, userId, "user-1")
+		_, err := store.StoreResume(ctx, model.Resume{UserID: userId, FileName: "cv.pdf", Key: "user-2", UploadDate: now(), Content: "Go developer"})
+		expectOk(t, err, "StoreResume")
+		second := storeResume(t, store, primitive.NewObjectID(), "user-3")
+		third := storeResume(t, store, userId, "user-4")
+
+		page, err := store.GetResumesWithoutContent(ctx, primitive.NilObjectID, 2)
+		if err != nil || len(page) != 2 || page[0].ID != first.ID || page[1].ID != second.ID {
+			t.Fatalf("Expected the first two resumes without content of any user, got %+v %v", page, err)
+		}
+		if page, err = store.GetResumesWithoutContent(ctx, second.ID, 2); err != nil || len(page) != 1 || page[0].ID != third.ID {
+			t.Errorf("Expected the resumes after the second, got %+v %v", page, err)
+		}
+
+		expectNotFound(t, store.SetResumeContent(ctx, first.ID, "replaced", "Go developer"), "SetResumeContent of a replaced file")
+		expectOk(t, store.SetResumeContent(ctx, first.ID, first.Key, "Go developer"), "SetResumeContent")
+		if page, err = store.GetResumesWithoutContent(ctx, primitive.NilObjectID, 10); err != nil || len(page) != 2 || page[0].ID != second.ID {
+			t.Errorf("Expected the filled resume to be left out, got %+v %v", page, err)
+		}
+		if found, _ := store.ListResumes(ctx, userId, database.ResumeQuery{Search: "developer"}); len(found.Resumes) != 2 {
+			t.Errorf("Expected the filled resume to be found by its content, got %+v", found.Resumes)
+		}
+	})
+
+	t.Run("temporary resumes", func(t *testing.T) {
+		store := newStore(t)
+		storeResume(t, store, primitive.NewObjectID(), "user-1")
+		temporary, err := store.StoreTemporaryResume(ctx, model.TemporaryResume{FileName: "cv.pdf", Key: "temp-1", UploadDate: now()})
+		expectOk(t, err, "StoreTemporaryResume")
+
+		fetched, err := store.GetTemporaryResume(ctx, temporary.ID.Hex())
+		expectOk(t, err, "GetTemporaryResume")
+		if fetched.Key != "temp-1" {
+			t.Errorf("Expected the temporary resume, got %+v", fetched)
+		}
+		_, err = store.GetResume(ctx, temporary.ID.Hex())
+		expectNotFound(t, err, "GetResume of a temporary resume")
+
+		resumes, temporaryResumes, err := store.CountResumes(ctx)
+		expectOk(t, err, "CountResumes")
+		if resumes != 1 || temporaryResumes != 1 {
+			t.Errorf("Expected one of each, got %d and %d", resumes, temporaryResumes)
+		}
+	})
+}
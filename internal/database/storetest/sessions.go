@@ -0,0 +1,80 @@
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionRepository runs the contract tests for session persistence, newStore has to return an empty store.
+func SessionRepository(t *testing.T, newStore func(t *testing.T) database.SessionRepository) {
+	ctx := context.Background()
+	createSession := func(t *testing.T, store database.SessionRepository, userId primitive.ObjectID, hash string) model.Session {
+		t.Helper()
+		session, err := store.CreateSession(ctx, model.Session{UserID: userId, RefreshTokenHash: hash, CreatedAt: now(), ExpiresAt: now().Add(time.Hour)})
+		expectOk(t, err, "CreateSession")
+		return session
+	}
+
+	t.Run("rotate refresh token", func(t *testing.T) {
+		store := newStore(t)
+		session := createSession(t, store, primitive.NewObjectID(), "first")
+		expiresAt := now().Add(2 * time.Hour)
+
+		expectOk(t, store.RotateRefreshToken(ctx, session.ID, "first", "second", expiresAt), "RotateRefreshToken")
+		expectNotFound(t, store.RotateRefreshToken(ctx, session.ID, "first", "third", expiresAt), "RotateRefreshToken with a rotated hash")
+		rotated, err := store.GetSession(ctx, session.ID)
+		expectOk(t, err, "GetSession")
+		if rotated.RefreshTokenHash != "second" || strings.Join(rotated.UsedRefreshTokenHashes, ",") != "first" || !rotated.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("Expected the second hash after the first and a new expiry, got %+v", rotated)
+		}
+
+		expectOk(t, store.RotateRefreshToken(ctx, session.ID, "second", "third", expiresAt), "RotateRefreshToken")
+		if rotated, _ = store.GetSession(ctx, session.ID); strings.Join(rotated.UsedRefreshTokenHashes, ",") != "first,second" {
+			t.Errorf("Expected every used hash to be kept in order, got %v", rotated.UsedRefreshTokenHashes)
+		}
+
+		expectOk(t, store.RevokeSession(ctx, session.ID), "RevokeSession")
+		expectNotFound(t, store.RotateRefreshToken(ctx, session.ID, "third", "fourth", expiresAt), "RotateRefreshToken of a revoked session")
+		_, err = store.GetSession(ctx, primitive.NewObjectID())
+		expectNotFound(t, err, "GetSession of an unknown id")
+	})
+
+	t.Run("revoke and delete user sessions", func(t *testing.T) {
+		store := newStore(t)
+		userId, otherId := primitive.NewObjectID(), primitive.NewObjectID()
+		kept := createSession(t, store, userId, "kept")
+		revoked := createSession(t, store, userId, "revoked")
+		other := createSession(t, store, otherId, "other")
+
+		expectOk(t, store.RevokeOtherUserSessions(ctx, userId, kept.ID), "RevokeOtherUserSessions")
+		for _, expected := range []struct {
+			session model.Session
+			revoked bool
+		}{{kept, false}, {revoked, true}, {other, false}} {
+			session, _ := store.GetSession(ctx, expected.session.ID)
+			if session.Revoked != expected.revoked {
+				t.Errorf("Expected session %s to be revoked: %v, got %+v", expected.session.RefreshTokenHash, expected.revoked, session)
+			}
+		}
+		if active, err := store.CountActiveSessions(ctx); err != nil || active != 2 {
+			t.Errorf("Expected 2 active sessions, got %d %v", active, err)
+		}
+
+		expectOk(t, store.RevokeUserSessions(ctx, userId), "RevokeUserSessions")
+		if session, _ := store.GetSession(ctx, kept.ID); !session.Revoked {
+			t.Errorf("Expected every session of the user to be revoked, got %+v", session)
+		}
+
+		expectOk(t, store.DeleteUserSessions(ctx, userId), "DeleteUserSessions")
+		_, err := store.GetSession(ctx, kept.ID)
+		expectNotFound(t, err, "GetSession of a deleted session")
+		_, err = store.GetSession(ctx, other.ID)
+		expectOk(t, err, "GetSession of another user's session")
+	})
+}
@@ -0,0 +1,288 @@
+// Package storetest holds the contract tests of the repositories in package database. Every implementation runs
+// them, so tests written against the in-memory stores hold for mongo as well.
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// now is truncated to the millisecond precision mongo stores.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func createUser(t *testing.T, store database.UserRepository, email string) model.User {
+	t.Helper()
+	user, err := store.CreateUser(context.Background(), model.User{Name: "Jane Doe", Email: email, Password: "hash"})
+	if err != nil {
+		t.Fatalf("Cannot create user: %v", err)
+	}
+	return user
+}
+
+func expectNotFound(t *testing.T, err error, action string) {
+	t.Helper()
+	if !database.IsNotFound(err) {
+		t.Errorf("Expected %s to find nothing, got %v", action, err)
+	}
+}
+
+func expectOk(t *testing.T, err error, action string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Expected %s to succeed, got %v", action, err)
+	}
+}
+
+// UserRepository runs the contract tests for user persistence, newStore has to return an empty store.
+func UserRepository(t *testing.T, newStore func(t *testing.T) database.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+		if user.ID.IsZero() {
+			t.Fatal("Expected the user to get an id")
+		}
+
+		byId, err := store.GetUser(ctx, user.ID)
+		expectOk(t, err, "GetUser")
+		byEmail, err := store.GetUserByEmail(ctx, "jane@example.com")
+		expectOk(t, err, "GetUserByEmail")
+		if byId.Email != "jane@example.com" || byEmail.ID != user.ID {
+			t.Errorf("Expected to get the created user, got %+v and %+v", byId, byEmail)
+		}
+
+		_, err = store.GetUser(ctx, primitive.NewObjectID())
+		expectNotFound(t, err, "GetUser of an unknown id")
+		_, err = store.GetUserByEmail(ctx, "JANE@example.com")
+		expectNotFound(t, err, "GetUserByEmail with different case")
+	})
+
+	t.Run("emails and identities are unique", func(t *testing.T) {
+		store := newStore(t)
+		jane := createUser(t, store, "jane@example.com")
+		john := createUser(t, store, "john@example.com")
+
+		_, err := store.CreateUser(ctx, model.User{Name: "Other Jane", Email: "jane@example.com"})
+		if !database.IsDuplicateKey(err) {
+			t.Errorf("Expected a duplicate email to be refused, got %v", err)
+		}
+
+		identity := model.LinkedIdentity{Provider: "google", Subject: "123"}
+		expectOk(t, store.LinkIdentity(ctx, jane.ID, identity), "LinkIdentity")
+		expectOk(t, store.LinkIdentity(ctx, jane.ID, identity), "linking the same identity again")
+		if err = store.LinkIdentity(ctx, john.ID, identity); !database.IsDuplicateKey(err) {
+			t.Errorf("Expected an identity linked to another user to be refused, got %v", err)
+		}
+
+		linked, err := store.GetUserByIdentity(ctx, "google", "123")
+		expectOk(t, err, "GetUserByIdentity")
+		if linked.ID != jane.ID || len(linked.Identities) != 1 || !linked.EmailVerified {
+			t.Errorf("Expected one identity on a verified user, got %+v", linked)
+		}
+		_, err = store.GetUserByIdentity(ctx, "acme", "123")
+		expectNotFound(t, err, "GetUserByIdentity of another provider")
+	})
+
+	t.Run("email otp", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+		issuedAt := now()
+
+		expectOk(t, store.ResetEmailOTP(ctx, user.ID, model.EmailOTP{Hash: "otp", IssuedAt: issuedAt}, time.Time{}), "ResetEmailOTP of a new user")
+		expectNotFound(t, store.ResetEmailOTP(ctx, user.ID, model.EmailOTP{Hash: "other"}, time.Time{}), "ResetEmailOTP with a stale issue time")
+
+		for attempt := 1; attempt <= 2; attempt++ {
+			updated, err := store.RegisterOTPAttempt(ctx, user.ID, 2)
+			expectOk(t, err, "RegisterOTPAttempt")
+			if updated.OTP.Attempts != attempt || updated.OTP.Hash != "otp" {
+				t.Errorf("Expected attempt %d to be counted, got %+v", attempt, updated.OTP)
+			}
+		}
+		_, err := store.RegisterOTPAttempt(ctx, user.ID, 2)
+		expectNotFound(t, err, "RegisterOTPAttempt past the maximum")
+
+		expectOk(t, store.VerifyEmail(ctx, user.ID), "VerifyEmail")
+		verified, _ := store.GetUser(ctx, user.ID)
+		if !verified.EmailVerified || verified.OTP.Hash != "" {
+			t.Errorf("Expected a verified email without OTP, got %+v", verified)
+		}
+	})
+
+	t.Run("password reset", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+
+		expectOk(t, store.SetPasswordResetToken(ctx, user.ID, "token", now().Add(time.Hour)), "SetPasswordResetToken")
+		before, err := store.ResetPassword(ctx, "token", "new-hash")
+		expectOk(t, err, "ResetPassword")
+		if before.ID != user.ID || before.Password != "hash" {
+			t.Errorf("Expected the user as it was before the reset, got %+v", before)
+		}
+		_, err = store.ResetPassword(ctx, "token", "newer-hash")
+		expectNotFound(t, err, "reusing a reset token")
+
+		expectOk(t, store.SetPasswordResetToken(ctx, user.ID, "expired", now().Add(-time.Minute)), "SetPasswordResetToken")
+		_, err = store.ResetPassword(ctx, "expired", "newer-hash")
+		expectNotFound(t, err, "an expired reset token")
+
+		expectOk(t, store.UpdatePassword(ctx, user.ID, "changed"), "UpdatePassword")
+		updated, _ := store.GetUser(ctx, user.ID)
+		if updated.Password != "changed" {
+			t.Errorf("Expected the password to be updated, got %q", updated.Password)
+		}
+	})
+
+	t.Run("two factor", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+
+		expectNotFound(t, store.UseTOTPStep(ctx, user.ID, 1), "UseTOTPStep before enrolment")
+		expectOk(t, store.SetPendingTwoFactor(ctx, user.ID, "secret"), "SetPendingTwoFactor")
+		expectNotFound(t, store.EnableTwoFactor(ctx, user.ID, "other", nil, 1), "EnableTwoFactor with another secret")
+		expectOk(t, store.EnableTwoFactor(ctx, user.ID, "secret", []string{"a", "b"}, 10), "EnableTwoFactor")
+
+		expectNotFound(t, store.UseTOTPStep(ctx, user.ID, 10), "reusing a TOTP step")
+		expectOk(t, store.UseTOTPStep(ctx, user.ID, 11), "UseTOTPStep")
+		expectOk(t, store.UseRecoveryCode(ctx, user.ID, "a"), "UseRecoveryCode")
+		expectNotFound(t, store.UseRecoveryCode(ctx, user.ID, "a"), "reusing a recovery code")
+
+		enabled, _ := store.GetUser(ctx, user.ID)
+		if !enabled.TwoFactor.Enabled || enabled.TwoFactor.Secret != "secret" || enabled.TwoFactor.PendingSecret != "" ||
+			enabled.TwoFactor.LastUsedStep != 11 || !slices.Equal(enabled.TwoFactor.RecoveryCodes, []string{"b"}) {
+			t.Errorf("Expected two factor to be enabled, got %+v", enabled.TwoFactor)
+		}
+
+		expectOk(t, store.SetRecoveryCodes(ctx, user.ID, []string{"c"}), "SetRecoveryCodes")
+		expectOk(t, store.DisableTwoFactor(ctx, user.ID), "DisableTwoFactor")
+		expectNotFound(t, store.SetRecoveryCodes(ctx, user.ID, []string{"d"}), "SetRecoveryCodes without two factor")
+		disabled, _ := store.GetUser(ctx, user.ID)
+		if disabled.TwoFactor.Enabled || len(disabled.TwoFactor.RecoveryCodes) != 0 {
+			t.Errorf("Expected two factor to be disabled, got %+v", disabled.TwoFactor)
+		}
+	})
+
+	t.Run("search and count", func(t *testing.T) {
+		store := newStore(t)
+		first := createUser(t, store, "first@example.com")
+		createUser(t, store, "second@example.com")
+		third := createUser(t, store, "third@other.org")
+		expectOk(t, store.VerifyEmail(ctx, first.ID), "VerifyEmail")
+
+		users, total, err := store.SearchUsers(ctx, "EXAMPLE", 1, 0)
+		expectOk(t, err, "SearchUsers")
+		if total != 2 || len(users) != 1 || users[0].Email != "second@example.com" {
+			t.Errorf("Expected the newest of two matches, got %d: %+v", total, users)
+		}
+		users, total, _ = store.SearchUsers(ctx, "", 10, 1)
+		if total != 3 || len(users) != 2 || users[1].ID != first.ID {
+			t.Errorf("Expected to skip the newest user, got %d: %+v", total, users)
+		}
+		if users, _, _ = store.SearchUsers(ctx, "doe", 0, 0); len(users) != 3 || users[0].ID != third.ID {
+			t.Errorf("Expected names to match too, got %+v", users)
+		}
+
+		count, verified, err := store.CountUsers(ctx)
+		expectOk(t, err, "CountUsers")
+		if count != 3 || verified != 1 {
+			t.Errorf("Expected 3 users of which 1 verified, got %d and %d", count, verified)
+		}
+	})
+
+	t.Run("admin updates", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+
+		expectNotFound(t, store.SetRoleByEmail(ctx, "jane@example.com", "admin"), "SetRoleByEmail of an unverified email")
+		expectOk(t, store.VerifyEmail(ctx, user.ID), "VerifyEmail")
+		expectOk(t, store.SetRoleByEmail(ctx, "jane@example.com", "support"), "SetRoleByEmail")
+		expectNotFound(t, store.SetRoleByEmail(ctx, "nobody@example.com", "admin"), "SetRoleByEmail of an unknown email")
+		expectOk(t, store.SetDisabled(ctx, user.ID, true), "SetDisabled")
+		updated, _ := store.GetUser(ctx, user.ID)
+		if updated.Role != "support" || !updated.Disabled {
+			t.Errorf("Expected a disabled support user, got %+v", updated)
+		}
+
+		expectOk(t, store.SetRole(ctx, user.ID, "admin"), "SetRole")
+		expectNotFound(t, store.SetRole(ctx, primitive.NewObjectID(), "admin"), "SetRole of an unknown user")
+	})
+
+	t.Run("profile and email change", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+		createUser(t, store, "taken@example.com")
+
+		renamed, err := store.UpdateProfile(ctx, user.ID, "Jane Roe")
+		expectOk(t, err, "UpdateProfile")
+		if renamed.Name != "Jane Roe" {
+			t.Errorf("Expected the updated user, got %+v", renamed)
+		}
+
+		_, err = store.RegisterEmailChangeAttempt(ctx, user.ID, 3)
+		expectNotFound(t, err, "RegisterEmailChangeAttempt without a pending change")
+		expectOk(t, store.SetPendingEmail(ctx, user.ID, model.PendingEmail{Email: "taken@example.com"}), "SetPendingEmail")
+		pending, err := store.RegisterEmailChangeAttempt(ctx, user.ID, 3)
+		expectOk(t, err, "RegisterEmailChangeAttempt")
+		if pending.PendingEmail == nil || pending.PendingEmail.OTP.Attempts != 1 {
+			t.Errorf("Expected the attempt to be counted, got %+v", pending.PendingEmail)
+		}
+
+		if err = store.ConfirmEmailChange(ctx, user.ID, "taken@example.com"); !database.IsDuplicateKey(err) {
+			t.Errorf("Expected an email taken in the meantime to be refused, got %v", err)
+		}
+		expectOk(t, store.SetPendingEmail(ctx, user.ID, model.PendingEmail{Email: "new@example.com"}), "SetPendingEmail")
+		expectNotFound(t, store.ConfirmEmailChange(ctx, user.ID, "other@example.com"), "ConfirmEmailChange of another email")
+		expectOk(t, store.ConfirmEmailChange(ctx, user.ID, "new@example.com"), "ConfirmEmailChange")
+
+		changed, _ := store.GetUser(ctx, user.ID)
+		if changed.Email != "new@example.com" || !changed.EmailVerified || changed.PendingEmail != nil {
+			t.Errorf("Expected the email to be changed, got %+v", changed)
+		}
+	})
+
+	t.Run("deletion", func(t *testing.T) {
+		store := newStore(t)
+		due := createUser(t, store, "due@example.com")
+		later := createUser(t, store, "later@example.com")
+		createUser(t, store, "kept@example.com")
+
+		expectNotFound(t, store.CancelDeletion(ctx, due.ID), "CancelDeletion without a scheduled deletion")
+		expectOk(t, store.ScheduleDeletion(ctx, due.ID, now().Add(-time.Minute)), "ScheduleDeletion")
+		expectOk(t, store.ScheduleDeletion(ctx, later.ID, now().Add(time.Hour)), "ScheduleDeletion")
+
+		users, err := store.GetUsersDueForDeletion(ctx, now(), 10)
+		expectOk(t, err, "GetUsersDueForDeletion")
+		if len(users) != 1 || users[0].ID != due.ID {
+			t.Errorf("Expected only the due user, got %+v", users)
+		}
+
+		expectOk(t, store.CancelDeletion(ctx, later.ID), "CancelDeletion")
+		expectOk(t, store.DeleteUser(ctx, due.ID), "DeleteUser")
+		expectOk(t, store.DeleteUser(ctx, due.ID), "deleting a deleted user")
+		_, err = store.GetUser(ctx, due.ID)
+		expectNotFound(t, err, "GetUser of a deleted user")
+		if cancelled, _ := store.GetUser(ctx, later.ID); cancelled.DeletionScheduledAt != nil {
+			t.Errorf("Expected the deletion to be cancelled, got %v", cancelled.DeletionScheduledAt)
+		}
+	})
+
+	t.Run("returned users are copies", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "jane@example.com")
+		expectOk(t, store.LinkIdentity(ctx, user.ID, model.LinkedIdentity{Provider: "google", Subject: "1"}), "LinkIdentity")
+
+		fetched, _ := store.GetUser(ctx, user.ID)
+		fetched.Identities[0].Subject = "2"
+		if again, _ := store.GetUser(ctx, user.ID); again.Identities[0].Subject != "1" {
+			t.Errorf("Expected the stored user not to change, got %+v", again.Identities)
+		}
+	})
+}
@@ -2,8 +2,11 @@ package database
 
 import (
 	"context"
-	"log"
+	"errors"
+	"regexp"
+	"resume-service/internal/logging"
 	"resume-service/internal/model"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,33 +20,21 @@ type UserStore struct {
 
 const userCollection = "users"
 
-func newUserStore(ctx context.Context, dbClient *mongo.Database) (UserStore, error) {
-	collection := dbClient.Collection(userCollection)
-	err := createUserIndexes(ctx, collection)
-	if err != nil {
-		return UserStore{}, err
-	}
-	return UserStore{collection: collection}, nil
-}
-
-func createUserIndexes(ctx context.Context, collection *mongo.Collection) error {
-	mod := mongo.IndexModel{
-		Keys:    bson.M{"email": 1},
-		Options: options.Index().SetUnique(true),
-	}
-
-	_, err := collection.Indexes().CreateOne(ctx, mod)
-	return err
+func newUserStore(dbClient *mongo.Database) UserStore {
+	return UserStore{collection: dbClient.Collection(userCollection)}
 }
 
 func (s *UserStore) GetUser(ctx context.Context, userId primitive.ObjectID) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "GetUser")
+	defer done()
+
 	user := &model.User{}
 	filter := bson.M{"_id": userId}
 	err := s.collection.FindOne(ctx, filter).Decode(user)
 
 	if err != nil {
 		if !IsNotFound(err) {
-			log.Println("Error finding user", err)
+			logging.FromContext(ctx).Error("Error finding user", "error", err)
 		}
 		return *user, err
 	}
@@ -52,13 +43,34 @@ func (s *UserStore) GetUser(ctx context.Context, userId primitive.ObjectID) (mod
 }
 
 func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "GetUserByEmail")
+	defer done()
+
 	user := &model.User{}
 	filter := bson.M{"email": email}
 	err := s.collection.FindOne(ctx, filter).Decode(user)
 
 	if err != nil {
 		if !IsNotFound(err) {
-			log.Println("Error finding user", err)
+			logging.FromContext(ctx).Error("Error finding user", "error", err)
+		}
+		return *user, err
+	}
+
+	return *user, nil
+}
+
+func (s *UserStore) GetUserByIdentity(ctx context.Context, provider string, subject string) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "GetUserByIdentity")
+	defer done()
+
+	user := &model.User{}
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+	err := s.collection.FindOne(ctx, filter).Decode(user)
+
+	if err != nil {
+		if !IsNotFound(err) {
+			logging.FromContext(ctx).Error("Error finding user", "error", err)
 		}
 		return *user, err
 	}
@@ -67,6 +79,9 @@ func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (model.Use
 }
 
 func (s *UserStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "CreateUser")
+	defer done()
+
 	res, err := s.collection.InsertOne(ctx, user)
 	if err != nil {
 		return model.User{}, err
@@ -75,24 +90,404 @@ func (s *UserStore) CreateUser(ctx context.Context, user model.User) (model.User
 	return user, nil
 }
 
-func (s *UserStore) ResetEmailOTP(ctx context.Context, userId primitive.ObjectID, otp string) error {
+// ResetEmailOTP replaces the user's OTP, as long as nobody else replaced it since `previousIssuedAt`.
+// Otherwise mongo.ErrNoDocuments is returned, which keeps concurrent resends from bypassing the throttling.
+func (s *UserStore) ResetEmailOTP(ctx context.Context, userId primitive.ObjectID, otp model.EmailOTP, previousIssuedAt time.Time) error {
+	ctx, done := instrument(ctx, "user", "ResetEmailOTP")
+	defer done()
+
+	filter := bson.M{"_id": userId, "otp.issued_at": previousIssuedAt}
+	if previousIssuedAt.IsZero() {
+		// users created before OTP state existed don't have the field
+		filter["otp.issued_at"] = bson.M{"$in": bson.A{nil, previousIssuedAt}}
+	}
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.M{"$set": bson.M{"otp": otp}},
+	)
+	return result.Err()
+}
+
+// RegisterOTPAttempt counts a verification attempt before the OTP is checked, and returns the updated user.
+// Once maxAttempts is reached no document matches and mongo.ErrNoDocuments is returned.
+func (s *UserStore) RegisterOTPAttempt(ctx context.Context, userId primitive.ObjectID, maxAttempts int) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "RegisterOTPAttempt")
+	defer done()
+
+	user := &model.User{}
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "$or": bson.A{
+			bson.M{"otp.attempts": bson.M{"$lt": maxAttempts}},
+			bson.M{"otp.attempts": bson.M{"$exists": false}},
+		}},
+		bson.M{"$inc": bson.M{"otp.attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(user)
+	if err != nil {
+		return model.User{}, err
+	}
+	return *user, nil
+}
+
+func (s *UserStore) VerifyEmail(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "user", "VerifyEmail")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"email_verified": true}, "$unset": bson.M{"otp": ""}},
+	)
+	return result.Err()
+}
+
+// LinkIdentity adds a provider identity to the user. Linking is only done for emails the provider verified,
+// so the user's email is marked verified as well.
+func (s *UserStore) LinkIdentity(ctx context.Context, userId primitive.ObjectID, identity model.LinkedIdentity) error {
+	ctx, done := instrument(ctx, "user", "LinkIdentity")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{
+			"$addToSet": bson.M{"identities": identity},
+			"$set":      bson.M{"email_verified": true},
+			"$unset":    bson.M{"otp": ""},
+		},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) SetPasswordResetToken(ctx context.Context, userId primitive.ObjectID, tokenHash string, expiry time.Time) error {
+	ctx, done := instrument(ctx, "user", "SetPasswordResetToken")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"password_reset_hash": tokenHash, "password_reset_expiry": expiry}},
+	)
+	return result.Err()
+}
+
+// ResetPassword consumes an unexpired reset token and sets the new password in one update,
+// so a token can only ever be used once.
+func (s *UserStore) ResetPassword(ctx context.Context, tokenHash string, hashedPassword string) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "ResetPassword")
+	defer done()
+
+	user := &model.User{}
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"password_reset_hash": tokenHash, "password_reset_expiry": bson.M{"$gt": time.Now()}},
+		bson.M{
+			"$set":   bson.M{"password": hashedPassword},
+			"$unset": bson.M{"password_reset_hash": "", "password_reset_expiry": ""},
+		},
+	).Decode(user)
+	if err != nil {
+		return model.User{}, err
+	}
+	return *user, nil
+}
+
+func (s *UserStore) SetPendingTwoFactor(ctx context.Context, userId primitive.ObjectID, secret string) error {
+	ctx, done := instrument(ctx, "user", "SetPendingTwoFactor")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"two_factor.pending_secret": secret}},
+	)
+	return result.Err()
+}
+
+// EnableTwoFactor promotes the pending secret, if it is still the one the confirmation code was checked against.
+func (s *UserStore) EnableTwoFactor(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodeHashes []string, step int64) error {
+	ctx, done := instrument(ctx, "user", "EnableTwoFactor")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "two_factor.pending_secret": secret},
+		bson.M{
+			"$set": bson.M{
+				"two_factor.enabled":        true,
+				"two_factor.secret":         secret,
+				"two_factor.recovery_codes": recoveryCodeHashes,
+				"two_factor.last_used_step": step,
+			},
+			"$unset": bson.M{"two_factor.pending_secret": ""},
+		},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) DisableTwoFactor(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "user", "DisableTwoFactor")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$unset": bson.M{"two_factor": ""}},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) SetRecoveryCodes(ctx context.Context, userId primitive.ObjectID, recoveryCodeHashes []string) error {
+	ctx, done := instrument(ctx, "user", "SetRecoveryCodes")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "two_factor.enabled": true},
+		bson.M{"$set": bson.M{"two_factor.recovery_codes": recoveryCodeHashes}},
+	)
+	return result.Err()
+}
+
+// UseTOTPStep records an accepted TOTP step. mongo.ErrNoDocuments means this or a later step was used already.
+func (s *UserStore) UseTOTPStep(ctx context.Context, userId primitive.ObjectID, step int64) error {
+	ctx, done := instrument(ctx, "user", "UseTOTPStep")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "two_factor.enabled": true, "two_factor.last_used_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"two_factor.last_used_step": step}},
+	)
+	return result.Err()
+}
+
+// UseRecoveryCode removes a recovery code, mongo.ErrNoDocuments means the user has no such (unused) code.
+func (s *UserStore) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) error {
+	ctx, done := instrument(ctx, "user", "UseRecoveryCode")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "two_factor.enabled": true, "two_factor.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"two_factor.recovery_codes": codeHash}},
+	)
+	return result.Err()
+}
+
+// SearchUsers pages through users whose name or email contains the query, newest first.
+func (s *UserStore) SearchUsers(ctx context.Context, query string, limit int64, skip int64) ([]model.User, int64, error) {
+	ctx, done := instrument(ctx, "user", "SearchUsers")
+	defer done()
+
+	filter := bson.M{}
+	if query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter["$or"] = bson.A{bson.M{"email": pattern}, bson.M{"name": pattern}}
+	}
+
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(limit).SetSkip(skip))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []model.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *UserStore) CountUsers(ctx context.Context) (int64, int64, error) {
+	ctx, done := instrument(ctx, "user", "CountUsers")
+	defer done()
+
+	total, err := s.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	verified, err := s.collection.CountDocuments(ctx, bson.M{"email_verified": true})
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, verified, nil
+}
+
+func (s *UserStore) SetRole(ctx context.Context, userId primitive.ObjectID, role string) error {
+	ctx, done := instrument(ctx, "user", "SetRole")
+	defer done()
+
 	result := s.collection.FindOneAndUpdate(
 		ctx,
 		bson.M{"_id": userId},
-		bson.M{"$set": bson.M{"email_otp": otp}},
+		bson.M{"$set": bson.M{"role": role}},
 	)
 	return result.Err()
 }
 
-func (s *UserStore) VerifyEmail(ctx context.Context, userId primitive.ObjectID, otp string) error {
+func (s *UserStore) SetRoleByEmail(ctx context.Context, email string, role string) error {
+	ctx, done := instrument(ctx, "user", "SetRoleByEmail")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"email": email, "email_verified": true},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) SetDisabled(ctx context.Context, userId primitive.ObjectID, disabled bool) error {
+	ctx, done := instrument(ctx, "user", "SetDisabled")
+	defer done()
+
 	result := s.collection.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": userId, "email_otp": otp},
-		bson.M{"$set": bson.M{"email_verified": true, "email_otp": ""}},
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"disabled": disabled}},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) UpdateProfile(ctx context.Context, userId primitive.ObjectID, name string) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "UpdateProfile")
+	defer done()
+
+	user := &model.User{}
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"name": name}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(user)
+	if err != nil {
+		return model.User{}, err
+	}
+	return *user, nil
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, userId primitive.ObjectID, hashedPassword string) error {
+	ctx, done := instrument(ctx, "user", "UpdatePassword")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"password": hashedPassword}},
 	)
 	return result.Err()
 }
 
+func (s *UserStore) SetPendingEmail(ctx context.Context, userId primitive.ObjectID, pending model.PendingEmail) error {
+	ctx, done := instrument(ctx, "user", "SetPendingEmail")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"pending_email": pending}},
+	)
+	return result.Err()
+}
+
+// RegisterEmailChangeAttempt is RegisterOTPAttempt for the OTP of a pending email change.
+func (s *UserStore) RegisterEmailChangeAttempt(ctx context.Context, userId primitive.ObjectID, maxAttempts int) (model.User, error) {
+	ctx, done := instrument(ctx, "user", "RegisterEmailChangeAttempt")
+	defer done()
+
+	user := &model.User{}
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "pending_email.otp.attempts": bson.M{"$lt": maxAttempts}},
+		bson.M{"$inc": bson.M{"pending_email.otp.attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(user)
+	if err != nil {
+		return model.User{}, err
+	}
+	return *user, nil
+}
+
+// ConfirmEmailChange swaps in the pending email. If another account took the email in the meantime,
+// the unique index refuses it and a duplicate key error is returned.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, userId primitive.ObjectID, email string) error {
+	ctx, done := instrument(ctx, "user", "ConfirmEmailChange")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "pending_email.email": email},
+		bson.M{
+			"$set":   bson.M{"email": email, "email_verified": true},
+			"$unset": bson.M{"pending_email": "", "otp": ""},
+		},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) ScheduleDeletion(ctx context.Context, userId primitive.ObjectID, at time.Time) error {
+	ctx, done := instrument(ctx, "user", "ScheduleDeletion")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId},
+		bson.M{"$set": bson.M{"deletion_scheduled_at": at}},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) CancelDeletion(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "user", "CancelDeletion")
+	defer done()
+
+	result := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userId, "deletion_scheduled_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deletion_scheduled_at": ""}},
+	)
+	return result.Err()
+}
+
+func (s *UserStore) GetUsersDueForDeletion(ctx context.Context, now time.Time, limit int64) ([]model.User, error) {
+	ctx, done := instrument(ctx, "user", "GetUsersDueForDeletion")
+	defer done()
+
+	cursor, err := s.collection.Find(
+		ctx,
+		bson.M{"deletion_scheduled_at": bson.M{"$lte": now}},
+		options.Find().SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []model.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *UserStore) DeleteUser(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, done := instrument(ctx, "user", "DeleteUser")
+	defer done()
+
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": userId})
+	return err
+}
+
+func IsDuplicateKey(err error) bool {
+	return mongo.IsDuplicateKeyError(err) || errors.Is(err, ErrDuplicateKey)
+}
+
 func IsNotFound(err error) bool {
-	return err == mongo.ErrNoDocuments
+	return errors.Is(err, ErrNotFound)
 }
@@ -0,0 +1,122 @@
+// Package health serves the liveness & readiness endpoints load balancers and orchestrators poll.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	statusOK          = "ok"
+	statusFailing     = "failing"
+	statusUnavailable = "unavailable"
+	statusStopping    = "shutting_down"
+)
+
+// Check is a dependency the service can't serve requests without. Results are reused for CacheFor, so
+// expensive checks don't run on every probe.
+type Check struct {
+	Name     string
+	Check    func(ctx context.Context) error
+	CacheFor time.Duration
+}
+
+type result struct {
+	err       error
+	checkedAt time.Time
+}
+
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	stopping atomic.Bool
+
+	mu      sync.Mutex
+	results map[string]result
+}
+
+// NewChecker creates a checker that gives each check up to timeout.
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout, results: map[string]result{}}
+}
+
+// SetShuttingDown makes readiness fail, so load balancers stop sending requests while they are drained.
+func (h *Checker) SetShuttingDown() {
+	h.stopping.Store(true)
+}
+
+// Live answers as long as the process can serve http.
+func (h *Checker) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": statusOK})
+}
+
+// Ready runs the checks, and only answers 200 when all of them pass.
+func (h *Checker) Ready(c *gin.Context) {
+	if h.stopping.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": statusStopping})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c, h.timeout)
+	defer cancel()
+
+	statuses := make(map[string]string, len(h.checks))
+	healthy := true
+	for name, err := range h.run(ctx) {
+		statuses[name] = statusOK
+		if err != nil {
+			statuses[name] = statusFailing
+			healthy = false
+		}
+	}
+
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": statusUnavailable, "checks": statuses})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": statusOK, "checks": statuses})
+}
+
+// run returns the result of every check, running the ones without a fresh result concurrently.
+func (h *Checker) run(ctx context.Context) map[string]error {
+	now := time.Now()
+	errs := make(map[string]error, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range h.checks {
+		h.mu.Lock()
+		cached, ok := h.results[check.Name]
+		h.mu.Unlock()
+		if ok && now.Sub(cached.checkedAt) < check.CacheFor {
+			mu.Lock()
+			errs[check.Name] = cached.err
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			err := check.Check(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "Readiness check failed", "check", check.Name, "error", err)
+			}
+
+			h.mu.Lock()
+			h.results[check.Name] = result{err: err, checkedAt: now}
+			h.mu.Unlock()
+			mu.Lock()
+			errs[check.Name] = err
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mailCalls := 0
+	var dbErr error
+	checker := NewChecker(time.Second,
+		Check{Name: "database", Check: func(ctx context.Context) error { return dbErr }},
+		Check{Name: "email", Check: func(ctx context.Context) error { mailCalls++; return nil }, CacheFor: time.Minute},
+	)
+	r := gin.New()
+	r.GET("/healthz", checker.Live)
+	r.GET("/readyz", checker.Ready)
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("Expected ready, got %d", code)
+	}
+
+	dbErr = errors.New("server selection timeout")
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while the database is down, got %d", code)
+	}
+	if mailCalls != 1 {
+		t.Errorf("Expected the email check to be cached, ran %d times", mailCalls)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("Expected liveness not to depend on checks, got %d", code)
+	}
+
+	dbErr = nil
+	checker.SetShuttingDown()
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while shutting down, got %d", code)
+	}
+}
@@ -0,0 +1,62 @@
+package humanverify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	HCaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+	TurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// CaptchaVerifier checks tokens with a siteverify endpoint, the protocol hCaptcha, Turnstile & reCAPTCHA share.
+type CaptchaVerifier struct {
+	verifyUrl  string
+	secret     string
+	httpClient *http.Client
+}
+
+func NewCaptchaVerifier(verifyUrl string, secret string) *CaptchaVerifier {
+	return &CaptchaVerifier{verifyUrl: verifyUrl, secret: secret, httpClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (v *CaptchaVerifier) Verify(ctx context.Context, token string, remoteIP string) error {
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+		"remoteip": {remoteIP},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verification returned %d", res.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.Join(ErrFailed, fmt.Errorf("captcha refused: %v", result.ErrorCodes))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package humanverify
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"resume-service/internal/config"
+	"resume-service/internal/ratelimit"
+	"time"
+)
+
+const powChallengeTTL = 5 * time.Minute
+
+// Disabled lets every request through, for local development.
+type Disabled struct{}
+
+func (Disabled) Verify(context.Context, string, string) error {
+	return nil
+}
+
+// New picks the verifier for the configured method. Proof of work uses `used` to refuse replayed solutions, the
+// config only lets it and the secret be per instance in development.
+func New(settings config.HumanVerification, used ratelimit.Store) (Verifier, error) {
+	switch settings.Method {
+	case config.HumanVerificationCaptcha:
+		verifyUrl := settings.CaptchaVerifyURL
+		if verifyUrl == "" {
+			verifyUrl = HCaptchaVerifyURL
+		}
+		return NewCaptchaVerifier(verifyUrl, settings.CaptchaSecret.Value()), nil
+	case config.HumanVerificationNone:
+		return Disabled{}, nil
+	default:
+		secret := []byte(settings.PowSecret.Value())
+		if len(secret) == 0 {
+			slog.Warn("No proof of work secret set, challenges will only be valid on this instance")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		return NewProofOfWork(secret, settings.PowDifficulty, powChallengeTTL, used), nil
+	}
+}
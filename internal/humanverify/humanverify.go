@@ -0,0 +1,69 @@
+package humanverify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"resume-service/internal/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenHeader = "X-Human-Verification"
+
+var (
+	ErrMissingToken = apperror.New(http.StatusForbidden, "human_verification_required", "Human verification is required")
+	ErrFailed       = apperror.New(http.StatusForbidden, "human_verification_failed", "Human verification failed, please try again")
+	errUnavailable  = apperror.New(http.StatusServiceUnavailable, "human_verification_unavailable", "Human verification is unavailable, try again later")
+	errNoChallenges = apperror.New(http.StatusNotFound, "no_challenges", "This verification method has no challenges")
+)
+
+// Verifier checks the token a client got by proving it's (likely) a human.
+type Verifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) error
+}
+
+// Challenger is implemented by verifiers that hand out their own challenges, like proof of work.
+type Challenger interface {
+	Challenge(ctx context.Context) (interface{}, error)
+}
+
+// Middleware requires a valid token in the X-Human-Verification header.
+func Middleware(verifier Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(tokenHeader)
+		if token == "" {
+			apperror.Abort(c, ErrMissingToken)
+			return
+		}
+
+		err := verifier.Verify(c, token, c.ClientIP())
+		if err != nil {
+			if errors.Is(err, ErrFailed) {
+				apperror.Abort(c, ErrFailed.Wrap(err))
+				return
+			}
+			apperror.Abort(c, errUnavailable.Wrap(err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ChallengeHandler serves a new challenge, for verifiers that have them.
+func ChallengeHandler(verifier Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		challenger, ok := verifier.(Challenger)
+		if !ok {
+			apperror.Abort(c, errNoChallenges)
+			return
+		}
+
+		challenge, err := challenger.Challenge(c)
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+	}
+}
@@ -0,0 +1,100 @@
+package humanverify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"resume-service/internal/ratelimit"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProofOfWork(t *testing.T) {
+	pow := NewProofOfWork([]byte("secret"), 8, time.Minute, ratelimit.NewMemoryStore())
+	ctx := context.Background()
+
+	challenge, err := pow.Challenge(ctx)
+	if err != nil {
+		t.Fatalf("Error creating challenge: %v", err)
+	}
+	token := Solve(challenge.(PowChallenge).Challenge, 8)
+
+	if err = pow.Verify(ctx, token, ""); err != nil {
+		t.Fatalf("Expected solved challenge to verify, got %v", err)
+	}
+	if err = pow.Verify(ctx, token, ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("Expected a reused solution to fail, got %v", err)
+	}
+
+	other := NewProofOfWork([]byte("other-secret"), 8, time.Minute, ratelimit.NewMemoryStore())
+	challenge, _ = other.Challenge(ctx)
+	if err = pow.Verify(ctx, Solve(challenge.(PowChallenge).Challenge, 8), ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("Expected a challenge signed by someone else to fail, got %v", err)
+	}
+
+	easy := NewProofOfWork([]byte("secret"), 0, time.Minute, ratelimit.NewMemoryStore())
+	challenge, _ = easy.Challenge(ctx)
+	if err = pow.Verify(ctx, challenge.(PowChallenge).Challenge+":0", ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("Expected a challenge below the difficulty to fail, got %v", err)
+	}
+}
+
+func TestCaptchaVerifier(t *testing.T) {
+	// local stand-in for the siteverify endpoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") == "secret" && r.FormValue("response") == "good-token" {
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	verifier := NewCaptchaVerifier(server.URL, "secret")
+	if err := verifier.Verify(context.Background(), "good-token", "10.0.0.1"); err != nil {
+		t.Errorf("Expected token to verify, got %v", err)
+	}
+	if err := verifier.Verify(context.Background(), "bad-token", "10.0.0.1"); !errors.Is(err, ErrFailed) {
+		t.Errorf("Expected %v, got %v", ErrFailed, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pow := NewProofOfWork([]byte("secret"), 4, time.Minute, ratelimit.NewMemoryStore())
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	r.GET("/challenge", ChallengeHandler(pow))
+	r.POST("/upload", Middleware(pow), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	upload := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		if token != "" {
+			req.Header.Set(tokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := upload(""); code != http.StatusForbidden {
+		t.Errorf("Expected missing token to be refused, got %d", code)
+	}
+	if code := upload("made-up"); code != http.StatusForbidden {
+		t.Errorf("Expected invalid token to be refused, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/challenge", nil))
+	body := w.Body.String()
+	start := strings.Index(body, `"challenge":"`) + len(`"challenge":"`)
+	challenge := body[start : start+strings.Index(body[start:], `"`)]
+	if code := upload(Solve(challenge, 4)); code != http.StatusOK {
+		t.Errorf("Expected solved challenge to pass, got %d: %s", code, body)
+	}
+}
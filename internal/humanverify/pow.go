@@ -0,0 +1,118 @@
+package humanverify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/bits"
+	"resume-service/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	challengeSeparator = "."
+	solutionSeparator  = ":"
+)
+
+// ProofOfWork makes clients spend cpu time before an expensive request. Clients get a challenge and look for
+// a nonce where sha256("<challenge>:<nonce>") starts with `difficulty` zero bits, then send "<challenge>:<nonce>".
+// Challenges are signed instead of stored, and each can only be used once.
+type ProofOfWork struct {
+	secret     []byte
+	difficulty int
+	ttl        time.Duration
+	used       ratelimit.Store
+}
+
+type PowChallenge struct {
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func NewProofOfWork(secret []byte, difficulty int, ttl time.Duration, used ratelimit.Store) *ProofOfWork {
+	return &ProofOfWork{secret: secret, difficulty: difficulty, ttl: ttl, used: used}
+}
+
+func (p *ProofOfWork) Challenge(_ context.Context) (interface{}, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(p.ttl)
+	payload := strconv.FormatInt(expiresAt.Unix(), 10) + "-" + strconv.Itoa(p.difficulty) + "-" + base64.RawURLEncoding.EncodeToString(random)
+	return PowChallenge{
+		Challenge:  payload + challengeSeparator + p.sign(payload),
+		Difficulty: p.difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (p *ProofOfWork) Verify(ctx context.Context, token string, _ string) error {
+	challenge, _, found := strings.Cut(token, solutionSeparator)
+	payload, signature, signed := strings.Cut(challenge, challengeSeparator)
+	if !found || !signed || !hmac.Equal([]byte(signature), []byte(p.sign(payload))) {
+		return ErrFailed
+	}
+
+	fields := strings.SplitN(payload, "-", 3)
+	if len(fields) != 3 {
+		return ErrFailed
+	}
+	expiresUnix, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || time.Now().Unix() > expiresUnix {
+		return ErrFailed
+	}
+	difficulty, err := strconv.Atoi(fields[1])
+	if err != nil || difficulty < p.difficulty {
+		return ErrFailed
+	}
+
+	if leadingZeroBits(sha256.Sum256([]byte(token))) < difficulty {
+		return ErrFailed
+	}
+
+	// only the first request with a solved challenge gets through
+	firstUse, err := p.used.Swap(ctx, "pow:"+challenge, 0, ratelimit.State{ExpiresAt: time.Unix(expiresUnix, 0)})
+	if err != nil {
+		return err
+	}
+	if !firstUse {
+		return ErrFailed
+	}
+	return nil
+}
+
+func (p *ProofOfWork) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Solve finds the nonce for a challenge, the way clients are expected to.
+func Solve(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		token := challenge + solutionSeparator + strconv.Itoa(nonce)
+		if leadingZeroBits(sha256.Sum256([]byte(token))) >= difficulty {
+			return token
+		}
+	}
+}
+
+func leadingZeroBits(sum [32]byte) int {
+	zeros := 0
+	for i := 0; i < len(sum); i += 8 {
+		word := binary.BigEndian.Uint64(sum[i : i+8])
+		zeros += bits.LeadingZeros64(word)
+		if word != 0 {
+			break
+		}
+	}
+	return zeros
+}
@@ -0,0 +1,74 @@
+// Package logging sets up structured JSON logs, and carries a request scoped logger through contexts so
+// everything a request does can be found by its request id.
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeys are redacted from every log, attributes whose key contains one of them are replaced.
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "api_key", "apikey", "otp", "cookie"}
+
+// emailKeys hold email addresses, which are masked so logs can still tell accounts apart.
+var emailKeys = map[string]bool{"email": true, "to": true}
+
+type contextKey struct{}
+
+// Setup makes a JSON logger writing to w the default, including for the standard log package.
+func Setup(w io.Writer, level slog.Leveler) *slog.Logger {
+	logger := slog.New(NewHandler(w, level))
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	return logger
+}
+
+func NewHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact})
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	if emailKeys[key] && attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, MaskEmail(attr.Value.String()))
+	}
+	return attr
+}
+
+// MaskEmail keeps the first letter and the domain of an address, "jane@example.com" becomes "j***@example.com".
+func MaskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return redacted
+	}
+	return local[:1] + "***@" + domain
+}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the default logger outside of requests.
+func FromContext(ctx context.Context) *slog.Logger {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedaction(t *testing.T) {
+	var output bytes.Buffer
+	logger := slog.New(NewHandler(&output, slog.LevelInfo))
+
+	logger.Info("Signup", "email", "jane@example.com", "password", "hunter22", "refresh_token", "abc", "user_id", "42")
+
+	logged := output.String()
+	for _, leaked := range []string{"jane@", "hunter22", "abc"} {
+		if strings.Contains(logged, leaked) {
+			t.Errorf("Expected %q to be redacted, got %s", leaked, logged)
+		}
+	}
+	if !strings.Contains(logged, `"email":"j***@example.com"`) || !strings.Contains(logged, `"user_id":"42"`) {
+		t.Errorf("Expected masked email and other attributes to be kept, got %s", logged)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var output bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(NewHandler(&output, slog.LevelInfo)))
+	defer slog.SetDefault(defaultLogger)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/resumes", func(c *gin.Context) {
+		FromContext(c).Info("Handling")
+		c.Status(http.StatusNotFound)
+	})
+
+	request := func(requestId string) (string, []map[string]any) {
+		output.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/resumes?q=secret", nil)
+		req.Header.Set(RequestIDHeader, requestId)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("Expected JSON logs, got %s", line)
+			}
+			records = append(records, record)
+		}
+		return w.Header().Get(RequestIDHeader), records
+	}
+
+	id, records := request("lb-1234")
+	if id != "lb-1234" || len(records) != 2 {
+		t.Fatalf("Expected the request id to be kept and two records, got %q and %v", id, records)
+	}
+	for _, record := range records {
+		if record["request_id"] != "lb-1234" {
+			t.Errorf("Expected every record to have the request id, got %v", record)
+		}
+	}
+	summary := records[1]
+	if summary["level"] != "WARN" || summary["status"] != float64(http.StatusNotFound) || summary["path"] != "/resumes" {
+		t.Errorf("Expected a warning without the query for the 404, got %v", summary)
+	}
+
+	id, records = request("<script>")
+	if id == "<script>" || id == "" || records[0]["request_id"] != id {
+		t.Errorf("Expected an invalid request id to be replaced, got %q", id)
+	}
+}
+
+func TestMiddlewareRedactsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var output bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(NewHandler(&output, slog.LevelInfo)))
+	defer slog.SetDefault(defaultLogger)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/share/:token", func(c *gin.Context) { c.Status(http.StatusGone) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/share/s3cr3t-token", nil))
+
+	var record map[string]any
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON log, got %s", output.String())
+	}
+	if strings.Contains(output.String(), "s3cr3t-token") || record["path"] != "/api/share/:token" {
+		t.Errorf("Expected the token to be left out of the path, got %v", record)
+	}
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// secretParams are route parameters that grant access by themselves, like the token of a share link.
+var secretParams = []string{"token"}
+
+// Middleware gives every request an id, taken from X-Request-ID when the client or a proxy sent a sane one,
+// and a logger that includes it. Once the request is handled it logs a summary.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestId := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestId) {
+			requestId = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestId)
+
+		logger := slog.Default().With(slog.String("request_id", requestId))
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", Path(c)),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("ip", c.ClientIP()),
+		}
+		if userId, ok := c.Get("userID"); ok {
+			attrs = append(attrs, slog.Any("user_id", userId))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Path is the path of the request to log and trace. Paths with a secret parameter are replaced by their route,
+// so whoever reads the logs can't use the secret.
+func Path(c *gin.Context) string {
+	for _, param := range c.Params {
+		if slices.Contains(secretParams, param.Key) {
+			return c.FullPath()
+		}
+	}
+	return c.Request.URL.Path
+}
+
+// validRequestID only accepts ids that are safe to echo back and put in logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, char := range id {
+		isAlphanumeric := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if !isAlphanumeric && char != '-' && char != '_' && char != '.' {
+			return false
+		}
+	}
+	return true
+}
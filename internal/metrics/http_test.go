@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/download-resume/:resume_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/api/download-resume/1", "/api/download-resume/2", "/wp-login.php"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if count := testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, "/api/download-resume/:resume_id", "200")); count != 2 {
+		t.Errorf("Expected both downloads under their route, got %v", count)
+	}
+	if count := testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")); count != 1 {
+		t.Errorf("Expected the unknown path as unmatched, got %v", count)
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "resume_service_http_request_duration_seconds_bucket") {
+		t.Errorf("Expected the latency histogram to be exposed, got %s", w.Body.String())
+	}
+}
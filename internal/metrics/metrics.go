@@ -0,0 +1,108 @@
+// Package metrics holds the Prometheus collectors of the service. The clients and stores record their own
+// operations, so handlers don't need to know about metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "resume_service"
+
+// Outcomes label whether an operation succeeded.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_operation_duration_seconds",
+		Help:      "Latency of store methods, which may run several mongo commands.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"store", "method"})
+
+	DBCommandErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_command_errors_total",
+		Help:      "Failed mongo commands by command name.",
+	}, []string{"command"})
+
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of S3 operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+
+	StorageBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_bytes_total",
+		Help:      "Bytes uploaded to and downloaded from S3.",
+	}, []string{"operation"})
+
+	LLMDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM calls by model.",
+		Buckets:   []float64{.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "outcome"})
+
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by LLM calls, by model and prompt or completion.",
+	}, []string{"model", "type"})
+
+	LLMErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "Failed LLM calls by model.",
+	}, []string{"model"})
+
+	EmailsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_sent_total",
+		Help:      "Emails sent by kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	ResumesUploaded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resumes_uploaded_total",
+		Help:      "Resumes stored, by user resumes and temporary ones from the public endpoints.",
+	}, []string{"kind"})
+
+	CoverLettersGenerated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cover_letters_generated_total",
+		Help:      "Cover letters generated by model.",
+	}, []string{"model"})
+)
+
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// Since is the time elapsed since start in seconds, the unit of every duration metric.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id,required" json:"user_id"`
+	Name       string             `bson:"name,required" json:"name"`
+	Prefix     string             `bson:"prefix,required" json:"prefix"`
+	KeyHash    string             `bson:"key_hash,required" json:"-"`
+	Scopes     []string           `bson:"scopes,required" json:"scopes"`
+	CreatedAt  time.Time          `bson:"created_at,required" json:"created_at"`
+	LastUsedAt time.Time          `bson:"last_used_at,omitempty" json:"last_used_at"`
+	Revoked    bool               `bson:"revoked" json:"revoked"`
+}
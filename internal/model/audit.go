@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEvent records an action taken through the admin api.
+type AuditEvent struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ActorID      primitive.ObjectID `bson:"actor_id,required" json:"actor_id"`
+	Action       string             `bson:"action,required" json:"action"`
+	TargetUserID string             `bson:"target_user_id,omitempty" json:"target_user_id,omitempty"`
+	Query        string             `bson:"query,omitempty" json:"query,omitempty"`
+	Status       int                `bson:"status,required" json:"status"`
+	IP           string             `bson:"ip,required" json:"ip"`
+	Time         time.Time          `bson:"time,required" json:"time"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// Export is a zip archive with all of a user's data, built in the background.
+type Export struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id,required" json:"user_id"`
+	Status    string             `bson:"status,required" json:"status"`
+	Key       string             `bson:"key,omitempty" json:"-"`
+	CreatedAt time.Time          `bson:"created_at,required" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at,required" json:"expires_at"`
+}
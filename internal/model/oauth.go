@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// OAuthState is kept between sending a user to an OAuth provider and the provider sending them back.
+type OAuthState struct {
+	ID        string    `bson:"_id" json:"-"`
+	Provider  string    `bson:"provider,required" json:"provider"`
+	Nonce     string    `bson:"nonce,required" json:"-"`
+	Verifier  string    `bson:"verifier,required" json:"-"`
+	ExpiresAt time.Time `bson:"expires_at,required" json:"expires_at"`
+}
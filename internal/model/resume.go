@@ -7,13 +7,67 @@ import (
 )
 
 type Resume struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID     primitive.ObjectID `bson:"user_id,required" json:"user_id"`
-	FileName   string             `bson:"file_name,required" json:"file_name"`
-	Key        string             `bson:"key,required" json:"key"`
-	UploadDate time.Time          `bson:"upload_date,required" json:"upload_date"`
-	Tags       []string           `bson:"tags,omitempty" json:"tags"`
-	Public     bool               `bson:"public,required" json:"public"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id,required" json:"user_id"`
+	FileName    string             `bson:"file_name,required" json:"file_name"`
+	Title       string             `bson:"title,omitempty" json:"title"`
+	Description string             `bson:"description,omitempty" json:"description"`
+	Key         string             `bson:"key,required" json:"key"`
+	UploadDate  time.Time          `bson:"upload_date,required" json:"upload_date"`
+	Tags        []string           `bson:"tags,omitempty" json:"tags"`
+	Public      bool               `bson:"public,required" json:"public"`
+	// Version counts the files uploaded for the resume, the first is 1
+	Version int `bson:"version" json:"version"`
+	// Content is the text extracted at upload, for searching
+	Content string `bson:"content,omitempty" json:"-"`
+}
+
+// ResumeVersion is a file a resume had, kept when a new version replaced it.
+type ResumeVersion struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ResumeID   primitive.ObjectID `bson:"resume_id" json:"resume_id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	Version    int                `bson:"version" json:"version"`
+	FileName   string             `bson:"file_name" json:"file_name"`
+	Key        string             `bson:"key" json:"-"`
+	UploadDate time.Time          `bson:"upload_date" json:"upload_date"`
+	Content    string             `bson:"content,omitempty" json:"-"`
+	Current    bool               `bson:"-" json:"current"`
+}
+
+// ShareLink lets anyone with its token download a resume without logging in. Without ExpiresAt or MaxDownloads
+// the link doesn't expire.
+type ShareLink struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ResumeID  primitive.ObjectID `bson:"resume_id" json:"resume_id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"-"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	// Prefix is the start of the token, to tell links apart
+	Prefix       string     `bson:"prefix" json:"prefix"`
+	PasswordHash string     `bson:"password_hash,omitempty" json:"-"`
+	HasPassword  bool       `bson:"has_password" json:"has_password"`
+	ExpiresAt    *time.Time `bson:"expires_at,omitempty" json:"expires_at"`
+	MaxDownloads int        `bson:"max_downloads" json:"max_downloads"`
+	Downloads    int        `bson:"downloads" json:"downloads"`
+	// NotifyOnView emails the owner when the link is first used
+	NotifyOnView bool      `bson:"notify_on_view" json:"notify_on_view"`
+	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
+	Revoked      bool      `bson:"revoked" json:"-"`
+}
+
+// ResumeView is a download of a resume by someone other than its owner, through a share link or because the
+// resume is public.
+type ResumeView struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	ResumeID primitive.ObjectID `bson:"resume_id"`
+	// UserID is the owner of the resume
+	UserID      primitive.ObjectID  `bson:"user_id"`
+	ShareLinkID *primitive.ObjectID `bson:"share_link_id,omitempty"`
+	Time        time.Time           `bson:"time"`
+	// Client is the kind of user agent: desktop, mobile, bot or other
+	Client string `bson:"client"`
+	// ViewerHash tells viewers apart without storing their ip
+	ViewerHash string `bson:"viewer_hash"`
 }
 
 type TemporaryResume struct {
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Session struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           primitive.ObjectID `bson:"user_id,required" json:"user_id"`
+	RefreshTokenHash string             `bson:"refresh_token_hash,required" json:"-"`
+	// UsedRefreshTokenHashes are the hashes of every token the session rotated away from, presenting one again
+	// is reuse.
+	UsedRefreshTokenHashes []string  `bson:"used_refresh_token_hashes,omitempty" json:"-"`
+	CreatedAt              time.Time `bson:"created_at,required" json:"created_at"`
+	ExpiresAt              time.Time `bson:"expires_at,required" json:"expires_at"`
+	Revoked                bool      `bson:"revoked" json:"revoked"`
+}
@@ -1,12 +1,56 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name          string             `bson:"name,required" json:"name"`
-	Email         string             `bson:"email,required" json:"email"`
-	Password      string             `bson:"password,required" json:"password"`
-	EmailVerified bool               `bson:"email_verified,required" json:"email_verified"`
-	EmailToken    string             `bson:"email_otp" json:"email_otp"`
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name                string             `bson:"name,required" json:"name"`
+	Email               string             `bson:"email,required" json:"email"`
+	Password            string             `bson:"password,required" json:"-"`
+	EmailVerified       bool               `bson:"email_verified,required" json:"email_verified"`
+	Role                string             `bson:"role,omitempty" json:"role"`
+	Disabled            bool               `bson:"disabled" json:"disabled"`
+	OTP                 EmailOTP           `bson:"otp" json:"-"`
+	PasswordResetHash   string             `bson:"password_reset_hash,omitempty" json:"-"`
+	PasswordResetExpiry time.Time          `bson:"password_reset_expiry,omitempty" json:"-"`
+	Identities          []LinkedIdentity   `bson:"identities,omitempty" json:"identities"`
+	TwoFactor           TwoFactor          `bson:"two_factor,omitempty" json:"-"`
+	PendingEmail        *PendingEmail      `bson:"pending_email,omitempty" json:"-"`
+	DeletionScheduledAt *time.Time         `bson:"deletion_scheduled_at,omitempty" json:"deletion_scheduled_at,omitempty"`
+}
+
+// PendingEmail is an email change waiting for the OTP sent to the new address.
+type PendingEmail struct {
+	Email string   `bson:"email"`
+	OTP   EmailOTP `bson:"otp"`
+}
+
+// LinkedIdentity is an account at an OAuth / OpenID Connect provider that can be used to log in.
+type LinkedIdentity struct {
+	Provider string `bson:"provider" json:"provider"`
+	Subject  string `bson:"subject" json:"-"`
+}
+
+// EmailOTP is the state of the one time password sent to verify an email address.
+type EmailOTP struct {
+	Hash              string    `bson:"hash"`
+	IssuedAt          time.Time `bson:"issued_at"`
+	ExpiresAt         time.Time `bson:"expires_at"`
+	Attempts          int       `bson:"attempts"`
+	ResendCount       int       `bson:"resend_count"`
+	ResendWindowStart time.Time `bson:"resend_window_start"`
+}
+
+// TwoFactor is the user's TOTP setup. PendingSecret is set during enrolment, until a code confirms it.
+type TwoFactor struct {
+	Enabled       bool     `bson:"enabled"`
+	Secret        string   `bson:"secret,omitempty"`
+	PendingSecret string   `bson:"pending_secret,omitempty"`
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+	// LastUsedStep is the time step of the last accepted code, older steps are refused to stop replays
+	LastUsedStep int64 `bson:"last_used_step"`
 }
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Per on average, with bursts of up to Burst requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// interval is the time it takes to get one token back
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// Allow takes a token from the bucket of the key, if there is one.
+func Allow(ctx context.Context, store Store, key string, limit Limit, now time.Time) (Result, error) {
+	allowed := false
+	state, err := update(ctx, store, key, func(state State, exists bool) State {
+		tokens := limit.burst()
+		if exists {
+			refilled := float64(now.Sub(state.UpdatedAt)) / float64(limit.interval())
+			tokens = math.Min(limit.burst(), state.Tokens+refilled)
+		}
+		allowed = tokens >= 1
+		if allowed {
+			tokens--
+		}
+		state.Tokens = tokens
+		state.UpdatedAt = now
+		state.ExpiresAt = now.Add(time.Duration((limit.burst() - tokens) * float64(limit.interval())))
+		return state
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int(limit.burst()),
+		Remaining: int(state.Tokens),
+		// time until the bucket is full again
+		Reset: state.ExpiresAt.Sub(now),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - state.Tokens) * float64(limit.interval()))
+	}
+	return result, nil
+}
+
+// ParseLimit reads limits written as "<requests>/<duration>" with an optional ":<burst>", ex. "10/1m" or "5/1s:20".
+func ParseLimit(value string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	requests, per, found := strings.Cut(rate, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected <requests>/<duration>", value)
+	}
+
+	limit := Limit{}
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: requests must be a positive number", value)
+	}
+	if limit.Per, err = time.ParseDuration(per); err != nil || limit.Per <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: bad duration", value)
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid limit %q: burst must be a positive number", value)
+		}
+	}
+	return limit, nil
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Lockout locks a key after Threshold failures. Every further failure doubles the lock, up to MaxDelay.
+// Failures are forgotten after Window without any, or on success.
+type Lockout struct {
+	Store     Store
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Check returns how long the key is still locked for, 0 if it isn't.
+func (l *Lockout) Check(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	state, version, err := l.Store.Get(ctx, key)
+	if err != nil || version == 0 || !now.Before(state.LockedUntil) {
+		return 0, err
+	}
+	return state.LockedUntil.Sub(now), nil
+}
+
+// Fail records a failure, and returns how long the key is now locked for.
+func (l *Lockout) Fail(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	state, err := update(ctx, l.Store, key, func(state State, exists bool) State {
+		state.Failures++
+		if state.Failures >= l.Threshold {
+			delay := l.BaseDelay << (state.Failures - l.Threshold)
+			if delay > l.MaxDelay || delay <= 0 {
+				delay = l.MaxDelay
+			}
+			state.LockedUntil = now.Add(delay)
+		}
+		state.ExpiresAt = now.Add(l.Window)
+		if state.LockedUntil.After(state.ExpiresAt) {
+			state.ExpiresAt = state.LockedUntil
+		}
+		return state
+	})
+	if err != nil || !now.Before(state.LockedUntil) {
+		return 0, err
+	}
+	return state.LockedUntil.Sub(now), nil
+}
+
+func (l *Lockout) Succeed(ctx context.Context, key string) error {
+	return l.Store.Delete(ctx, key)
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"resume-service/internal/apperror"
+	"resume-service/internal/logging"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc picks what a request is limited by. An empty key skips that limit for the request.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP limits each client address.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser limits each logged in user, it needs to run after auth.Middleware.
+func ByUser(c *gin.Context) string {
+	userId, ok := c.Get("userID")
+	if !ok {
+		return ""
+	}
+	return "user:" + userId.(string)
+}
+
+// ByEmail limits each account by the email in the json body, for endpoints used before logging in.
+func ByEmail(c *gin.Context) string {
+	email := strings.ToLower(strings.TrimSpace(peekJSONField(c, "email")))
+	if email == "" {
+		return ""
+	}
+	return "email:" + email
+}
+
+// Rule limits a route by every key in Keys, a request has to be within all of them.
+type Rule struct {
+	Name  string
+	Limit Limit
+	Keys  []KeyFunc
+}
+
+// Middleware enforces the rule and sets the RateLimit-* headers (and Retry-After when refused).
+// When the store is unavailable requests are let through, rather than taking the api down with it.
+func Middleware(store Store, rule Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		var tightest *Result
+		for _, keyFunc := range rule.Keys {
+			key := keyFunc(c)
+			if key == "" {
+				continue
+			}
+
+			result, err := Allow(c, store, rule.Name+":"+key, rule.Limit, now)
+			if err != nil {
+				logging.FromContext(c).WarnContext(c, "Rate limit unavailable", "rule", rule.Name, "error", err)
+				continue
+			}
+			if tightest == nil || !result.Allowed || (tightest.Allowed && result.Remaining < tightest.Remaining) {
+				tightest = &result
+			}
+			if !result.Allowed {
+				break
+			}
+		}
+
+		if tightest == nil {
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("RateLimit-Reset", seconds(tightest.Reset))
+		if !tightest.Allowed {
+			c.Header("Retry-After", seconds(tightest.RetryAfter))
+			apperror.Abort(c, apperror.ErrRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// peekJSONField reads a string field of the json body, and puts the body back for the handler.
+func peekJSONField(c *gin.Context, field string) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	value, _ := fields[field].(string)
+	return value
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAllow(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Per: time.Minute}
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		result, err := Allow(ctx, store, "key", limit, now)
+		if err != nil || !result.Allowed || result.Remaining != 1-i {
+			t.Fatalf("Request %d should be allowed, got %+v %v", i+1, result, err)
+		}
+	}
+
+	result, _ := Allow(ctx, store, "key", limit, now)
+	if result.Allowed || result.RetryAfter != 30*time.Second {
+		t.Errorf("Expected third request to wait 30s, got %+v", result)
+	}
+
+	result, _ = Allow(ctx, store, "key", limit, now.Add(30*time.Second))
+	if !result.Allowed {
+		t.Errorf("Expected a token to be back after 30s, got %+v", result)
+	}
+
+	result, _ = Allow(ctx, store, "other-key", limit, now)
+	if !result.Allowed {
+		t.Errorf("Expected keys to have their own buckets")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	lockout := &Lockout{Store: NewMemoryStore(), Threshold: 3, BaseDelay: time.Minute, MaxDelay: 3 * time.Minute, Window: time.Hour}
+	ctx := context.Background()
+	now := time.Now()
+
+	expected := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, delay := range expected {
+		locked, err := lockout.Fail(ctx, "key", now)
+		if err != nil || locked != delay {
+			t.Fatalf("Failure %d: expected lock of %v, got %v %v", i+1, delay, locked, err)
+		}
+	}
+
+	if locked, _ := lockout.Check(ctx, "key", now); locked != 3*time.Minute {
+		t.Errorf("Expected key to be locked for 3m, got %v", locked)
+	}
+	if locked, _ := lockout.Check(ctx, "key", now.Add(3*time.Minute)); locked != 0 {
+		t.Errorf("Expected lock to be over, got %v", locked)
+	}
+
+	_ = lockout.Succeed(ctx, "key")
+	if locked, _ := lockout.Fail(ctx, "key", now); locked != 0 {
+		t.Errorf("Expected success to reset failures, got lock of %v", locked)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	rule := Rule{Name: "login", Limit: Limit{Requests: 1, Per: time.Minute}, Keys: []KeyFunc{ByIP, ByEmail}}
+	r.POST("/login", Middleware(NewMemoryStore(), rule), func(c *gin.Context) {
+		// the body is still readable after the middleware looked at it
+		body := struct {
+			Email string `json:"email"`
+		}{}
+		if err := c.ShouldBindJSON(&body); err != nil || body.Email == "" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	login := func(email string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"`+email+`"}`))
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := login("a@example.com", "10.0.0.1")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("Expected first login to pass with rate limit headers, got %d %v", w.Code, w.Header())
+	}
+
+	w = login("a@example.com", "10.0.0.2")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected the same account from another ip to be limited, got %d %v", w.Code, w.Header())
+	}
+
+	w = login("b@example.com", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected another account from the same ip to be limited, got %d", w.Code)
+	}
+
+	w = login("b@example.com", "10.0.0.3")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected an unrelated ip & account to pass, got %d", w.Code)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("5/1s:20")
+	if err != nil || limit != (Limit{Requests: 5, Per: time.Second, Burst: 20}) {
+		t.Errorf("Unexpected limit %+v %v", limit, err)
+	}
+	for _, value := range []string{"", "5", "0/1m", "5/minute", "5/1m:0"} {
+		if _, err = ParseLimit(value); err == nil {
+			t.Errorf("Expected %q to be refused", value)
+		}
+	}
+}
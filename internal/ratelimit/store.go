@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const maxSwapRetries = 5
+
+var ErrContention = errors.New("rate limit state kept changing, giving up")
+
+// State is what the limiters keep per key. A store only has to persist it and swap it atomically,
+// so anything with a compare-and-swap (mongo, redis) can back the limiters.
+type State struct {
+	Tokens      float64   `bson:"tokens"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+	Failures    int       `bson:"failures"`
+	LockedUntil time.Time `bson:"locked_until"`
+	// ExpiresAt is when the store may forget the state
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type Store interface {
+	// Get returns the state of the key and its version. Version 0 means the key has no state. An expired state
+	// comes back as the zero State, with the version it has to be swapped at.
+	Get(ctx context.Context, key string) (State, int64, error)
+	// Swap stores the state if the key is still at the given version, and reports whether it did.
+	Swap(ctx context.Context, key string, version int64, state State) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// update applies fn to the state of the key, retrying when another request changed it concurrently.
+func update(ctx context.Context, store Store, key string, fn func(state State, exists bool) State) (State, error) {
+	for i := 0; i < maxSwapRetries; i++ {
+		state, version, err := store.Get(ctx, key)
+		if err != nil {
+			return State{}, err
+		}
+		next := fn(state, !state.ExpiresAt.IsZero())
+		swapped, err := store.Swap(ctx, key, version, next)
+		if err != nil {
+			return State{}, err
+		}
+		if swapped {
+			return next, nil
+		}
+	}
+	return State{}, ErrContention
+}
+
+type memoryEntry struct {
+	state   State
+	version int64
+}
+
+// MemoryStore keeps state in the process, so limits are per instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	swaps   int
+}
+
+// expired entries are swept every this many swaps
+const memorySweepInterval = 10000
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (State, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.state.ExpiresAt) {
+		return State{}, 0, nil
+	}
+	return entry.state, entry.version, nil
+}
+
+func (s *MemoryStore) Swap(_ context.Context, key string, version int64, state State) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	current := entry.version
+	if !ok || time.Now().After(entry.state.ExpiresAt) {
+		current = 0
+	}
+	if current != version {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{state: state, version: current + 1}
+
+	s.swaps++
+	if s.swaps%memorySweepInterval == 0 {
+		now := time.Now()
+		for k, e := range s.entries {
+			if now.After(e.state.ExpiresAt) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
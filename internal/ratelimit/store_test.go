@@ -0,0 +1,11 @@
+package ratelimit_test
+
+import (
+	"resume-service/internal/ratelimit"
+	"resume-service/internal/ratelimit/storetest"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Store(t, func(t *testing.T) ratelimit.Store { return ratelimit.NewMemoryStore() })
+}
@@ -0,0 +1,70 @@
+// Package storetest checks that implementations of ratelimit.Store behave the same.
+package storetest
+
+import (
+	"context"
+	"resume-service/internal/ratelimit"
+	"testing"
+	"time"
+)
+
+// Store runs the contract tests for rate limit state, newStore has to return an empty store.
+func Store(t *testing.T, newStore func(t *testing.T) ratelimit.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	t.Run("compare and swap", func(t *testing.T) {
+		store := newStore(t)
+		state, version, err := store.Get(ctx, "key")
+		if err != nil || version != 0 || state != (ratelimit.State{}) {
+			t.Fatalf("Expected no state for a new key, got %+v %d %v", state, version, err)
+		}
+
+		first := ratelimit.State{Tokens: 1, UpdatedAt: now, ExpiresAt: now.Add(time.Minute)}
+		if swapped, err := store.Swap(ctx, "key", 0, first); err != nil || !swapped {
+			t.Fatalf("Expected the first swap to succeed, got %v %v", swapped, err)
+		}
+		if swapped, err := store.Swap(ctx, "key", 0, first); err != nil || swapped {
+			t.Errorf("Expected a swap at a stale version to fail, got %v %v", swapped, err)
+		}
+
+		state, version, err = store.Get(ctx, "key")
+		if err != nil || version == 0 || state.Tokens != 1 || !state.ExpiresAt.Equal(first.ExpiresAt) {
+			t.Fatalf("Expected the stored state, got %+v %d %v", state, version, err)
+		}
+		second := ratelimit.State{Failures: 2, ExpiresAt: now.Add(time.Hour)}
+		if swapped, err := store.Swap(ctx, "key", version, second); err != nil || !swapped {
+			t.Fatalf("Expected a swap at the current version to succeed, got %v %v", swapped, err)
+		}
+		if swapped, err := store.Swap(ctx, "key", version, first); err != nil || swapped {
+			t.Errorf("Expected a swap at a replaced version to fail, got %v %v", swapped, err)
+		}
+
+		if err = store.Delete(ctx, "key"); err != nil {
+			t.Fatalf("Expected the delete to succeed, got %v", err)
+		}
+		if _, version, err = store.Get(ctx, "key"); err != nil || version != 0 {
+			t.Errorf("Expected no state after the delete, got version %d %v", version, err)
+		}
+	})
+
+	t.Run("expired state", func(t *testing.T) {
+		store := newStore(t)
+		expired := ratelimit.State{Failures: 5, LockedUntil: now.Add(-time.Second), ExpiresAt: now.Add(-time.Second)}
+		if swapped, err := store.Swap(ctx, "key", 0, expired); err != nil || !swapped {
+			t.Fatalf("Expected the swap to succeed, got %v %v", swapped, err)
+		}
+
+		state, version, err := store.Get(ctx, "key")
+		if err != nil || state != (ratelimit.State{}) {
+			t.Fatalf("Expected an expired state to come back empty, got %+v %v", state, err)
+		}
+		fresh := ratelimit.State{Failures: 1, ExpiresAt: now.Add(time.Minute)}
+		if swapped, err := store.Swap(ctx, "key", version, fresh); err != nil || !swapped {
+			t.Fatalf("Expected a swap at the version of the expired state to succeed, got %v %v", swapped, err)
+		}
+		if state, _, err = store.Get(ctx, "key"); err != nil || state.Failures != 1 {
+			t.Errorf("Expected the fresh state, got %+v %v", state, err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package resume
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/background"
+	"resume-service/internal/database"
+	"resume-service/internal/logging"
+	"resume-service/internal/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultAnalyticsDays = 30
+
+const (
+	clientDesktop = "desktop"
+	clientMobile  = "mobile"
+	clientBot     = "bot"
+	clientOther   = "other"
+)
+
+// botAgents are parts of the user agents of crawlers, link previews and scripts, in lower case.
+var botAgents = []string{"bot", "crawl", "spider", "slurp", "preview", "curl", "wget", "python", "go-http-client", "java/", "okhttp", "postman"}
+
+// ShareMailer is the part of email.EmailClient the analytics use.
+type ShareMailer interface {
+	SendShareLinkViewedMail(ctx context.Context, to string, fileName string, linkPrefix string) error
+}
+
+// Analytics is what the controller needs to record views of shared resumes and tell their owners.
+type Analytics struct {
+	// ViewerHashKey keys the hashes of viewer ips, so they can't be reversed by trying every address
+	ViewerHashKey []byte
+	Users         database.UserRepository
+	Mailer        ShareMailer
+	Jobs          *background.Jobs
+}
+
+// ResumeAnalytics sums up the views of a resume over the last days, with a series of every day.
+func (r *ResumeController) ResumeAnalytics(c *gin.Context) {
+	var request struct {
+		Days int `form:"days" binding:"omitempty,min=1,max=365"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	if request.Days == 0 {
+		request.Days = defaultAnalyticsDays
+	}
+	userId := auth.GetUserIdFromContext(c)
+	resumeId := c.Param("resume_id")
+	if _, err := r.ownResume(c, userId, resumeId); err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-request.Days)
+	analytics, err := r.resumeStore.ResumeAnalytics(c, userId, resumeId, since)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"views":          analytics.Views,
+		"unique_viewers": analytics.UniqueViewers,
+		"clients":        analytics.Clients,
+		"series":         everyDay(analytics.Days, since, today),
+	})
+}
+
+// everyDay fills in the days without views, so the series can be charted as it is.
+func everyDay(days []database.DayViews, since, until time.Time) []database.DayViews {
+	byDate := map[string]database.DayViews{}
+	for _, day := range days {
+		byDate[day.Date] = day
+	}
+	series := []database.DayViews{}
+	for day := since; !day.After(until); day = day.AddDate(0, 0, 1) {
+		date := day.Format(database.DayFormat)
+		views, ok := byDate[date]
+		if !ok {
+			views = database.DayViews{Date: date}
+		}
+		series = append(series, views)
+	}
+	return series
+}
+
+// recordView records a download by someone other than the owner, link is nil for public resumes. Analytics don't
+// fail the download, errors are only logged.
+func (r *ResumeController) recordView(c *gin.Context, resume model.Resume, link *model.ShareLink) {
+	view := model.ResumeView{
+		ResumeID:   resume.ID,
+		UserID:     resume.UserID,
+		Time:       time.Now(),
+		Client:     classifyClient(c.Request.UserAgent()),
+		ViewerHash: r.viewerHash(c.ClientIP()),
+	}
+	if link != nil {
+		view.ShareLinkID = &link.ID
+	}
+	if err := r.resumeStore.RecordResumeView(c, view); err != nil {
+		logging.FromContext(c).Warn("Cannot record resume view", "resume_id", resume.ID.Hex(), "error", err)
+	}
+}
+
+// notifyFirstView emails the owner in the background, the download doesn't wait for the mail server.
+func (r *ResumeController) notifyFirstView(c *gin.Context, resume model.Resume, link model.ShareLink) {
+	logger := logging.FromContext(c)
+	r.analytics.Jobs.Go("share link viewed", func(ctx context.Context) {
+		ctx = logging.WithLogger(ctx, logger)
+		owner, err := r.analytics.Users.GetUser(ctx, resume.UserID)
+		if err != nil {
+			logger.ErrorContext(ctx, "Cannot look up owner of shared resume", "resume_id", resume.ID.Hex(), "error", err)
+			return
+		}
+		// failures are logged by the email client
+		_ = r.analytics.Mailer.SendShareLinkViewedMail(ctx, owner.Email, resume.FileName, link.Prefix)
+	})
+}
+
+func (r *ResumeController) viewerHash(ip string) string {
+	mac := hmac.New(sha256.New, r.analytics.ViewerHashKey)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// classifyClient sorts user agents coarsely, anything finer would tell viewers apart.
+func classifyClient(userAgent string) string {
+	agent := strings.ToLower(userAgent)
+	for _, bot := range botAgents {
+		if strings.Contains(agent, bot) {
+			return clientBot
+		}
+	}
+	switch {
+	case strings.Contains(agent, "mobile") || strings.Contains(agent, "android") || strings.Contains(agent, "iphone") || strings.Contains(agent, "ipad"):
+		return clientMobile
+	case strings.HasPrefix(agent, "mozilla/"):
+		return clientDesktop
+	default:
+		return clientOther
+	}
+}
@@ -0,0 +1,103 @@
+package resume
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestClassifyClient(t *testing.T) {
+	for userAgent, client := range map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36":                clientDesktop,
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148": clientMobile,
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36":                   clientMobile,
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                                   clientBot,
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)":                                                                 clientBot,
+		"curl/8.4.0":  clientBot,
+		"":            clientOther,
+		"SomeApp/1.0": clientOther,
+	} {
+		if got := classifyClient(userAgent); got != client {
+			t.Errorf("Expected %q to be %s, got %s", userAgent, client, got)
+		}
+	}
+}
+
+func TestEveryDay(t *testing.T) {
+	since := time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC)
+	series := everyDay([]database.DayViews{{Date: "2024-02-29", Views: 3, UniqueViewers: 2}}, since, since.AddDate(0, 0, 2))
+	expected := []database.DayViews{{Date: "2024-02-28"}, {Date: "2024-02-29", Views: 3, UniqueViewers: 2}, {Date: "2024-03-01"}}
+	if len(series) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, series)
+	}
+	for i := range expected {
+		if series[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected, series)
+		}
+	}
+}
+
+func TestResumeAnalytics(t *testing.T) {
+	s := newTestServer()
+	owner, err := s.users.CreateUser(context.Background(), model.User{Email: "owner@example.com"})
+	if err != nil {
+		t.Fatalf("Cannot create user: %v", err)
+	}
+	resume := s.storeResume(t, owner.ID, true)
+	id := resume.ID.Hex()
+
+	// the owner's own downloads don't count
+	s.request(owner.ID, httptest.NewRequest(http.MethodGet, "/api/download-resume/"+id, nil))
+	req := httptest.NewRequest(http.MethodGet, "/api/download-resume/"+id, nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148")
+	if w := s.request(primitive.NewObjectID(), req); w.Code != http.StatusOK {
+		t.Fatalf("Expected the public resume to download, got %d %s", w.Code, w.Body.String())
+	}
+
+	link, token := createShareLink(t, s, owner.ID, id, `{"notify_on_view": true}`)
+	if !link.NotifyOnView {
+		t.Errorf("Expected the link to notify, got %+v", link)
+	}
+	for _, remoteAddr := range []string{"198.51.100.7:4000", "203.0.113.9:5000"} {
+		req = httptest.NewRequest(http.MethodGet, "/api/share/"+token, nil)
+		req.RemoteAddr = remoteAddr
+		if w := download(s, req); w.Code != http.StatusOK {
+			t.Fatalf("Expected the shared resume to download, got %d %s", w.Code, w.Body.String())
+		}
+	}
+	if err = s.jobs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Cannot wait for background jobs: %v", err)
+	}
+	if len(s.mailer.mails) != 1 || s.mailer.mails[0] != "owner@example.com cv.pdf "+link.Prefix {
+		t.Errorf("Expected one mail about the first view, got %q", s.mailer.mails)
+	}
+
+	w := s.request(owner.ID, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/analytics?days=7", nil))
+	var response struct {
+		Views         int64               `json:"views"`
+		UniqueViewers int64               `json:"unique_viewers"`
+		Clients       map[string]int64    `json:"clients"`
+		Series        []database.DayViews `json:"series"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the analytics, got %d %s", w.Code, w.Body.String())
+	}
+	// the public download came from the default test address, the link was used from two others
+	if response.Views != 3 || response.UniqueViewers != 3 || response.Clients[clientMobile] != 1 || response.Clients[clientOther] != 2 {
+		t.Errorf("Expected 3 views by 3 viewers, got %+v", response)
+	}
+	today := time.Now().UTC().Format(database.DayFormat)
+	if len(response.Series) != 7 || response.Series[6].Date != today || response.Series[6].Views != 3 || response.Series[0].Views != 0 {
+		t.Errorf("Expected 7 days ending today, got %+v", response.Series)
+	}
+
+	expectError(t, s.request(owner.ID, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/analytics?days=400", nil)), http.StatusBadRequest, "bad_request")
+	expectError(t, s.request(primitive.NewObjectID(), httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/analytics", nil)), http.StatusNotFound, "resume_not_found")
+}
@@ -0,0 +1,63 @@
+package resume
+
+import (
+	"context"
+	"resume-service/internal/database"
+	"resume-service/internal/logging"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const backfillBatchSize = 100
+
+// BackfillContent extracts the text of resumes that have none, like those uploaded before it was kept for search,
+// until every one was tried or stop is closed. Resumes that can't be read stay without text and are tried again
+// by the next backfill.
+func (r *ResumeController) BackfillContent(ctx context.Context, stop <-chan struct{}) {
+	logger := logging.FromContext(ctx)
+	after, filled, unreadable := primitive.NilObjectID, 0, 0
+	defer func() {
+		if filled > 0 || unreadable > 0 {
+			logger.InfoContext(ctx, "Backfilled resume text", "filled", filled, "unreadable", unreadable)
+		}
+	}()
+
+	for {
+		resumes, err := r.resumeStore.GetResumesWithoutContent(ctx, after, backfillBatchSize)
+		if err != nil {
+			logger.ErrorContext(ctx, "Cannot find resumes to backfill", "error", err)
+			return
+		}
+		if len(resumes) == 0 {
+			return
+		}
+		for _, resume := range resumes {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			after = resume.ID
+
+			fileContent, err := r.fileStorage.Download(ctx, resume.Key)
+			if err != nil {
+				logger.ErrorContext(ctx, "Cannot download resume to backfill", "resume_id", resume.ID.Hex(), "error", err)
+				continue
+			}
+			content, err := parsePDF(ctx, fileContent)
+			if err != nil || content == "" {
+				unreadable++
+				continue
+			}
+			err = r.resumeStore.SetResumeContent(ctx, resume.ID, resume.Key, content)
+			switch {
+			case database.IsNotFound(err):
+				// a new version uploaded meanwhile brought its own text
+			case err != nil:
+				logger.ErrorContext(ctx, "Cannot store backfilled resume text", "resume_id", resume.ID.Hex(), "error", err)
+			default:
+				filled++
+			}
+		}
+	}
+}
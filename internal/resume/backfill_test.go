@@ -0,0 +1,37 @@
+package resume
+
+import (
+	"context"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBackfillContent(t *testing.T) {
+	s := newTestServer()
+	controller := NewResumeController(s.storage, s.store, s.generator, 2, Analytics{})
+	userId := primitive.NewObjectID()
+	first := s.storeResume(t, userId, false)
+	_, err := s.store.StoreResume(context.Background(), model.Resume{UserID: userId, FileName: "cv.pdf", Key: "user-read", UploadDate: time.Now(), Content: "Go developer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := s.storeResume(t, primitive.NewObjectID(), false)
+
+	stopped := make(chan struct{})
+	close(stopped)
+	controller.BackfillContent(context.Background(), stopped)
+	if len(s.storage.downloaded) != 0 {
+		t.Errorf("Expected a stopped backfill not to read files, got %v", s.storage.downloaded)
+	}
+
+	controller.BackfillContent(context.Background(), make(chan struct{}))
+	if downloaded := s.storage.downloaded; len(downloaded) != 2 || downloaded[0] != first.Key || downloaded[1] != second.Key {
+		t.Errorf("Expected only the files of resumes without text to be read, got %v", downloaded)
+	}
+	if resume, _ := s.store.GetResume(context.Background(), first.ID.Hex()); resume.Content != "" {
+		t.Errorf("Expected an unreadable resume to stay without text, got %q", resume.Content)
+	}
+}
@@ -1,33 +1,63 @@
 package resume
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"resume-service/internal/apperror"
 	"resume-service/internal/auth"
-	"resume-service/internal/clients/filestore"
-	"resume-service/internal/clients/mlclient"
 	"resume-service/internal/database"
+	"resume-service/internal/logging"
 	"resume-service/internal/model"
-	"resume-service/internal/utils"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var (
+	errResumeNotFound   = apperror.New(http.StatusNotFound, "resume_not_found", "Resume not found")
+	errFileRequired     = apperror.New(http.StatusBadRequest, "file_required", "Upload a resume file")
+	errResumeIdRequired = apperror.New(http.StatusBadRequest, "resume_id_required", "Resume ID is required")
+	errResumeUnreadable = apperror.New(http.StatusUnprocessableEntity, "resume_unreadable", "Could not read the text of this resume")
+	errGenerationFailed = apperror.New(http.StatusBadGateway, "generation_failed", "Failed to generate cover letter, try again later")
+	errInvalidFileName  = apperror.New(http.StatusBadRequest, "invalid_file_name", "File names can't be empty or contain slashes or quotes")
+	errInvalidCursor    = apperror.New(http.StatusBadRequest, "invalid_cursor", "The cursor is not valid, start again from the first page")
+)
+
+const defaultPageSize = 20
+
+// FileStorage is the part of filestore.FileStore the controller uses.
+type FileStorage interface {
+	Upload(ctx context.Context, key string, fileContent []byte) error
+	Download(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// CoverLetterGenerator is the part of mlclient.MLClient the controller uses.
+type CoverLetterGenerator interface {
+	GenerateCoverLetter(ctx context.Context, jobDesc, resumeText string) (string, error)
+}
+
 type ResumeController struct {
-	fileStorage *filestore.FileStore
-	resumeStore *database.ResumeStore
-	mlclient    *mlclient.MLClient
+	fileStorage FileStorage
+	resumeStore database.ResumeRepository
+	mlclient    CoverLetterGenerator
+	// versionsKept is how many previous versions of a resume survive a new upload
+	versionsKept int
+	analytics    Analytics
 }
 
-func NewResumeController(fileStorage *filestore.FileStore, store *database.ResumeStore, mlclient *mlclient.MLClient) *ResumeController {
-	return &ResumeController{fileStorage: fileStorage, resumeStore: store, mlclient: mlclient}
+func NewResumeController(fileStorage FileStorage, store database.ResumeRepository, mlclient CoverLetterGenerator, versionsKept int, analytics Analytics) *ResumeController {
+	return &ResumeController{fileStorage: fileStorage, resumeStore: store, mlclient: mlclient, versionsKept: versionsKept, analytics: analytics}
 }
 
 func (r *ResumeController) UploadResume(c *gin.Context) {
@@ -36,38 +66,39 @@ func (r *ResumeController) UploadResume(c *gin.Context) {
 		Tags []string              `form:"tags"`
 	}
 	if err := c.ShouldBind(&request); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
-	file, err := request.File.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+	if request.File == nil {
+		apperror.Abort(c, errFileRequired)
 		return
 	}
-
-	fileContent, err := io.ReadAll(file)
+	if !validFileName(request.File.Filename) {
+		apperror.Abort(c, errInvalidFileName)
+		return
+	}
+	fileContent, err := readFile(request.File)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	tags := []string{}
-	if request.Tags != nil && len(request.Tags) > 0 {
-		for _, tag := range request.Tags {
-			tags = append(tags, strings.ReplaceAll(tag, " ", ""))
-		}
+	tags, err := normalizeTags(request.Tags)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
 	}
 
 	key := fmt.Sprintf("user-%s-%s", auth.GetUserIdFromContext(c).String(), uuid.New())
 
-	err = r.fileStorage.Upload(key, fileContent)
+	err = r.fileStorage.Upload(c, key, fileContent)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 	resume := model.Resume{
@@ -77,11 +108,12 @@ func (r *ResumeController) UploadResume(c *gin.Context) {
 		UploadDate: time.Now(),
 		Tags:       tags,
 		Public:     false,
+		Content:    extractContent(c, fileContent),
 	}
 
 	resume, err = r.resumeStore.StoreResume(c, resume)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
@@ -93,31 +125,39 @@ func (r *ResumeController) UploadResumePublic(c *gin.Context) {
 		File *multipart.FileHeader `form:"file"`
 	}
 	if err := c.ShouldBind(&request); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	if request.File == nil {
+		apperror.Abort(c, errFileRequired)
+		return
+	}
+	if !validFileName(request.File.Filename) {
+		apperror.Abort(c, errInvalidFileName)
 		return
 	}
 	file, err := request.File.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
 	fileContent, err := io.ReadAll(file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
 	key := fmt.Sprintf("temp-%s-%s", uuid.New(), uuid.New())
 
-	err = r.fileStorage.Upload(key, fileContent)
+	err = r.fileStorage.Upload(c, key, fileContent)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 	resume := model.TemporaryResume{
@@ -128,7 +168,7 @@ func (r *ResumeController) UploadResumePublic(c *gin.Context) {
 
 	resume, err = r.resumeStore.StoreTemporaryResume(c, resume)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
@@ -136,59 +176,127 @@ func (r *ResumeController) UploadResumePublic(c *gin.Context) {
 }
 
 func (r *ResumeController) ListResumes(c *gin.Context) {
-	userId := auth.GetUserIdFromContext(c)
+	var request struct {
+		Limit          int64     `form:"limit" binding:"omitempty,min=1,max=100"`
+		Cursor         string    `form:"cursor"`
+		Tags           []string  `form:"tags"`
+		Visibility     string    `form:"visibility" binding:"omitempty,oneof=public private"`
+		UploadedAfter  time.Time `form:"uploaded_after" time_format:"2006-01-02T15:04:05Z07:00"`
+		UploadedBefore time.Time `form:"uploaded_before" time_format:"2006-01-02T15:04:05Z07:00"`
+		Sort           string    `form:"sort" binding:"omitempty,oneof=upload_date file_name"`
+		Order          string    `form:"order" binding:"omitempty,oneof=asc desc"`
+		Query          string    `form:"q"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
 
-	resumes, err := r.resumeStore.GetResumesByUserId(c, userId)
+	tags, err := normalizeTags(request.Tags)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, err)
+		return
+	}
+	query := database.ResumeQuery{
+		Tags:           tags,
+		UploadedAfter:  request.UploadedAfter,
+		UploadedBefore: request.UploadedBefore,
+		Search:         strings.TrimSpace(request.Query),
+		Sort:           database.ResumeSort(request.Sort),
+		Limit:          request.Limit,
+	}
+	if query.Sort == "" {
+		query.Sort = database.SortByUploadDate
+	}
+	// newest first unless asked otherwise, names go a to z
+	query.Descending = request.Order == "desc" || (request.Order == "" && query.Sort == database.SortByUploadDate)
+	if query.Limit == 0 {
+		query.Limit = defaultPageSize
+	}
+	if request.Visibility != "" {
+		public := request.Visibility == "public"
+		query.Public = &public
+	}
+	if request.Cursor != "" {
+		cursor, err := database.DecodeResumeCursor(request.Cursor)
+		if err != nil {
+			apperror.Abort(c, errInvalidCursor)
+			return
+		}
+		query.After = cursor
+	}
+
+	page, err := r.resumeStore.ListResumes(c, auth.GetUserIdFromContext(c), query)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"resumes": resumes})
+	response := gin.H{"resumes": page.Resumes, "next_cursor": nil}
+	if page.Next != nil {
+		response["next_cursor"] = page.Next.Encode()
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (r *ResumeController) DownloadResume(c *gin.Context) {
 	resumeId := c.Param("resume_id")
 	if resumeId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Now resume id found"})
+		apperror.Abort(c, errResumeIdRequired)
 		return
 	}
 
 	resume, err := r.resumeStore.GetResume(c, resumeId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+		resumeNotFoundOrError(c, err)
 		return
 	}
 
+	// private resumes of other users look the same as missing ones
 	userId := auth.GetUserIdFromContext(c)
 	if !resume.Public && resume.UserID != userId {
-		c.JSON(http.StatusUnauthorized, utils.GinError(errors.New("not allowed to download resume")))
+		apperror.Abort(c, errResumeNotFound)
 		return
 	}
 
-	file, err := r.fileStorage.Download(resume.Key)
+	file, err := r.fileStorage.Download(c, resume.Key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
+	if resume.UserID != userId {
+		r.recordView(c, resume, nil)
+	}
+
+	sendAttachment(c, resume.FileName, file)
+}
 
-	c.Header("Content-Disposition", "attachment; filename="+resume.FileName)
+// sendAttachment sends a pdf to be saved under fileName, quoted and encoded as the header needs.
+func sendAttachment(c *gin.Context, fileName string, file []byte) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
 	c.Data(http.StatusOK, "application/pdf", file)
 }
 
 func (r *ResumeController) DeleteResume(c *gin.Context) {
 	resumeId := c.Param("resume_id")
 	if resumeId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Now resume id found"})
+		apperror.Abort(c, errResumeIdRequired)
 		return
 	}
 
 	userId := auth.GetUserIdFromContext(c)
-	err := r.resumeStore.DeleteResume(c, userId, resumeId)
+	// looked up first, the versions are deleted with the resume
+	keys, err := r.fileKeys(c, userId, resumeId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
+	err = r.resumeStore.DeleteResume(c, userId, resumeId)
+	if err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+	r.deleteFiles(c, keys)
 
 	c.JSON(http.StatusOK, gin.H{"message": "delete successful"})
 }
@@ -197,63 +305,106 @@ func (r *ResumeController) UpdateResumeVisibility(c *gin.Context) {
 	userId := auth.GetUserIdFromContext(c)
 	resumeId := c.Param("resume_id")
 	if resumeId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "resume_id not found"})
+		apperror.Abort(c, errResumeIdRequired)
 		return
 	}
 
 	isPublic, err := strconv.ParseBool(c.DefaultQuery("public", "false"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
 
 	err = r.resumeStore.UpdateUserResumeIsPublic(c, userId, resumeId, isPublic)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		resumeNotFoundOrError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Resume visibility updated"})
 }
 
+func (r *ResumeController) UpdateResume(c *gin.Context) {
+	var request struct {
+		FileName    *string  `json:"file_name" binding:"omitempty,max=255"`
+		Title       *string  `json:"title" binding:"omitempty,max=200"`
+		Description *string  `json:"description" binding:"omitempty,max=2000"`
+		Tags        []string `json:"tags"`
+		Public      *bool    `json:"public"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	update := database.ResumeUpdate{Description: request.Description, Public: request.Public}
+	if request.FileName != nil {
+		fileName := strings.TrimSpace(*request.FileName)
+		if !validFileName(fileName) {
+			apperror.Abort(c, errInvalidFileName)
+			return
+		}
+		update.FileName = &fileName
+	}
+	if request.Title != nil {
+		title := strings.TrimSpace(*request.Title)
+		update.Title = &title
+	}
+	if request.Tags != nil {
+		tags, err := normalizeTags(request.Tags)
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		update.Tags = tags
+	}
+
+	resume, err := r.resumeStore.UpdateResume(c, auth.GetUserIdFromContext(c), c.Param("resume_id"), update)
+	if err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resume": resume})
+}
+
 func (r *ResumeController) GenerateCoverletter(c *gin.Context) {
 	var request struct {
 		ResumeId string `json:"resume_id"`
 		JobDesc  string `json:"job_desc"`
 	}
 
-	if err := c.BindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
 
 	if request.ResumeId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Resume ID and Job Description are required"})
+		apperror.Abort(c, errResumeIdRequired)
 		return
 	}
 
 	resume, err := r.resumeStore.GetResume(c, request.ResumeId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		resumeNotFoundOrError(c, err)
 		return
 	}
 
 	// verify if user can read this resume
 	if resume.UserID != auth.GetUserIdFromContext(c) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Abort(c, errResumeNotFound)
 		return
 	}
 
 	// download PDF from S3
-	fileContent, err := r.fileStorage.Download(resume.Key)
+	fileContent, err := r.fileStorage.Download(c, resume.Key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
 	// Extract text from the PDF
-	resumeText, err := parsePDF(fileContent)
+	resumeText, err := parsePDF(c, fileContent)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse resume text"})
+		apperror.Abort(c, errResumeUnreadable.Wrap(err))
 		return
 	}
 	//c.JSON(http.StatusOK, gin.H{"cover_letter": resumeText})
@@ -261,7 +412,7 @@ func (r *ResumeController) GenerateCoverletter(c *gin.Context) {
 	// Generate the cover letter
 	coverLetter, err := r.mlclient.GenerateCoverLetter(c, request.JobDesc, resumeText)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate cover letter"})
+		apperror.Abort(c, errGenerationFailed.Wrap(err))
 		return
 	}
 
@@ -274,33 +425,33 @@ func (r *ResumeController) GenerateCoverletterPublic(c *gin.Context) {
 		JobDesc  string `json:"job_desc"`
 	}
 
-	if err := c.BindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
 
 	if request.ResumeId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Resume ID and Job Description are required"})
+		apperror.Abort(c, errResumeIdRequired)
 		return
 	}
 
 	resume, err := r.resumeStore.GetTemporaryResume(c, request.ResumeId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		resumeNotFoundOrError(c, err)
 		return
 	}
 
 	// download PDF from S3
-	fileContent, err := r.fileStorage.Download(resume.Key)
+	fileContent, err := r.fileStorage.Download(c, resume.Key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
 	// Extract text from the PDF
-	resumeText, err := parsePDF(fileContent)
+	resumeText, err := parsePDF(c, fileContent)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse resume text"})
+		apperror.Abort(c, errResumeUnreadable.Wrap(err))
 		return
 	}
 	//c.JSON(http.StatusOK, gin.H{"cover_letter": resumeText})
@@ -308,9 +459,41 @@ func (r *ResumeController) GenerateCoverletterPublic(c *gin.Context) {
 	// Generate the cover letter
 	coverLetter, err := r.mlclient.GenerateCoverLetter(c, request.JobDesc, resumeText)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate cover letter"})
+		apperror.Abort(c, errGenerationFailed.Wrap(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"cover_letter": coverLetter})
 }
+
+// validFileName keeps file names safe to send back in the Content-Disposition of downloads.
+func validFileName(fileName string) bool {
+	if fileName == "" || strings.ContainsAny(fileName, `/\"`) {
+		return false
+	}
+	for _, r := range fileName {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// extractContent gets the text of a resume for searching. Unreadable resumes are still stored, they can only be
+// found by file name.
+func extractContent(c *gin.Context, fileContent []byte) string {
+	text, err := parsePDF(c, fileContent)
+	if err != nil {
+		logging.FromContext(c).Warn("Cannot extract resume text for search", "error", err)
+		return ""
+	}
+	return text
+}
+
+func resumeNotFoundOrError(c *gin.Context, err error) {
+	if database.IsNotFound(err) || errors.Is(err, primitive.ErrInvalidHex) {
+		apperror.Abort(c, errResumeNotFound)
+		return
+	}
+	apperror.Abort(c, apperror.Internal(err))
+}
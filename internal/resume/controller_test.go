@@ -0,0 +1,478 @@
+package resume
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"resume-service/internal/background"
+	"resume-service/internal/database"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/model"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeStorage struct {
+	mu         sync.Mutex
+	files      map[string][]byte
+	err        error
+	downloaded []string
+}
+
+func (s *fakeStorage) Upload(ctx context.Context, key string, fileContent []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.files[key] = fileContent
+	return nil
+}
+
+func (s *fakeStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+func (s *fakeStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.downloaded = append(s.downloaded, key)
+	content, ok := s.files[key]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return content, nil
+}
+
+type fakeGenerator struct {
+	calls int
+}
+
+func (g *fakeGenerator) GenerateCoverLetter(ctx context.Context, jobDesc, resumeText string) (string, error) {
+	g.calls++
+	return "Dear hiring manager", nil
+}
+
+// fakeMailer records the mails instead of sending them, it's only used by background jobs.
+type fakeMailer struct {
+	mu    sync.Mutex
+	mails []string
+}
+
+func (m *fakeMailer) SendShareLinkViewedMail(ctx context.Context, to string, fileName string, linkPrefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, fmt.Sprintf("%s %s %s", to, fileName, linkPrefix))
+	return nil
+}
+
+type testServer struct {
+	router    *gin.Engine
+	store     *memory.ResumeStore
+	users     *memory.UserStore
+	storage   *fakeStorage
+	generator *fakeGenerator
+	mailer    *fakeMailer
+	jobs      *background.Jobs
+}
+
+func newTestServer() *testServer {
+	gin.SetMode(gin.TestMode)
+	s := &testServer{
+		store:     memory.NewResumeStore(),
+		storage:   &fakeStorage{files: map[string][]byte{}},
+		generator: &fakeGenerator{},
+		users:     memory.NewUserStore(),
+		mailer:    &fakeMailer{},
+		jobs:      background.NewJobs(),
+	}
+	analytics := Analytics{ViewerHashKey: []byte("test"), Users: s.users, Mailer: s.mailer, Jobs: s.jobs}
+	controller := NewResumeController(s.storage, s.store, s.generator, 2, analytics)
+
+	s.router = gin.New()
+	s.router.Use(apperror.Middleware())
+	// stands in for auth.Middleware, the user is taken from a header
+	authed := s.router.Group("/api", func(c *gin.Context) { c.Set("userID", c.GetHeader("X-Test-User")) })
+	authed.PUT("/upload-resume", controller.UploadResume)
+	s.router.PUT("/api/upload-resume-public", controller.UploadResumePublic)
+	authed.GET("/list-resumes", controller.ListResumes)
+	authed.GET("/download-resume/:resume_id", controller.DownloadResume)
+	authed.DELETE("/delete-resume/:resume_id", controller.DeleteResume)
+	authed.POST("/update-resume-visibility/:resume_id", controller.UpdateResumeVisibility)
+	authed.PATCH("/resumes/:resume_id", controller.UpdateResume)
+	authed.GET("/tags", controller.ListTags)
+	authed.PUT("/tags/:tag", controller.RenameTag)
+	authed.DELETE("/tags/:tag", controller.DeleteTag)
+	authed.PUT("/resumes/:resume_id/file", controller.UploadResumeVersion)
+	authed.GET("/resumes/:resume_id/versions", controller.ListResumeVersions)
+	authed.GET("/resumes/:resume_id/versions/:version/file", controller.DownloadResumeVersion)
+	authed.POST("/resumes/:resume_id/versions/:version/restore", controller.RestoreResumeVersion)
+	authed.DELETE("/resumes/:resume_id/versions", controller.PruneResumeVersions)
+	authed.GET("/resumes/:resume_id/diff", controller.DiffResumes)
+	authed.POST("/resumes/:resume_id/share-links", controller.CreateShareLink)
+	authed.GET("/share-links", controller.ListShareLinks)
+	authed.DELETE("/share-links/:link_id", controller.RevokeShareLink)
+	authed.GET("/resumes/:resume_id/analytics", controller.ResumeAnalytics)
+	authed.POST("/generate-cover-letter", controller.GenerateCoverletter)
+	s.router.GET("/api/share/:token", controller.DownloadSharedResume)
+	s.router.POST("/api/share/:token", controller.DownloadSharedResume)
+	return s
+}
+
+func (s *testServer) request(userId primitive.ObjectID, req *http.Request) *httptest.ResponseRecorder {
+	req.Header.Set("X-Test-User", userId.Hex())
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *testServer) storeResume(t *testing.T, userId primitive.ObjectID, public bool) model.Resume {
+	t.Helper()
+	key := "user-" + primitive.NewObjectID().Hex()
+	s.storage.files[key] = []byte("%PDF-1.4")
+	resume, err := s.store.StoreResume(context.Background(), model.Resume{UserID: userId, FileName: "cv.pdf", Key: key, UploadDate: time.Now(), Public: public})
+	if err != nil {
+		t.Fatalf("Cannot store resume: %v", err)
+	}
+	return resume
+}
+
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	var response apperror.Response
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != status || response.Code != code {
+		t.Errorf("Expected %d %s, got %d %s", status, code, w.Code, w.Body.String())
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "cv.pdf")
+	_, _ = file.Write([]byte("%PDF-1.4"))
+	_ = form.WriteField("tags", "back end")
+	_ = form.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/upload-resume", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	w := s.request(userId, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the upload to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	resumes, _ := s.store.GetResumesByUserId(context.Background(), userId)
+	if len(resumes) != 1 || resumes[0].FileName != "cv.pdf" || len(resumes[0].Tags) != 1 || resumes[0].Tags[0] != "backend" {
+		t.Fatalf("Expected the resume to be stored with its tags, got %+v", resumes)
+	}
+	if string(s.storage.files[resumes[0].Key]) != "%PDF-1.4" {
+		t.Errorf("Expected the file to be uploaded under the resume's key")
+	}
+
+	body.Reset()
+	form = multipart.NewWriter(&body)
+	_ = form.WriteField("tags", "backend")
+	_ = form.Close()
+	req = httptest.NewRequest(http.MethodPut, "/api/upload-resume", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	expectError(t, s.request(userId, req), http.StatusBadRequest, "file_required")
+}
+
+func TestUploadResumeRefusesUnsafeFileNames(t *testing.T) {
+	s := newTestServer()
+	for _, path := range []string{"/api/upload-resume", "/api/upload-resume-public"} {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, _ := form.CreateFormFile("file", `cv"; filename="other.pdf`)
+		_, _ = file.Write([]byte("%PDF-1.4"))
+		_ = form.Close()
+		req := httptest.NewRequest(http.MethodPut, path, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		expectError(t, s.request(primitive.NewObjectID(), req), http.StatusBadRequest, "invalid_file_name")
+	}
+	if len(s.storage.files) != 0 {
+		t.Errorf("Expected nothing to be uploaded, got %v", s.storage.files)
+	}
+}
+
+func TestDownloadResumeQuotesFileName(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	resume := s.storeResume(t, userId, false)
+	name := "my cv.pdf"
+	if _, err := s.store.UpdateResume(context.Background(), userId, resume.ID.Hex(), database.ResumeUpdate{FileName: &name}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/download-resume/"+resume.ID.Hex(), nil))
+	if disposition := w.Header().Get("Content-Disposition"); w.Code != http.StatusOK || disposition != `attachment; filename="my cv.pdf"` {
+		t.Errorf("Expected the file name to be quoted, got %d %q", w.Code, disposition)
+	}
+}
+
+func TestListResumesOnlyListsOwnResumes(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	own := s.storeResume(t, userId, false)
+	s.storeResume(t, primitive.NewObjectID(), true)
+
+	w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/list-resumes", nil))
+	var response struct {
+		Resumes []model.Resume `json:"resumes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected a list, got %d %s", w.Code, w.Body.String())
+	}
+	if len(response.Resumes) != 1 || response.Resumes[0].ID != own.ID {
+		t.Errorf("Expected only the user's resume, got %+v", response.Resumes)
+	}
+}
+
+func TestListResumesPages(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	for i := 0; i < 3; i++ {
+		s.storeResume(t, userId, i == 0)
+	}
+
+	type listResponse struct {
+		Resumes    []model.Resume `json:"resumes"`
+		NextCursor *string        `json:"next_cursor"`
+	}
+	list := func(query string) listResponse {
+		t.Helper()
+		w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/list-resumes?"+query, nil))
+		var response listResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Expected a list for %q, got %d %s", query, w.Code, w.Body.String())
+		}
+		return response
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	query := "limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatal("Expected the pages to end")
+		}
+		response := list(query)
+		for _, resume := range response.Resumes {
+			if seen[resume.ID] {
+				t.Errorf("Expected each resume once, got %s again", resume.ID.Hex())
+			}
+			seen[resume.ID] = true
+		}
+		if response.NextCursor == nil {
+			break
+		}
+		query = "limit=2&cursor=" + *response.NextCursor
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected all 3 resumes over the pages, got %d", len(seen))
+	}
+
+	if response := list("visibility=public"); len(response.Resumes) != 1 || !response.Resumes[0].Public {
+		t.Errorf("Expected only the public resume, got %+v", response.Resumes)
+	}
+
+	w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/list-resumes?cursor=nonsense", nil))
+	expectError(t, w, http.StatusBadRequest, "invalid_cursor")
+	w = s.request(userId, httptest.NewRequest(http.MethodGet, "/api/list-resumes?sort=size", nil))
+	expectError(t, w, http.StatusBadRequest, "bad_request")
+	w = s.request(userId, httptest.NewRequest(http.MethodGet, "/api/list-resumes?limit=1000", nil))
+	expectError(t, w, http.StatusBadRequest, "bad_request")
+}
+
+func TestDownloadResume(t *testing.T) {
+	s := newTestServer()
+	userId, otherId := primitive.NewObjectID(), primitive.NewObjectID()
+	private := s.storeResume(t, otherId, false)
+	public := s.storeResume(t, otherId, true)
+	own := s.storeResume(t, userId, false)
+
+	download := func(id string) *httptest.ResponseRecorder {
+		return s.request(userId, httptest.NewRequest(http.MethodGet, "/api/download-resume/"+id, nil))
+	}
+
+	for _, resume := range []model.Resume{own, public} {
+		if w := download(resume.ID.Hex()); w.Code != http.StatusOK || w.Body.String() != "%PDF-1.4" {
+			t.Errorf("Expected to download %s, got %d", resume.ID.Hex(), w.Code)
+		}
+	}
+	expectError(t, download(private.ID.Hex()), http.StatusNotFound, "resume_not_found")
+	expectError(t, download("not-an-id"), http.StatusNotFound, "resume_not_found")
+
+	s.storage.err = errors.New("RequestTimeout")
+	expectError(t, download(own.ID.Hex()), http.StatusInternalServerError, "internal_error")
+}
+
+func TestDeleteResume(t *testing.T) {
+	s := newTestServer()
+	userId, otherId := primitive.NewObjectID(), primitive.NewObjectID()
+	own := s.storeResume(t, userId, false)
+	others := s.storeResume(t, otherId, true)
+
+	for _, resume := range []model.Resume{own, others} {
+		if w := s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/delete-resume/"+resume.ID.Hex(), nil)); w.Code != http.StatusOK {
+			t.Errorf("Expected deleting to respond ok, got %d", w.Code)
+		}
+	}
+
+	if _, err := s.store.GetResume(context.Background(), own.ID.Hex()); err == nil {
+		t.Error("Expected the user's resume to be deleted")
+	}
+	if _, err := s.store.GetResume(context.Background(), others.ID.Hex()); err != nil {
+		t.Errorf("Expected another user's resume to be kept, got %v", err)
+	}
+}
+
+func TestUpdateResumeVisibility(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	own := s.storeResume(t, userId, false)
+	others := s.storeResume(t, primitive.NewObjectID(), false)
+
+	update := func(id string, query string) *httptest.ResponseRecorder {
+		return s.request(userId, httptest.NewRequest(http.MethodPost, "/api/update-resume-visibility/"+id+query, nil))
+	}
+
+	if w := update(own.ID.Hex(), "?public=true"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the update to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if resume, _ := s.store.GetResume(context.Background(), own.ID.Hex()); !resume.Public {
+		t.Error("Expected the resume to be public")
+	}
+	expectError(t, update(own.ID.Hex(), "?public=maybe"), http.StatusBadRequest, "bad_request")
+	expectError(t, update(others.ID.Hex(), "?public=true"), http.StatusNotFound, "resume_not_found")
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestUpdateResume(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	own := s.storeResume(t, userId, false)
+	others := s.storeResume(t, primitive.NewObjectID(), false)
+
+	update := func(id string, body string) *httptest.ResponseRecorder {
+		return s.request(userId, jsonRequest(http.MethodPatch, "/api/resumes/"+id, body))
+	}
+
+	w := update(own.ID.Hex(), `{"file_name": " backend.pdf ", "title": "Backend", "tags": ["Go", "Back End", "go"], "public": true}`)
+	var response struct {
+		Resume model.Resume `json:"resume"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the update to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	updated := response.Resume
+	if updated.FileName != "backend.pdf" || updated.Title != "Backend" || strings.Join(updated.Tags, ",") != "go,backend" || !updated.Public {
+		t.Errorf("Expected the fields to be updated and the tags normalized, got %+v", updated)
+	}
+
+	// fields left out are kept
+	w = update(own.ID.Hex(), `{"description": "Five years of Go"}`)
+	if stored, _ := s.store.GetResume(context.Background(), own.ID.Hex()); w.Code != http.StatusOK || stored.Title != "Backend" || stored.Description != "Five years of Go" {
+		t.Errorf("Expected only the description to change, got %d %+v", w.Code, stored)
+	}
+
+	expectError(t, update(own.ID.Hex(), `{"file_name": "../cv.pdf"}`), http.StatusBadRequest, "invalid_file_name")
+	expectError(t, update(own.ID.Hex(), `{"file_name": "  "}`), http.StatusBadRequest, "invalid_file_name")
+	expectError(t, update(own.ID.Hex(), `{"tags": ["<b>"]}`), http.StatusBadRequest, "invalid_tag")
+	expectError(t, update(others.ID.Hex(), `{"title": "Mine now"}`), http.StatusNotFound, "resume_not_found")
+}
+
+func TestTags(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	for _, tags := range [][]string{{"go", "backend"}, {"go"}, {"golang"}} {
+		resume := s.storeResume(t, userId, false)
+		if _, err := s.store.UpdateResume(context.Background(), userId, resume.ID.Hex(), database.ResumeUpdate{Tags: tags}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listTags := func() string {
+		t.Helper()
+		w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+		var response struct {
+			Tags []database.TagCount `json:"tags"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Expected the tags, got %d %s", w.Code, w.Body.String())
+		}
+		var tags []string
+		for _, tag := range response.Tags {
+			tags = append(tags, fmt.Sprintf("%s:%d", tag.Tag, tag.Count))
+		}
+		return strings.Join(tags, ",")
+	}
+
+	if tags := listTags(); tags != "go:2,backend:1,golang:1" {
+		t.Errorf("Expected the tags by count, got %s", tags)
+	}
+
+	w := s.request(userId, jsonRequest(http.MethodPut, "/api/tags/golang", `{"name": "Go"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the rename to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if tags := listTags(); tags != "go:3,backend:1" {
+		t.Errorf("Expected golang to be merged into go, got %s", tags)
+	}
+
+	if w = s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/tags/backend", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the delete to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if tags := listTags(); tags != "go:3" {
+		t.Errorf("Expected backend to be gone, got %s", tags)
+	}
+
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/tags/backend", nil)), http.StatusNotFound, "tag_not_found")
+	expectError(t, s.request(userId, jsonRequest(http.MethodPut, "/api/tags/go", `{"name": "a/b"}`)), http.StatusBadRequest, "invalid_tag")
+	// other users' tags are out of reach
+	expectError(t, s.request(primitive.NewObjectID(), httptest.NewRequest(http.MethodDelete, "/api/tags/go", nil)), http.StatusNotFound, "tag_not_found")
+}
+
+func TestGenerateCoverLetter(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	own := s.storeResume(t, userId, false)
+	others := s.storeResume(t, primitive.NewObjectID(), true)
+
+	generate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/generate-cover-letter", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return s.request(userId, req)
+	}
+
+	expectError(t, generate(`{"job_desc": "Go developer"}`), http.StatusBadRequest, "resume_id_required")
+	expectError(t, generate(`{"resume_id": "`+others.ID.Hex()+`"}`), http.StatusNotFound, "resume_not_found")
+	// the stored file isn't a complete pdf
+	expectError(t, generate(`{"resume_id": "`+own.ID.Hex()+`"}`), http.StatusUnprocessableEntity, "resume_unreadable")
+	if s.generator.calls != 0 {
+		t.Errorf("Expected no generation without resume text, got %d calls", s.generator.calls)
+	}
+}
@@ -0,0 +1,297 @@
+package resume
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/textdiff"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	diffContext = 2
+	// maxDiffLines bounds the time a diff takes, which grows with lines times changes. Resumes are far shorter.
+	maxDiffLines = 1000
+)
+
+var (
+	errNoPreviousVersion = apperror.New(http.StatusBadRequest, "no_previous_version", "This resume has no previous version to compare with")
+	errDiffTooLarge      = apperror.New(http.StatusUnprocessableEntity, "diff_too_large", fmt.Sprintf("Resumes of over %d lines can't be compared", maxDiffLines))
+)
+
+// sectionHeadings are the headings resumes commonly have. There is no structured parser yet, so sections are
+// found by these and by lines in capitals.
+var sectionHeadings = []string{
+	"summary", "profile", "about me", "objective", "experience", "work experience", "professional experience",
+	"employment", "employment history", "education", "skills", "technical skills", "projects", "certifications",
+	"awards", "publications", "languages", "interests", "references", "volunteering", "contact",
+}
+
+type diffLine struct {
+	Op      textdiff.Op `json:"op"`
+	OldLine int         `json:"old_line,omitempty"`
+	NewLine int         `json:"new_line,omitempty"`
+	Text    string      `json:"text"`
+}
+
+type diffHunk struct {
+	Lines []diffLine `json:"lines"`
+}
+
+// sectionDiff is unchanged, changed, added or removed. Sections are matched by heading, so moving one around
+// doesn't show up as a change.
+type sectionDiff struct {
+	Section string     `json:"section"`
+	Status  string     `json:"status"`
+	Hunks   []diffHunk `json:"hunks"`
+}
+
+type line struct {
+	// number is the line in the extracted text, from 1
+	number int
+	text   string
+}
+
+type section struct {
+	name  string
+	lines []line
+}
+
+// DiffResumes compares a version of a resume with an older version, or with another resume.
+func (r *ResumeController) DiffResumes(c *gin.Context) {
+	var request struct {
+		Version        int    `form:"version" binding:"omitempty,min=1"`
+		Against        string `form:"against"`
+		AgainstVersion int    `form:"against_version" binding:"omitempty,min=1"`
+		Format         string `form:"format" binding:"omitempty,oneof=json html"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	userId := auth.GetUserIdFromContext(c)
+	resumeId := c.Param("resume_id")
+	againstId := request.Against
+	if againstId == "" {
+		againstId = resumeId
+	}
+
+	resume, err := r.ownResume(c, userId, resumeId)
+	if err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+	version := request.Version
+	if version == 0 {
+		version = resume.Version
+	}
+	againstVersion := request.AgainstVersion
+	if againstVersion == 0 && againstId == resumeId {
+		if againstVersion = version - 1; againstVersion < 1 {
+			apperror.Abort(c, errNoPreviousVersion)
+			return
+		}
+	}
+	if againstVersion == 0 {
+		against, err := r.ownResume(c, userId, againstId)
+		if err != nil {
+			resumeNotFoundOrError(c, err)
+			return
+		}
+		againstVersion = against.Version
+	}
+
+	newText, ok := r.versionText(c, resumeId, version)
+	if !ok {
+		return
+	}
+	oldText, ok := r.versionText(c, againstId, againstVersion)
+	if !ok {
+		return
+	}
+	oldSections, newSections := splitSections(oldText), splitSections(newText)
+	if countLines(oldSections) > maxDiffLines || countLines(newSections) > maxDiffLines {
+		apperror.Abort(c, errDiffTooLarge)
+		return
+	}
+
+	sections := diffSections(oldSections, newSections)
+	response := gin.H{
+		"from":     gin.H{"resume_id": againstId, "version": againstVersion},
+		"to":       gin.H{"resume_id": resumeId, "version": version},
+		"sections": sections,
+	}
+	if request.Format == "html" {
+		response["html"] = renderDiffHTML(sections)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// versionText returns the text of a version, extracting it from the file for resumes uploaded before the text
+// was kept.
+func (r *ResumeController) versionText(c *gin.Context, resumeId string, version int) (string, bool) {
+	resumeVersion, err := r.resumeStore.GetResumeVersion(c, auth.GetUserIdFromContext(c), resumeId, version)
+	if err != nil {
+		versionNotFoundOrError(c, err, errVersionNotFound)
+		return "", false
+	}
+	if resumeVersion.Content != "" {
+		return resumeVersion.Content, true
+	}
+
+	fileContent, err := r.fileStorage.Download(c, resumeVersion.Key)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return "", false
+	}
+	text, err := parsePDF(c, fileContent)
+	if err != nil {
+		apperror.Abort(c, errResumeUnreadable.Wrap(err))
+		return "", false
+	}
+	return text, true
+}
+
+// splitSections splits the text at its headings, lines before the first heading have a section without name.
+// Blank lines are left out and spacing is evened out, text extracted from a pdf varies in both.
+func splitSections(text string) []section {
+	sections := []section{{}}
+	for i, raw := range strings.Split(text, "\n") {
+		text := strings.Join(strings.Fields(raw), " ")
+		if text == "" {
+			continue
+		}
+		if isHeading(text) {
+			sections = append(sections, section{name: strings.TrimSuffix(text, ":")})
+		}
+		current := &sections[len(sections)-1]
+		current.lines = append(current.lines, line{number: i + 1, text: text})
+	}
+	if len(sections[0].lines) == 0 {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+func isHeading(text string) bool {
+	name := strings.ToLower(strings.TrimSuffix(text, ":"))
+	for _, heading := range sectionHeadings {
+		if name == heading {
+			return true
+		}
+	}
+
+	// short lines in capitals, like "WORK HISTORY"
+	if len(strings.Fields(text)) > 4 || len(text) > 40 {
+		return false
+	}
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters >= 3
+}
+
+// diffSections diffs the sections with the same heading, in the order of the new text. Sections only the old
+// text has come last.
+func diffSections(oldSections, newSections []section) []sectionDiff {
+	matched := make([]bool, len(oldSections))
+	diffs := []sectionDiff{}
+	for _, newSection := range newSections {
+		match := -1
+		for i, oldSection := range oldSections {
+			if !matched[i] && strings.EqualFold(oldSection.name, newSection.name) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			diffs = append(diffs, sectionDiff{Section: newSection.name, Status: "added", Hunks: diffLines(nil, newSection.lines)})
+			continue
+		}
+		matched[match] = true
+		hunks := diffLines(oldSections[match].lines, newSection.lines)
+		status := "changed"
+		if len(hunks) == 0 {
+			status = "unchanged"
+		}
+		diffs = append(diffs, sectionDiff{Section: newSection.name, Status: status, Hunks: hunks})
+	}
+	for i, oldSection := range oldSections {
+		if !matched[i] {
+			diffs = append(diffs, sectionDiff{Section: oldSection.name, Status: "removed", Hunks: diffLines(oldSection.lines, nil)})
+		}
+	}
+	return diffs
+}
+
+func diffLines(oldLines, newLines []line) []diffHunk {
+	texts := func(lines []line) []string {
+		list := make([]string, len(lines))
+		for i, line := range lines {
+			list[i] = line.text
+		}
+		return list
+	}
+
+	hunks := []diffHunk{}
+	for _, edits := range textdiff.Hunks(textdiff.Diff(texts(oldLines), texts(newLines)), diffContext) {
+		hunk := diffHunk{}
+		for _, edit := range edits {
+			diffLine := diffLine{Op: edit.Op}
+			if edit.Old >= 0 {
+				diffLine.OldLine = oldLines[edit.Old].number
+				diffLine.Text = oldLines[edit.Old].text
+			}
+			if edit.New >= 0 {
+				diffLine.NewLine = newLines[edit.New].number
+				diffLine.Text = newLines[edit.New].text
+			}
+			hunk.Lines = append(hunk.Lines, diffLine)
+		}
+		hunks = append(hunks, hunk)
+	}
+	return hunks
+}
+
+func countLines(sections []section) int {
+	count := 0
+	for _, section := range sections {
+		count += len(section.lines)
+	}
+	return count
+}
+
+// renderDiffHTML renders the sections for the frontend to style, with the classes diff-section, diff-<status>,
+// diff-hunk and diff-<op>.
+func renderDiffHTML(sections []sectionDiff) string {
+	var b strings.Builder
+	b.WriteString(`<div class="resume-diff">`)
+	for _, section := range sections {
+		fmt.Fprintf(&b, `<section class="diff-section diff-%s">`, section.Status)
+		if section.Section != "" {
+			fmt.Fprintf(&b, `<h3>%s</h3>`, html.EscapeString(section.Section))
+		}
+		for _, hunk := range section.Hunks {
+			b.WriteString(`<pre class="diff-hunk">`)
+			for _, line := range hunk.Lines {
+				tag := map[textdiff.Op]string{textdiff.Equal: "span", textdiff.Insert: "ins", textdiff.Delete: "del"}[line.Op]
+				fmt.Fprintf(&b, `<%s class="diff-%s">%s</%s>`+"\n", tag, line.Op, html.EscapeString(line.Text), tag)
+			}
+			b.WriteString(`</pre>`)
+		}
+		b.WriteString(`</section>`)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package resume
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	firstDraft = `Jane Doe
+jane@example.com
+
+SUMMARY
+Backend developer.
+
+EXPERIENCE
+Acme, 2019 - 2021
+Wrote Go services.
+
+Education:
+BSc Computer Science`
+	secondDraft = `Jane Doe
+jane@example.com
+
+Skills
+Go, MongoDB
+
+EXPERIENCE
+Globex, 2021 - now
+Acme, 2019 - 2021
+Wrote   Go services.
+
+Education:
+BSc Computer Science`
+)
+
+func TestSplitSections(t *testing.T) {
+	sections := splitSections(secondDraft)
+	var names []string
+	for _, section := range sections {
+		names = append(names, section.name)
+	}
+	if strings.Join(names, "|") != "|Skills|EXPERIENCE|Education" {
+		t.Fatalf("Expected the preamble and three sections, got %q", names)
+	}
+	if got := sections[2].lines[3]; got.number != 10 || got.text != "Wrote Go services." {
+		t.Errorf("Expected line 10 with even spacing, got %+v", got)
+	}
+
+	for text, heading := range map[string]bool{
+		"WORK HISTORY":                  true,
+		"Work experience:":              true,
+		"Acme, 2019 - 2021":             false,
+		"BSc":                           false,
+		"CV":                            false,
+		"GO, KUBERNETES, AWS, GCP, SQL": false,
+	} {
+		if isHeading(text) != heading {
+			t.Errorf("Expected isHeading(%q) to be %v", text, heading)
+		}
+	}
+}
+
+func TestDiffSections(t *testing.T) {
+	diffs := diffSections(splitSections(firstDraft), splitSections(secondDraft))
+	statuses := map[string]string{}
+	for _, diff := range diffs {
+		statuses[diff.Section] = diff.Status
+	}
+	expected := map[string]string{"": "unchanged", "Skills": "added", "EXPERIENCE": "changed", "Education": "unchanged", "SUMMARY": "removed"}
+	if len(diffs) != len(expected) || diffs[len(diffs)-1].Section != "SUMMARY" {
+		t.Fatalf("Expected removed sections last, got %+v", diffs)
+	}
+	for section, status := range expected {
+		if statuses[section] != status {
+			t.Errorf("Expected section %q to be %s, got %s", section, status, statuses[section])
+		}
+	}
+
+	experience := diffs[2]
+	if len(experience.Hunks) != 1 {
+		t.Fatalf("Expected one hunk, got %+v", experience.Hunks)
+	}
+	lines := experience.Hunks[0].Lines
+	if len(lines) != 4 || lines[1] != (diffLine{Op: "insert", NewLine: 8, Text: "Globex, 2021 - now"}) ||
+		lines[0] != (diffLine{Op: "equal", OldLine: 7, NewLine: 7, Text: "EXPERIENCE"}) {
+		t.Errorf("Expected the new job inserted between context lines, got %+v", lines)
+	}
+}
+
+func TestRenderDiffHTML(t *testing.T) {
+	got := renderDiffHTML([]sectionDiff{{Section: "R&D", Status: "changed", Hunks: []diffHunk{{Lines: []diffLine{
+		{Op: "delete", OldLine: 1, Text: "<b>old</b>"},
+		{Op: "insert", NewLine: 1, Text: "new"},
+	}}}}})
+	expected := `<div class="resume-diff"><section class="diff-section diff-changed"><h3>R&amp;D</h3><pre class="diff-hunk">` +
+		`<del class="diff-delete">&lt;b&gt;old&lt;/b&gt;</del>` + "\n" + `<ins class="diff-insert">new</ins>` + "\n" + `</pre></section></div>`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+// storeDrafts stores a resume with the first draft as version 1 and the second as version 2.
+func (s *testServer) storeDrafts(t *testing.T, userId primitive.ObjectID) model.Resume {
+	t.Helper()
+	resume, err := s.store.StoreResume(context.Background(), model.Resume{UserID: userId, FileName: "cv.pdf", Key: "user-v1", UploadDate: time.Now(), Content: firstDraft})
+	if err != nil {
+		t.Fatalf("Cannot store resume: %v", err)
+	}
+	resume, err = s.store.AddResumeVersion(context.Background(), userId, resume.ID.Hex(), model.ResumeVersion{
+		FileName: "cv-v2.pdf", Key: "user-v2", UploadDate: time.Now(), Content: secondDraft,
+	})
+	if err != nil {
+		t.Fatalf("Cannot add version: %v", err)
+	}
+	return resume
+}
+
+func TestDiffResumes(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	resume := s.storeDrafts(t, userId)
+	id := resume.ID.Hex()
+
+	w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?format=html", nil))
+	var response struct {
+		From     struct{ Version int } `json:"from"`
+		To       struct{ Version int } `json:"to"`
+		Sections []sectionDiff         `json:"sections"`
+		HTML     string                `json:"html"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the diff, got %d %s", w.Code, w.Body.String())
+	}
+	if response.From.Version != 1 || response.To.Version != 2 || len(response.Sections) != 5 {
+		t.Errorf("Expected the current version against the previous one, got %+v", response)
+	}
+	if !strings.Contains(response.HTML, `<ins class="diff-insert">Globex, 2021 - now</ins>`) {
+		t.Errorf("Expected the html rendering, got %s", response.HTML)
+	}
+
+	w = s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?version=1&against_version=2", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"html"`) || !strings.Contains(w.Body.String(), `"status":"added","hunks":[{"lines":[{"op":"insert","new_line":4,"text":"SUMMARY"}`) {
+		t.Errorf("Expected the diff the other way around without html, got %d %s", w.Code, w.Body.String())
+	}
+
+	other, err := s.store.StoreResume(context.Background(), model.Resume{UserID: userId, FileName: "other.pdf", Key: "user-other", UploadDate: time.Now(), Content: secondDraft})
+	if err != nil {
+		t.Fatalf("Cannot store resume: %v", err)
+	}
+	w = s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?against="+other.ID.Hex(), nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"changed"`) {
+		t.Errorf("Expected no changes against the other resume, got %d %s", w.Code, w.Body.String())
+	}
+
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+other.ID.Hex()+"/diff", nil)), http.StatusBadRequest, "no_previous_version")
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?against_version=7", nil)), http.StatusNotFound, "version_not_found")
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?format=pdf", nil)), http.StatusBadRequest, "bad_request")
+
+	otherUser := primitive.NewObjectID()
+	expectError(t, s.request(otherUser, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff", nil)), http.StatusNotFound, "resume_not_found")
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?against="+s.storeResume(t, otherUser, true).ID.Hex(), nil)), http.StatusNotFound, "resume_not_found")
+
+	long := strings.Repeat("line\n", maxDiffLines+1)
+	large, err := s.store.StoreResume(context.Background(), model.Resume{UserID: userId, FileName: "large.pdf", Key: "user-large", UploadDate: time.Now(), Content: long})
+	if err != nil {
+		t.Fatalf("Cannot store resume: %v", err)
+	}
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/diff?against="+large.ID.Hex(), nil)), http.StatusUnprocessableEntity, "diff_too_large")
+}
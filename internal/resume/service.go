@@ -2,13 +2,19 @@ package resume
 
 import (
 	"bytes"
+	"context"
+	"resume-service/internal/tracing"
 	"strings"
 
 	"github.com/unidoc/unipdf/v3/extractor"
 	"github.com/unidoc/unipdf/v3/model"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func parsePDF(fileContent []byte) (string, error) {
+func parsePDF(ctx context.Context, fileContent []byte) (text string, err error) {
+	_, span := tracing.Start(ctx, "pdf extract text", attribute.Int("pdf.bytes", len(fileContent)))
+	defer func() { tracing.End(span, err) }()
+
 	r := bytes.NewReader(fileContent)
 	pdfReader, err := model.NewPdfReader(r)
 	if err != nil {
@@ -19,6 +25,7 @@ func parsePDF(fileContent []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	span.SetAttributes(attribute.Int("pdf.pages", numPages))
 	var textBuilder strings.Builder
 
 	for i := 1; i <= numPages; i++ {
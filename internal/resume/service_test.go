@@ -1,6 +1,7 @@
 package resume
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -8,7 +9,7 @@ import (
 
 func TestParsePDF(t *testing.T) {
 	pdf := getFile(t)
-	parsedText, err := parsePDF(pdf)
+	parsedText, err := parsePDF(context.Background(), pdf)
 	if err != nil {
 		t.Errorf("Error parsing pdf")
 	}
@@ -0,0 +1,198 @@
+package resume
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxShareLinksPerResume = 20
+	shareLinkPrefixChars   = 8
+)
+
+var (
+	errShareLinkNotFound     = apperror.New(http.StatusNotFound, "share_link_not_found", "Share link not found")
+	errShareLinkExpired      = apperror.New(http.StatusGone, "share_link_expired", "This share link has expired")
+	errSharePasswordRequired = apperror.New(http.StatusUnauthorized, "share_password_required", "This share link needs a password")
+	errInvalidSharePassword  = apperror.New(http.StatusUnauthorized, "invalid_share_password", "The password is not correct")
+	errInvalidShareExpiry    = apperror.New(http.StatusBadRequest, "invalid_expiry", "Share links have to expire in the future")
+	errTooManyShareLinks     = apperror.New(http.StatusConflict, "too_many_share_links", fmt.Sprintf("A resume can have at most %d active share links", maxShareLinksPerResume))
+)
+
+// CreateShareLink returns the new link with its token, this is the only time the token is shown.
+func (r *ResumeController) CreateShareLink(c *gin.Context) {
+	var request struct {
+		ExpiresAt    *time.Time `json:"expires_at"`
+		Password     string     `json:"password" binding:"omitempty,min=4,max=72"`
+		MaxDownloads int        `json:"max_downloads" binding:"min=0"`
+		NotifyOnView bool       `json:"notify_on_view"`
+	}
+	// every setting is optional, so is the body
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	now := time.Now()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		apperror.Abort(c, errInvalidShareExpiry)
+		return
+	}
+
+	userId := auth.GetUserIdFromContext(c)
+	resumeId := c.Param("resume_id")
+	resume, err := r.ownResume(c, userId, resumeId)
+	if err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+	links, err := r.resumeStore.ListShareLinks(c, userId, resumeId, now)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if len(links) >= maxShareLinksPerResume {
+		apperror.Abort(c, errTooManyShareLinks)
+		return
+	}
+
+	token, err := auth.GenerateSecureToken()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	link := model.ShareLink{
+		ResumeID:     resume.ID,
+		UserID:       userId,
+		TokenHash:    auth.HashToken(token),
+		Prefix:       token[:shareLinkPrefixChars],
+		ExpiresAt:    request.ExpiresAt,
+		MaxDownloads: request.MaxDownloads,
+		NotifyOnView: request.NotifyOnView,
+		CreatedAt:    now,
+	}
+	if request.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		link.PasswordHash = string(hash)
+		link.HasPassword = true
+	}
+
+	link, err = r.resumeStore.CreateShareLink(c, link)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"share_link": link, "token": token})
+}
+
+// ListShareLinks lists the links of the user that can still be used, of one resume with the resume_id query.
+func (r *ResumeController) ListShareLinks(c *gin.Context) {
+	links, err := r.resumeStore.ListShareLinks(c, auth.GetUserIdFromContext(c), c.Query("resume_id"), time.Now())
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		apperror.Abort(c, errResumeNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"share_links": links})
+}
+
+func (r *ResumeController) RevokeShareLink(c *gin.Context) {
+	err := r.resumeStore.RevokeShareLink(c, auth.GetUserIdFromContext(c), c.Param("link_id"))
+	if err != nil {
+		if database.IsNotFound(err) || errors.Is(err, primitive.ErrInvalidHex) {
+			apperror.Abort(c, errShareLinkNotFound)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "share link revoked"})
+}
+
+// DownloadSharedResume serves the resume of a share link without a login. Links with a password take it POSTed,
+// so it doesn't end up in logs and browser history like a query parameter would.
+func (r *ResumeController) DownloadSharedResume(c *gin.Context) {
+	link, err := r.resumeStore.GetShareLinkByHash(c, auth.HashToken(c.Param("token")))
+	if err != nil {
+		shareLinkNotFoundOrError(c, err)
+		return
+	}
+	now := time.Now()
+	if !database.ShareLinkActive(link, now) {
+		apperror.Abort(c, errShareLinkExpired)
+		return
+	}
+
+	if link.HasPassword {
+		var request struct {
+			Password string `form:"password" json:"password"`
+		}
+		if c.Request.Method == http.MethodPost {
+			if err = c.ShouldBind(&request); err != nil {
+				apperror.Abort(c, apperror.InvalidRequest(err))
+				return
+			}
+		}
+		if request.Password == "" {
+			apperror.Abort(c, errSharePasswordRequired)
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(request.Password)) != nil {
+			apperror.Abort(c, errInvalidSharePassword)
+			return
+		}
+	}
+
+	resume, err := r.resumeStore.GetResume(c, link.ResumeID.Hex())
+	if err != nil {
+		shareLinkNotFoundOrError(c, err)
+		return
+	}
+	file, err := r.fileStorage.Download(c, resume.Key)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	// counted once the file is there, a download that fails doesn't use one up or count as a view
+	used, err := r.resumeStore.UseShareLink(c, link.ID, now)
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errShareLinkExpired)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	r.recordView(c, resume, &used)
+	// only one download can be the first, the count is atomic
+	if used.NotifyOnView && used.Downloads == 1 {
+		r.notifyFirstView(c, resume, used)
+	}
+
+	sendAttachment(c, resume.FileName, file)
+}
+
+func shareLinkNotFoundOrError(c *gin.Context, err error) {
+	if database.IsNotFound(err) {
+		apperror.Abort(c, errShareLinkNotFound)
+		return
+	}
+	apperror.Abort(c, apperror.Internal(err))
+}
@@ -0,0 +1,123 @@
+package resume
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func createShareLink(t *testing.T, s *testServer, userId primitive.ObjectID, resumeId, body string) (model.ShareLink, string) {
+	t.Helper()
+	w := s.request(userId, jsonRequest(http.MethodPost, "/api/resumes/"+resumeId+"/share-links", body))
+	var response struct {
+		ShareLink model.ShareLink `json:"share_link"`
+		Token     string          `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("Expected the share link, got %d %s", w.Code, w.Body.String())
+	}
+	return response.ShareLink, response.Token
+}
+
+// download requests a shared resume without a user, like anyone with the link would.
+func download(s *testServer, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestShareLinks(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	resume := s.storeResume(t, userId, false)
+	id := resume.ID.Hex()
+
+	link, token := createShareLink(t, s, userId, id, `{"max_downloads": 2}`)
+	if link.ResumeID != resume.ID || link.MaxDownloads != 2 || link.HasPassword || link.Prefix != token[:8] {
+		t.Errorf("Expected a link limited to 2 downloads, got %+v", link)
+	}
+	for i := 0; i < 2; i++ {
+		w := download(s, httptest.NewRequest(http.MethodGet, "/api/share/"+token, nil))
+		if w.Code != http.StatusOK || w.Body.String() != "%PDF-1.4" || w.Header().Get("Content-Disposition") != "attachment; filename=cv.pdf" {
+			t.Fatalf("Expected the private resume to download, got %d %s", w.Code, w.Body.String())
+		}
+	}
+	expectError(t, download(s, httptest.NewRequest(http.MethodGet, "/api/share/"+token, nil)), http.StatusGone, "share_link_expired")
+	expectError(t, download(s, httptest.NewRequest(http.MethodGet, "/api/share/unknown", nil)), http.StatusNotFound, "share_link_not_found")
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	protected, protectedToken := createShareLink(t, s, userId, id, fmt.Sprintf(`{"password": "hunter2", "expires_at": %q}`, expiresAt))
+	if !protected.HasPassword || protected.ExpiresAt == nil {
+		t.Errorf("Expected a protected link that expires, got %+v", protected)
+	}
+	expectError(t, download(s, httptest.NewRequest(http.MethodGet, "/api/share/"+protectedToken+"?password=hunter2", nil)), http.StatusUnauthorized, "share_password_required")
+	expectError(t, download(s, jsonRequest(http.MethodPost, "/api/share/"+protectedToken, `{"password": "wrong"}`)), http.StatusUnauthorized, "invalid_share_password")
+	if w := download(s, jsonRequest(http.MethodPost, "/api/share/"+protectedToken, `{"password": "hunter2"}`)); w.Code != http.StatusOK {
+		t.Errorf("Expected the password to unlock the link, got %d %s", w.Code, w.Body.String())
+	}
+
+	w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/share-links?resume_id="+id, nil))
+	var listed struct {
+		ShareLinks []model.ShareLink `json:"share_links"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || len(listed.ShareLinks) != 1 || listed.ShareLinks[0].ID != protected.ID || listed.ShareLinks[0].Downloads != 1 {
+		t.Errorf("Expected only the link that can still be used, got %d %s", w.Code, w.Body.String())
+	}
+
+	otherId := primitive.NewObjectID()
+	expectError(t, s.request(otherId, httptest.NewRequest(http.MethodDelete, "/api/share-links/"+protected.ID.Hex(), nil)), http.StatusNotFound, "share_link_not_found")
+	if w = s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/share-links/"+protected.ID.Hex(), nil)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the link to be revoked, got %d %s", w.Code, w.Body.String())
+	}
+	expectError(t, download(s, jsonRequest(http.MethodPost, "/api/share/"+protectedToken, `{"password": "hunter2"}`)), http.StatusNotFound, "share_link_not_found")
+
+	_, token = createShareLink(t, s, userId, id, "")
+	if w = s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/delete-resume/"+id, nil)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the resume to be deleted, got %d %s", w.Code, w.Body.String())
+	}
+	expectError(t, download(s, httptest.NewRequest(http.MethodGet, "/api/share/"+token, nil)), http.StatusNotFound, "share_link_not_found")
+}
+
+func TestFailedSharedDownloadIsNotCounted(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	resume := s.storeResume(t, userId, false)
+	_, token := createShareLink(t, s, userId, resume.ID.Hex(), `{"max_downloads": 1}`)
+
+	s.storage.err = errors.New("s3 unavailable")
+	expectError(t, download(s, httptest.NewRequest(http.MethodGet, "/api/share/"+token, nil)), http.StatusInternalServerError, "internal_error")
+	s.storage.err = nil
+	if w := download(s, httptest.NewRequest(http.MethodGet, "/api/share/"+token, nil)); w.Code != http.StatusOK {
+		t.Errorf("Expected the failed download not to use up the link, got %d %s", w.Code, w.Body.String())
+	}
+
+	analytics, err := s.store.ResumeAnalytics(context.Background(), userId, resume.ID.Hex(), time.Now().Add(-time.Hour))
+	if err != nil || analytics.Views != 1 {
+		t.Errorf("Expected only the successful download to be a view, got %+v %v", analytics, err)
+	}
+}
+
+func TestCreateShareLinkValidation(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	id := s.storeResume(t, userId, false).ID.Hex()
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	expectError(t, s.request(userId, jsonRequest(http.MethodPost, "/api/resumes/"+id+"/share-links", fmt.Sprintf(`{"expires_at": %q}`, past))), http.StatusBadRequest, "invalid_expiry")
+	expectError(t, s.request(userId, jsonRequest(http.MethodPost, "/api/resumes/"+id+"/share-links", `{"max_downloads": -1}`)), http.StatusBadRequest, "bad_request")
+	expectError(t, s.request(userId, jsonRequest(http.MethodPost, "/api/resumes/"+id+"/share-links", `{"password": "abc"}`)), http.StatusBadRequest, "bad_request")
+	expectError(t, s.request(primitive.NewObjectID(), jsonRequest(http.MethodPost, "/api/resumes/"+id+"/share-links", `{}`)), http.StatusNotFound, "resume_not_found")
+
+	for i := 0; i < maxShareLinksPerResume; i++ {
+		createShareLink(t, s, userId, id, `{}`)
+	}
+	expectError(t, s.request(userId, jsonRequest(http.MethodPost, "/api/resumes/"+id+"/share-links", `{}`)), http.StatusConflict, "too_many_share_links")
+}
@@ -0,0 +1,110 @@
+package resume
+
+import (
+	"fmt"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 32
+	// tagSymbols are allowed besides letters and digits, for tags like c++, c#, .net or ci-cd
+	tagSymbols = "-_.+#"
+)
+
+var (
+	errInvalidTag  = apperror.New(http.StatusBadRequest, "invalid_tag", fmt.Sprintf("Tags have up to %d letters, digits or any of %s", maxTagLength, tagSymbols))
+	errTooManyTags = apperror.New(http.StatusBadRequest, "too_many_tags", fmt.Sprintf("A resume can have up to %d tags", maxTags))
+	errTagNotFound = apperror.New(http.StatusNotFound, "tag_not_found", "None of your resumes has this tag")
+)
+
+// normalizeTag is the one place tags are cleaned up: they are lower case without spaces, as tags have always been
+// stored without them.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.Join(strings.Fields(strings.ToLower(tag)), "")
+	if tag == "" || len([]rune(tag)) > maxTagLength {
+		return "", errInvalidTag
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(tagSymbols, r) {
+			return "", errInvalidTag
+		}
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes the tags of a resume, dropping duplicates.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		return nil, errTooManyTags
+	}
+	return normalized, nil
+}
+
+func (r *ResumeController) ListTags(c *gin.Context) {
+	tags, err := r.resumeStore.ListTags(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+func (r *ResumeController) RenameTag(c *gin.Context) {
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	// taken as listed, so tags stored before the current rules can still be renamed
+	from := c.Param("tag")
+	to, err := normalizeTag(request.Name)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	updated, err := r.resumeStore.RenameTag(c, auth.GetUserIdFromContext(c), from, to)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if updated == 0 && from != to {
+		apperror.Abort(c, errTagNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tag": to, "resumes_updated": updated})
+}
+
+func (r *ResumeController) DeleteTag(c *gin.Context) {
+	tag := c.Param("tag")
+	updated, err := r.resumeStore.DeleteTag(c, auth.GetUserIdFromContext(c), tag)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if updated == 0 {
+		apperror.Abort(c, errTagNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tag": tag, "resumes_updated": updated})
+}
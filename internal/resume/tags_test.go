@@ -0,0 +1,46 @@
+package resume
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	valid := map[string]string{
+		"Go":          "go",
+		" back end ":  "backend",
+		"C++":         "c++",
+		"C#":          "c#",
+		".NET":        ".net",
+		"ci-cd":       "ci-cd",
+		"Développeur": "développeur",
+	}
+	for tag, want := range valid {
+		if got, err := normalizeTag(tag); err != nil || got != want {
+			t.Errorf("Expected %q to become %q, got %q, %v", tag, want, got, err)
+		}
+	}
+
+	for _, tag := range []string{"", "   ", "a/b", "<script>", "tag,other", strings.Repeat("a", maxTagLength+1)} {
+		if _, err := normalizeTag(tag); !errors.Is(err, errInvalidTag) {
+			t.Errorf("Expected %q to be refused, got %v", tag, err)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{"Go", "go", "GO ", "backend"})
+	if err != nil || strings.Join(tags, ",") != "go,backend" {
+		t.Errorf("Expected duplicates to be dropped, got %v, %v", tags, err)
+	}
+
+	var many []string
+	for i := 0; i <= maxTags; i++ {
+		many = append(many, fmt.Sprintf("tag%d", i))
+	}
+	if _, err = normalizeTags(many); !errors.Is(err, errTooManyTags) {
+		t.Errorf("Expected more than %d tags to be refused, got %v", maxTags, err)
+	}
+}
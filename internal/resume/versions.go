@@ -0,0 +1,261 @@
+package resume
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/logging"
+	"resume-service/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errVersionNotFound  = apperror.New(http.StatusNotFound, "version_not_found", "Version not found")
+	errVersionIsCurrent = apperror.New(http.StatusBadRequest, "version_is_current", "This version is already the current one")
+	errVersionConflict  = apperror.New(http.StatusConflict, "version_conflict", "Another version was uploaded at the same time, try again")
+)
+
+// UploadResumeVersion replaces the file of a resume, keeping the one it had as a previous version.
+func (r *ResumeController) UploadResumeVersion(c *gin.Context) {
+	var request struct {
+		File *multipart.FileHeader `form:"file"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	if request.File == nil {
+		apperror.Abort(c, errFileRequired)
+		return
+	}
+	if !validFileName(request.File.Filename) {
+		apperror.Abort(c, errInvalidFileName)
+		return
+	}
+	fileContent, err := readFile(request.File)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	resume, err := r.addVersion(c, c.Param("resume_id"), request.File.Filename, fileContent)
+	if err != nil {
+		versionNotFoundOrError(c, err, errResumeNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resume": resume})
+}
+
+// ListResumeVersions lists the versions of a resume, the current one first.
+func (r *ResumeController) ListResumeVersions(c *gin.Context) {
+	userId := auth.GetUserIdFromContext(c)
+	resumeId := c.Param("resume_id")
+
+	resume, err := r.ownResume(c, userId, resumeId)
+	if err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+	previous, err := r.resumeStore.ListResumeVersions(c, userId, resumeId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	current := database.CurrentVersion(resume)
+	current.Content = ""
+	c.JSON(http.StatusOK, gin.H{"versions": append([]model.ResumeVersion{current}, previous...)})
+}
+
+func (r *ResumeController) DownloadResumeVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+	resumeVersion, err := r.resumeStore.GetResumeVersion(c, auth.GetUserIdFromContext(c), c.Param("resume_id"), version)
+	if err != nil {
+		versionNotFoundOrError(c, err, errVersionNotFound)
+		return
+	}
+
+	file, err := r.fileStorage.Download(c, resumeVersion.Key)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	sendAttachment(c, resumeVersion.FileName, file)
+}
+
+// RestoreResumeVersion makes a copy of an older version the new current version, so the history stays as it was.
+func (r *ResumeController) RestoreResumeVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+	resumeId := c.Param("resume_id")
+	restored, err := r.resumeStore.GetResumeVersion(c, auth.GetUserIdFromContext(c), resumeId, version)
+	if err != nil {
+		versionNotFoundOrError(c, err, errVersionNotFound)
+		return
+	}
+	if restored.Current {
+		apperror.Abort(c, errVersionIsCurrent)
+		return
+	}
+	// names of resumes uploaded before they were checked can't be used as they are
+	if !validFileName(restored.FileName) {
+		apperror.Abort(c, errInvalidFileName)
+		return
+	}
+
+	fileContent, err := r.fileStorage.Download(c, restored.Key)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	resume, err := r.addVersion(c, resumeId, restored.FileName, fileContent)
+	if err != nil {
+		versionNotFoundOrError(c, err, errResumeNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resume": resume})
+}
+
+// PruneResumeVersions deletes the previous versions of a resume but the newest keep ones.
+func (r *ResumeController) PruneResumeVersions(c *gin.Context) {
+	var request struct {
+		Keep *int `form:"keep" binding:"required,min=0"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+	userId := auth.GetUserIdFromContext(c)
+	resumeId := c.Param("resume_id")
+	if _, err := r.ownResume(c, userId, resumeId); err != nil {
+		resumeNotFoundOrError(c, err)
+		return
+	}
+
+	pruned, err := r.resumeStore.PruneResumeVersions(c, userId, resumeId, *request.Keep)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	r.deleteFiles(c, versionKeys(pruned))
+	c.JSON(http.StatusOK, gin.H{"deleted": len(pruned)})
+}
+
+// addVersion stores a new file for the resume and prunes the versions past the retention.
+func (r *ResumeController) addVersion(c *gin.Context, resumeId, fileName string, fileContent []byte) (model.Resume, error) {
+	userId := auth.GetUserIdFromContext(c)
+	key := fmt.Sprintf("user-%s-%s", userId.String(), uuid.New())
+	if err := r.fileStorage.Upload(c, key, fileContent); err != nil {
+		return model.Resume{}, err
+	}
+
+	resume, err := r.resumeStore.AddResumeVersion(c, userId, resumeId, model.ResumeVersion{
+		FileName:   fileName,
+		Key:        key,
+		UploadDate: time.Now(),
+		Content:    extractContent(c, fileContent),
+	})
+	if err != nil {
+		r.deleteFiles(c, []string{key})
+		return model.Resume{}, err
+	}
+
+	pruned, err := r.resumeStore.PruneResumeVersions(c, userId, resumeId, r.versionsKept)
+	if err != nil {
+		// the next upload prunes again
+		logging.FromContext(c).Warn("Cannot prune resume versions", "resume_id", resumeId, "error", err)
+	}
+	r.deleteFiles(c, versionKeys(pruned))
+	return resume, nil
+}
+
+func (r *ResumeController) ownResume(c *gin.Context, userId primitive.ObjectID, resumeId string) (model.Resume, error) {
+	resume, err := r.resumeStore.GetResume(c, resumeId)
+	if err != nil {
+		return model.Resume{}, err
+	}
+	if resume.UserID != userId {
+		return model.Resume{}, database.ErrNotFound
+	}
+	return resume, nil
+}
+
+// fileKeys are the storage keys of every version of the user's resume, none when it isn't theirs.
+func (r *ResumeController) fileKeys(c *gin.Context, userId primitive.ObjectID, resumeId string) ([]string, error) {
+	resume, err := r.ownResume(c, userId, resumeId)
+	if database.IsNotFound(err) || errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	previous, err := r.resumeStore.ListResumeVersions(c, userId, resumeId)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{resume.Key}, versionKeys(previous)...), nil
+}
+
+// deleteFiles deletes files that nothing refers to anymore. Failing only leaves them behind, so it doesn't fail the
+// request.
+func (r *ResumeController) deleteFiles(c *gin.Context, keys []string) {
+	for _, key := range keys {
+		if err := r.fileStorage.Delete(c, key); err != nil {
+			logging.FromContext(c).Warn("Cannot delete resume file", "key", key, "error", err)
+		}
+	}
+}
+
+func versionKeys(versions []model.ResumeVersion) []string {
+	keys := make([]string, 0, len(versions))
+	for _, version := range versions {
+		keys = append(keys, version.Key)
+	}
+	return keys
+}
+
+func versionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		apperror.Abort(c, errVersionNotFound)
+		return 0, false
+	}
+	return version, true
+}
+
+func readFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// versionNotFoundOrError reports missing resumes and versions as notFound and conflicts as such.
+func versionNotFoundOrError(c *gin.Context, err error, notFound *apperror.Error) {
+	switch {
+	case errors.Is(err, database.ErrVersionConflict):
+		apperror.Abort(c, errVersionConflict)
+	case database.IsNotFound(err) || errors.Is(err, primitive.ErrInvalidHex):
+		apperror.Abort(c, notFound)
+	default:
+		apperror.Abort(c, apperror.Internal(err))
+	}
+}
@@ -0,0 +1,138 @@
+package resume
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/model"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func uploadVersion(s *testServer, userId primitive.ObjectID, resumeId, fileName, content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", fileName)
+	_, _ = file.Write([]byte(content))
+	_ = form.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/resumes/"+resumeId+"/file", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return s.request(userId, req)
+}
+
+func listVersions(t *testing.T, s *testServer, userId primitive.ObjectID, resumeId string) []model.ResumeVersion {
+	t.Helper()
+	w := s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+resumeId+"/versions", nil))
+	var response struct {
+		Versions []model.ResumeVersion `json:"versions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the versions, got %d %s", w.Code, w.Body.String())
+	}
+	return response.Versions
+}
+
+func TestUploadResumeVersion(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	resume := s.storeResume(t, userId, true)
+	id := resume.ID.Hex()
+
+	w := uploadVersion(s, userId, id, "cv-v2.pdf", "%PDF-1.4 v2")
+	var response struct {
+		Resume model.Resume `json:"resume"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the upload to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if response.Resume.ID != resume.ID || response.Resume.Version != 2 || !response.Resume.Public || response.Resume.FileName != "cv-v2.pdf" {
+		t.Errorf("Expected version 2 of the same resume, keeping its visibility, got %+v", response.Resume)
+	}
+
+	versions := listVersions(t, s, userId, id)
+	if len(versions) != 2 || !versions[0].Current || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Errorf("Expected the current version, then the first, got %+v", versions)
+	}
+
+	w = s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/versions/1/file", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "%PDF-1.4" {
+		t.Errorf("Expected to download the first version, got %d %s", w.Code, w.Body.String())
+	}
+
+	otherId := primitive.NewObjectID()
+	expectError(t, uploadVersion(s, otherId, id, "mine.pdf", "%PDF-1.4"), http.StatusNotFound, "resume_not_found")
+	expectError(t, s.request(otherId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/versions/1/file", nil)), http.StatusNotFound, "version_not_found")
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodGet, "/api/resumes/"+id+"/versions/9/file", nil)), http.StatusNotFound, "version_not_found")
+}
+
+func TestResumeVersionFileNames(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	// stored before names were checked
+	s.storage.files["user-v1"] = []byte("%PDF-1.4")
+	resume, err := s.store.StoreResume(context.Background(), model.Resume{UserID: userId, FileName: "cv\r\nv1.pdf", Key: "user-v1"})
+	if err != nil {
+		t.Fatalf("Cannot store resume: %v", err)
+	}
+	id := resume.ID.Hex()
+
+	expectError(t, uploadVersion(s, userId, id, `cv"; filename="other.pdf`, "%PDF-1.4 v2"), http.StatusBadRequest, "invalid_file_name")
+	if versions := listVersions(t, s, userId, id); len(versions) != 1 {
+		t.Errorf("Expected no version to be added, got %+v", versions)
+	}
+
+	if w := uploadVersion(s, userId, id, "cv-v2.pdf", "%PDF-1.4 v2"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the upload to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodPost, "/api/resumes/"+id+"/versions/1/restore", nil)), http.StatusBadRequest, "invalid_file_name")
+}
+
+func TestResumeVersionRetention(t *testing.T) {
+	s := newTestServer()
+	userId := primitive.NewObjectID()
+	resume := s.storeResume(t, userId, false)
+	id := resume.ID.Hex()
+
+	// the test server keeps 2 previous versions
+	for i := 2; i <= 5; i++ {
+		if w := uploadVersion(s, userId, id, fmt.Sprintf("v%d.pdf", i), fmt.Sprint("v", i)); w.Code != http.StatusOK {
+			t.Fatalf("Expected upload %d to succeed, got %d %s", i, w.Code, w.Body.String())
+		}
+	}
+	versions := listVersions(t, s, userId, id)
+	if len(versions) != 3 || versions[1].Version != 4 || versions[2].Version != 3 {
+		t.Errorf("Expected the current and 2 previous versions, got %+v", versions)
+	}
+	if _, ok := s.storage.files[resume.Key]; ok {
+		t.Error("Expected the file of a pruned version to be deleted")
+	}
+
+	w := s.request(userId, httptest.NewRequest(http.MethodPost, "/api/resumes/"+id+"/versions/3/restore", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the restore to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	current, _ := s.store.GetResume(context.Background(), id)
+	if current.Version != 6 || current.FileName != "v3.pdf" || string(s.storage.files[current.Key]) != "v3" {
+		t.Errorf("Expected version 3 to come back as version 6, got %+v", current)
+	}
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodPost, "/api/resumes/"+id+"/versions/6/restore", nil)), http.StatusBadRequest, "version_is_current")
+
+	w = s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/resumes/"+id+"/versions?keep=0", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the prune to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if versions = listVersions(t, s, userId, id); len(versions) != 1 || !versions[0].Current {
+		t.Errorf("Expected only the current version to be left, got %+v", versions)
+	}
+	expectError(t, s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/resumes/"+id+"/versions", nil)), http.StatusBadRequest, "bad_request")
+
+	w = s.request(userId, httptest.NewRequest(http.MethodDelete, "/api/delete-resume/"+id, nil))
+	if w.Code != http.StatusOK || len(s.storage.files) != 0 {
+		t.Errorf("Expected deleting the resume to delete its files, got %d and %d files", w.Code, len(s.storage.files))
+	}
+}
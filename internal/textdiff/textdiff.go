@@ -0,0 +1,170 @@
+// Package textdiff compares sequences of lines with the Myers algorithm, which finds the fewest insertions and
+// deletions that turn one into the other.
+package textdiff
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is a step from a to b. Old indexes into a and New into b, -1 where the line is only on the other side.
+type Edit struct {
+	Op  Op
+	Old int
+	New int
+}
+
+// Diff returns the edits that turn a into b, in order. It uses the linear space variant of Myers: the middle
+// snake of the shortest path splits the problem in two, so memory stays proportional to the number of lines.
+func Diff(a, b []string) []Edit {
+	// enough diagonals for the largest split, of the whole of a and b
+	size := len(a) + len(b) + 4
+	d := differ{a: a, b: b, forward: make([]int, size), backward: make([]int, size)}
+	d.diff(0, len(a), 0, len(b))
+	return d.edits
+}
+
+type differ struct {
+	a, b []string
+	// forward and backward hold the furthest x reached on each diagonal, reused by every split
+	forward, backward []int
+	edits             []Edit
+}
+
+// diff appends the edits that turn a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.edits = append(d.edits, Edit{Op: Equal, Old: aLo, New: bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+		suffix++
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.edits = append(d.edits, Edit{Op: Insert, Old: -1, New: j})
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.edits = append(d.edits, Edit{Op: Delete, Old: i, New: -1})
+		}
+	default:
+		// without a common prefix or suffix it takes at least two edits, so both halves are smaller
+		x, y := d.split(aLo, aHi, bLo, bHi)
+		d.diff(aLo, x, bLo, y)
+		d.diff(x, aHi, y, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.edits = append(d.edits, Edit{Op: Equal, Old: aHi + i, New: bHi + i})
+	}
+}
+
+// split searches from both ends at once until the paths meet, and returns where they do, a point on a shortest
+// path halfway through. Diagonals are k = x - y, counted from the start going forward and from the end going
+// backward. Paths that leave the box are dropped, by narrowing the diagonals searched.
+func (d *differ) split(aLo, aHi, bLo, bHi int) (int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	steps := (n + m + 1) / 2
+	offset := steps + 1
+	forward, backward := d.forward[:2*offset+1], d.backward[:2*offset+1]
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+	for step := 0; step <= steps; step++ {
+		for k := -step + forwardStart; k <= step-forwardEnd; k += 2 {
+			x := forward[offset+k-1] + 1
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				// the backward path on the same diagonal
+				if back := offset + delta - k; back >= 0 && back < len(backward) && backward[back] != -1 && x >= n-backward[back] {
+					return aLo + x, bLo + y
+				}
+			}
+		}
+
+		for k := -step + backwardStart; k <= step-backwardEnd; k += 2 {
+			x := backward[offset+k-1] + 1
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				if ahead := offset + delta - k; ahead >= 0 && ahead < len(forward) && forward[ahead] != -1 && forward[ahead] >= n-x {
+					aheadX := forward[ahead]
+					return aLo + aheadX, bLo + aheadX - (delta - k)
+				}
+			}
+		}
+	}
+	// the paths always meet by then
+	panic("textdiff: paths don't meet")
+}
+
+// Hunks groups the changes with up to context equal lines around them. Changes closer than twice the context
+// share a hunk.
+func Hunks(edits []Edit, context int) [][]Edit {
+	var hunks [][]Edit
+	start, end := -1, -1
+	for i, edit := range edits {
+		if edit.Op == Equal {
+			continue
+		}
+		from := i - context
+		if from < 0 {
+			from = 0
+		}
+		if start >= 0 && from > end {
+			hunks = append(hunks, edits[start:end])
+			start = -1
+		}
+		if start < 0 {
+			start = from
+		}
+		end = i + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+	}
+	if start >= 0 {
+		hunks = append(hunks, edits[start:end])
+	}
+	return hunks
+}
@@ -0,0 +1,143 @@
+package textdiff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// apply replays the edits on a, checking that they describe b.
+func apply(t *testing.T, a, b []string, edits []Edit) {
+	t.Helper()
+	var got []string
+	oldNext, newNext := 0, 0
+	for _, edit := range edits {
+		switch edit.Op {
+		case Equal:
+			if edit.Old != oldNext || edit.New != newNext || a[edit.Old] != b[edit.New] {
+				t.Fatalf("Bad equal edit %+v", edit)
+			}
+			got = append(got, a[edit.Old])
+			oldNext++
+			newNext++
+		case Delete:
+			if edit.Old != oldNext {
+				t.Fatalf("Bad delete edit %+v", edit)
+			}
+			oldNext++
+		case Insert:
+			if edit.New != newNext {
+				t.Fatalf("Bad insert edit %+v", edit)
+			}
+			got = append(got, b[edit.New])
+			newNext++
+		}
+	}
+	if oldNext != len(a) || strings.Join(got, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("Expected the edits to turn %v into %v, got %v", a, b, got)
+	}
+}
+
+func changes(edits []Edit) int {
+	count := 0
+	for _, edit := range edits {
+		if edit.Op != Equal {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		changes int
+	}{
+		{"", "", 0},
+		{"a b c", "a b c", 0},
+		{"", "a b", 2},
+		{"a b", "", 2},
+		{"a b c", "x y z", 6},
+		{"a b c a b b a", "c b a b a c", 5},
+		{"go python sql", "go rust python sql", 1},
+	}
+	for _, test := range tests {
+		a, b := strings.Fields(test.a), strings.Fields(test.b)
+		edits := Diff(a, b)
+		apply(t, a, b, edits)
+		if got := changes(edits); got != test.changes {
+			t.Errorf("Expected %d changes from %q to %q, got %d", test.changes, test.a, test.b, got)
+		}
+	}
+}
+
+func TestDiffRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	words := func() []string {
+		list := make([]string, random.Intn(40))
+		for i := range list {
+			list[i] = string(rune('a' + random.Intn(4)))
+		}
+		return list
+	}
+	for i := 0; i < 500; i++ {
+		a, b := words(), words()
+		edits := Diff(a, b)
+		apply(t, a, b, edits)
+		if got, fewest := changes(edits), fewestChanges(a, b); got != fewest {
+			t.Fatalf("Expected %d changes from %v to %v, got %d", fewest, a, b, got)
+		}
+	}
+}
+
+// fewestChanges is the edit distance without substitutions, from the longest common subsequence.
+func fewestChanges(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestDiffLarge(t *testing.T) {
+	a, b := make([]string, 5000), make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d", i)
+		b[i] = fmt.Sprintf("new %d", i)
+	}
+	b[2500] = a[1000]
+	edits := Diff(a, b)
+	apply(t, a, b, edits)
+	if got := changes(edits); got != 9998 {
+		t.Errorf("Expected 9998 changes, got %d", got)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	a := strings.Fields("1 2 3 4 5 6 7 8 9 10")
+	b := strings.Fields("1 x 3 4 5 6 7 8 9 y")
+	hunks := Hunks(Diff(a, b), 1)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %+v", hunks)
+	}
+	if first := hunks[0]; first[0].Old != 0 || first[len(first)-1].Old != 2 {
+		t.Errorf("Expected the first hunk to cover lines 1 to 3, got %+v", first)
+	}
+
+	if hunks = Hunks(Diff(a, b), 4); len(hunks) != 1 {
+		t.Errorf("Expected close changes to share a hunk, got %+v", hunks)
+	}
+	if hunks = Hunks(Diff(a, a), 2); len(hunks) != 0 {
+		t.Errorf("Expected no hunks without changes, got %+v", hunks)
+	}
+}
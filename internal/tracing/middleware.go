@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"net/http"
+	"resume-service/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts the server span of every request, continuing the trace of a traceparent header. The trace
+// id is added to the request's logger, so logs and traces can be matched.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method
+		}
+		ctx, span := otel.Tracer(tracerName).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(logging.Path(c)),
+				semconv.ClientAddress(c.ClientIP()),
+			),
+		)
+		defer span.End()
+
+		if span.SpanContext().IsValid() {
+			logger := logging.FromContext(ctx).With("trace_id", span.SpanContext().TraceID().String())
+			ctx = logging.WithLogger(ctx, logger)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			description := http.StatusText(status)
+			if len(c.Errors) > 0 {
+				description = c.Errors.Last().Error()
+			}
+			span.SetStatus(codes.Error, description)
+		}
+	}
+}
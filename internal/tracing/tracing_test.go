@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func setupTest(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(sdktrace.NewSimpleSpanProcessor(exporter), 1)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := setupTest(t)
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(Middleware(), apperror.Middleware())
+	r.GET("/api/download-resume/:resume_id", func(c *gin.Context) {
+		_, span := Start(c, "db resume.GetResume")
+		End(span, errors.New("server selection timeout"))
+		apperror.Abort(c, apperror.ErrUnavailable)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/download-resume/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected the request and store spans, got %d", len(spans))
+	}
+	store, server := spans[0], spans[1]
+	if server.Name != "GET /api/download-resume/:resume_id" || server.Status.Code != codes.Error {
+		t.Errorf("Expected a failed span named by route, got %q with %v", server.Name, server.Status)
+	}
+	if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the trace of the traceparent header to be continued, got %v", server.SpanContext.TraceID())
+	}
+	if store.Parent.SpanID() != server.SpanContext.SpanID() || store.Status.Code != codes.Error {
+		t.Errorf("Expected a failed child span for the store call, got %+v", store)
+	}
+}
+
+func TestMiddlewareRedactsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := setupTest(t)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/share/:token", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/share/s3cr3t-token", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected the request span, got %d", len(spans))
+	}
+	path := ""
+	for _, attr := range spans[0].Attributes {
+		if attr.Key == semconv.URLPathKey {
+			path = attr.Value.AsString()
+		}
+		if strings.Contains(attr.Value.Emit(), "s3cr3t-token") {
+			t.Errorf("Expected no attribute with the token, got %s=%s", attr.Key, attr.Value.Emit())
+		}
+	}
+	if path != "/api/share/:token" {
+		t.Errorf("Expected the route as url.path, got %q", path)
+	}
+}
@@ -0,0 +1,228 @@
+package user
+
+import (
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	errWrongPassword      = apperror.New(http.StatusUnauthorized, "wrong_password", "Current password is incorrect")
+	errEmailTaken         = apperror.New(http.StatusConflict, "email_taken", "Email is already in use")
+	errSameEmail          = apperror.New(http.StatusBadRequest, "same_email", "This is already your email")
+	errNoPendingEmail     = apperror.New(http.StatusBadRequest, "no_pending_email", "No email change in progress")
+	errEmptyProfileUpdate = apperror.New(http.StatusBadRequest, "empty_update", "Nothing to update")
+)
+
+func (uc *UserController) GetMe(c *gin.Context) {
+	user, err := uc.userStore.GetUser(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, meResponse(user))
+}
+
+func (uc *UserController) UpdateMe(c *gin.Context) {
+	var request struct {
+		Name *string `json:"name"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	if request.Name == nil || strings.TrimSpace(*request.Name) == "" {
+		apperror.Abort(c, errEmptyProfileUpdate)
+		return
+	}
+
+	user, err := uc.userStore.UpdateProfile(c, auth.GetUserIdFromContext(c), strings.TrimSpace(*request.Name))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, meResponse(user))
+}
+
+// ChangePassword needs the current password, and logs out every other session.
+func (uc *UserController) ChangePassword(c *gin.Context) {
+	var request struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	userId := auth.GetUserIdFromContext(c)
+	user, err := uc.userStore.GetUser(c, userId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.CurrentPassword)) != nil {
+		apperror.Abort(c, errWrongPassword)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.userStore.UpdatePassword(c, userId, string(hashedPassword))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.sessionStore.RevokeOtherUserSessions(c, userId, auth.GetSessionIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// RequestEmailChange sends an OTP to the new address. The email only changes once ConfirmEmailChange gets that OTP.
+func (uc *UserController) RequestEmailChange(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	userId := auth.GetUserIdFromContext(c)
+	user, err := uc.userStore.GetUser(c, userId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	// accounts created through an oauth provider may not have a password
+	if user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
+		apperror.Abort(c, errWrongPassword)
+		return
+	}
+
+	if request.Email == user.Email {
+		apperror.Abort(c, errSameEmail)
+		return
+	}
+
+	_, err = uc.userStore.GetUserByEmail(c, request.Email)
+	if err == nil {
+		apperror.Abort(c, errEmailTaken)
+		return
+	}
+	if !database.IsNotFound(err) {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	now := time.Now()
+	previous := model.EmailOTP{}
+	if user.PendingEmail != nil {
+		previous = user.PendingEmail.OTP
+		if err = checkResendAllowed(previous, now); err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+	}
+
+	otp, otpState, err := newEmailOTP(previous, now)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.userStore.SetPendingEmail(c, userId, model.PendingEmail{Email: request.Email, OTP: otpState})
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.emailClient.SendEmailChangeMail(c, request.Email, otp)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"pending_email": request.Email})
+}
+
+func (uc *UserController) ConfirmEmailChange(c *gin.Context) {
+	var request struct {
+		OTP string `json:"otp" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	userId := auth.GetUserIdFromContext(c)
+	user, err := uc.userStore.RegisterEmailChangeAttempt(c, userId, maxOTPAttempts)
+	if err != nil {
+		if database.IsNotFound(err) {
+			current, getErr := uc.userStore.GetUser(c, userId)
+			if getErr == nil && current.PendingEmail == nil {
+				apperror.Abort(c, errNoPendingEmail)
+				return
+			}
+			apperror.Abort(c, errOTPLocked)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	if err = checkOTP(user.PendingEmail.OTP, request.OTP, time.Now()); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = uc.userStore.ConfirmEmailChange(c, userId, user.PendingEmail.Email)
+	if err != nil {
+		if database.IsDuplicateKey(err) {
+			apperror.Abort(c, errEmailTaken)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"email": user.PendingEmail.Email, "email_verified": true})
+}
+
+func meResponse(user model.User) gin.H {
+	response := gin.H{
+		"user":               user,
+		"has_password":       user.Password != "",
+		"two_factor_enabled": user.TwoFactor.Enabled,
+	}
+	if user.PendingEmail != nil {
+		response["pending_email"] = user.PendingEmail.Email
+	}
+	return response
+}
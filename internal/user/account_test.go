@@ -0,0 +1,100 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAccountServer serves the handlers that only need the user store, as the user in the X-Test-User header.
+func newAccountServer(store *memory.UserStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	controller := NewUserController(store, memory.NewSessionStore(), &fakeMailer{}, nil, nil, "https://interviewgrab.tech")
+
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	authed := r.Group("/api", func(c *gin.Context) { c.Set("userID", c.GetHeader("X-Test-User")) })
+	authed.GET("/me", controller.GetMe)
+	authed.PATCH("/me", controller.UpdateMe)
+	authed.POST("/verify-email", controller.VerifyEmail)
+	return r
+}
+
+func serve(r *gin.Engine, user model.User, method string, path string, body string) (int, map[string]any) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user.ID.Hex())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestGetAndUpdateMe(t *testing.T) {
+	store := memory.NewUserStore()
+	user, _ := store.CreateUser(context.Background(), model.User{Name: "Jane", Email: "jane@example.com", Password: "hash"})
+	r := newAccountServer(store)
+
+	status, response := serve(r, user, http.MethodGet, "/api/me", "")
+	if status != http.StatusOK || response["has_password"] != true || response["user"].(map[string]any)["email"] != "jane@example.com" {
+		t.Errorf("Expected the user, got %d %v", status, response)
+	}
+
+	if status, response = serve(r, user, http.MethodPatch, "/api/me", `{"name": "  "}`); status != http.StatusBadRequest || response["error_code"] != "empty_update" {
+		t.Errorf("Expected a blank name to be refused, got %d %v", status, response)
+	}
+	if status, response = serve(r, user, http.MethodPatch, "/api/me", `{"name": " Jane Doe "}`); status != http.StatusOK {
+		t.Errorf("Expected the update to succeed, got %d %v", status, response)
+	}
+	if updated, _ := store.GetUser(context.Background(), user.ID); updated.Name != "Jane Doe" {
+		t.Errorf("Expected the trimmed name to be stored, got %q", updated.Name)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	store := memory.NewUserStore()
+	otp, state, err := newEmailOTP(model.EmailOTP{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := store.CreateUser(context.Background(), model.User{Name: "Jane", Email: "jane@example.com", OTP: state})
+	r := newAccountServer(store)
+
+	if status, response := serve(r, user, http.MethodPost, "/api/verify-email", `{"otp": "wrong"}`); status != http.StatusBadRequest || response["error_code"] != "otp_invalid" {
+		t.Errorf("Expected a wrong code to be refused, got %d %v", status, response)
+	}
+	if status, response := serve(r, user, http.MethodPost, "/api/verify-email", `{"otp": "`+otp+`"}`); status != http.StatusAccepted {
+		t.Fatalf("Expected the code to verify the email, got %d %v", status, response)
+	}
+	if verified, _ := store.GetUser(context.Background(), user.ID); !verified.EmailVerified {
+		t.Error("Expected the email to be verified")
+	}
+	if status, response := serve(r, user, http.MethodPost, "/api/verify-email", `{"otp": "`+otp+`"}`); response["error_code"] != "email_already_verified" {
+		t.Errorf("Expected a second verification to be refused, got %d %v", status, response)
+	}
+}
+
+func TestVerifyEmailLocksAfterTooManyAttempts(t *testing.T) {
+	store := memory.NewUserStore()
+	otp, state, _ := newEmailOTP(model.EmailOTP{}, time.Now())
+	user, _ := store.CreateUser(context.Background(), model.User{Name: "Jane", Email: "jane@example.com", OTP: state})
+	r := newAccountServer(store)
+
+	for attempt := 0; attempt < maxOTPAttempts; attempt++ {
+		serve(r, user, http.MethodPost, "/api/verify-email", `{"otp": "wrong"}`)
+	}
+	if status, response := serve(r, user, http.MethodPost, "/api/verify-email", `{"otp": "`+otp+`"}`); status != http.StatusTooManyRequests || response["error_code"] != "otp_locked" {
+		t.Errorf("Expected even the right code to be refused once locked, got %d %v", status, response)
+	}
+}
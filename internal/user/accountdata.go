@@ -0,0 +1,361 @@
+package user
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/background"
+	"resume-service/internal/database"
+	"resume-service/internal/logging"
+	"resume-service/internal/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	deletionGracePeriod = 7 * 24 * time.Hour
+	exportTTL           = 24 * time.Hour
+	exportBuildTimeout  = 10 * time.Minute
+	cleanupInterval     = time.Hour
+	cleanupBatchSize    = 100
+)
+
+var (
+	errExportInProgress = apperror.New(http.StatusConflict, "export_in_progress", "An export is already being prepared")
+	errNoExport         = apperror.New(http.StatusNotFound, "no_export", "No export requested")
+	errNoDeletion       = apperror.New(http.StatusBadRequest, "deletion_not_scheduled", "Account deletion is not scheduled")
+)
+
+// FileStorage is the part of filestore.FileStore the account controller uses.
+type FileStorage interface {
+	Upload(ctx context.Context, key string, fileContent []byte) error
+	Download(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	PresignedDownloadURL(ctx context.Context, key string, fileName string, expiry time.Duration) (string, error)
+}
+
+// AccountController handles account deletion & data export, and the background cleanup both need.
+type AccountController struct {
+	userStore    database.UserRepository
+	resumeStore  database.ResumeRepository
+	sessionStore database.SessionRepository
+	apiKeyStore  database.APIKeyRepository
+	exportStore  database.ExportRepository
+	fileStorage  FileStorage
+	jobs         *background.Jobs
+}
+
+func NewAccountController(userStore database.UserRepository, resumeStore database.ResumeRepository, sessionStore database.SessionRepository, apiKeyStore database.APIKeyRepository, exportStore database.ExportRepository, fileStorage FileStorage, jobs *background.Jobs) *AccountController {
+	return &AccountController{
+		userStore:    userStore,
+		resumeStore:  resumeStore,
+		sessionStore: sessionStore,
+		apiKeyStore:  apiKeyStore,
+		exportStore:  exportStore,
+		fileStorage:  fileStorage,
+		jobs:         jobs,
+	}
+}
+
+// DeleteMe schedules the account for deletion after a grace period, during which CancelDeletion can undo it.
+func (ac *AccountController) DeleteMe(c *gin.Context) {
+	deleteAt := time.Now().Add(deletionGracePeriod)
+	err := ac.userStore.ScheduleDeletion(c, auth.GetUserIdFromContext(c), deleteAt)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"deletion_scheduled_at": deleteAt})
+}
+
+func (ac *AccountController) CancelDeletion(c *gin.Context) {
+	err := ac.userStore.CancelDeletion(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errNoDeletion)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// StartExport starts building an archive of the user's data. GetExport tells when it can be downloaded.
+func (ac *AccountController) StartExport(c *gin.Context) {
+	userId := auth.GetUserIdFromContext(c)
+
+	latest, err := ac.exportStore.GetLatestExport(c, userId)
+	if err == nil && latest.Status == model.ExportPending && time.Since(latest.CreatedAt) < exportBuildTimeout {
+		apperror.Abort(c, errExportInProgress)
+		return
+	}
+	if err != nil && !database.IsNotFound(err) {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	now := time.Now()
+	export, err := ac.exportStore.CreateExport(c, model.Export{
+		UserID:    userId,
+		Status:    model.ExportPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(exportTTL),
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	// the job outlives the request, it only keeps its logger so the export can be traced back to it
+	logger := logging.FromContext(c)
+	ac.jobs.Go("export", func(ctx context.Context) { ac.buildExport(logging.WithLogger(ctx, logger), export) })
+
+	c.JSON(http.StatusAccepted, gin.H{"export": export})
+}
+
+// GetExport returns the latest export, with a download url once it's ready.
+func (ac *AccountController) GetExport(c *gin.Context) {
+	export, err := ac.exportStore.GetLatestExport(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errNoExport)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	response := gin.H{"export": export}
+	if export.Status == model.ExportReady && time.Now().Before(export.ExpiresAt) {
+		url, err := ac.fileStorage.PresignedDownloadURL(c, export.Key, "resume-service-export.zip", time.Until(export.ExpiresAt))
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		response["download_url"] = url
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (ac *AccountController) buildExport(ctx context.Context, export model.Export) {
+	ctx, cancel := context.WithTimeout(ctx, exportBuildTimeout)
+	defer cancel()
+
+	status, key := model.ExportReady, fmt.Sprintf("export-%s-%s.zip", export.UserID.Hex(), uuid.New())
+	archive, err := ac.exportArchive(ctx, export.UserID)
+	if err == nil {
+		err = ac.fileStorage.Upload(ctx, key, archive)
+	}
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "Cannot build export", "export_id", export.ID.Hex(), "error", err)
+		status, key = model.ExportFailed, ""
+	}
+
+	if err = ac.exportStore.UpdateExportStatus(ctx, export.ID, status, key); err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "Cannot update export", "export_id", export.ID.Hex(), "error", err)
+	}
+}
+
+// exportArchive zips the profile, resume metadata & files with their previous versions, share links and api keys
+// of the user.
+func (ac *AccountController) exportArchive(ctx context.Context, userId primitive.ObjectID) ([]byte, error) {
+	user, err := ac.userStore.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	resumes, err := ac.resumeStore.GetResumesByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	shareLinks, err := ac.resumeStore.ListShareLinks(ctx, userId, "", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := ac.apiKeyStore.GetAPIKeysByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[primitive.ObjectID][]model.ResumeVersion{}
+	allVersions := []model.ResumeVersion{}
+	for _, resume := range resumes {
+		resumeVersions, err := ac.resumeStore.ListResumeVersions(ctx, userId, resume.ID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		versions[resume.ID] = resumeVersions
+		allVersions = append(allVersions, resumeVersions...)
+	}
+
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+
+	jsonFiles := map[string]interface{}{
+		"profile.json":         meResponse(user),
+		"resumes.json":         resumes,
+		"resume_versions.json": allVersions,
+		"share_links.json":     shareLinks,
+		"api_keys.json":        apiKeys,
+	}
+	for name, content := range jsonFiles {
+		if err = writeJSONFile(archive, name, content); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, resume := range resumes {
+		if err = ac.writeFile(ctx, archive, resume.Key, archiveFileName(resume.ID.Hex(), resume.FileName)); err != nil {
+			return nil, err
+		}
+		for _, version := range versions[resume.ID] {
+			name := archiveFileName(fmt.Sprintf("%s-v%d", resume.ID.Hex(), version.Version), version.FileName)
+			if err = ac.writeFile(ctx, archive, version.Key, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// archiveFileName keeps a resume file in the resumes folder, whatever its name. Names saved before they were
+// checked can hold slashes or dots that unzip tools would follow. prefix tells files of the same name apart.
+func archiveFileName(prefix string, fileName string) string {
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if name == "." || name == ".." || name == "/" {
+		name = "resume"
+	}
+	return fmt.Sprintf("resumes/%s-%s", prefix, name)
+}
+
+// writeFile copies the stored file with key into the archive.
+func (ac *AccountController) writeFile(ctx context.Context, archive *zip.Writer, key string, name string) error {
+	content, err := ac.fileStorage.Download(ctx, key)
+	if err != nil {
+		return err
+	}
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return err
+}
+
+func writeJSONFile(archive *zip.Writer, name string, content interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(content)
+}
+
+// RunCleanup deletes accounts whose grace period is over and expired exports, until stop is closed.
+// A cleanup that is running when that happens is finished first, unless ctx is cancelled.
+func (ac *AccountController) RunCleanup(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		ac.cleanup(ctx)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ac *AccountController) cleanup(ctx context.Context) {
+	now := time.Now()
+	logger := logging.FromContext(ctx)
+
+	users, err := ac.userStore.GetUsersDueForDeletion(ctx, now, cleanupBatchSize)
+	if err != nil {
+		logger.ErrorContext(ctx, "Cannot find accounts to delete", "error", err)
+	}
+	for _, user := range users {
+		if err = ac.purgeUser(ctx, user.ID); err != nil {
+			logger.ErrorContext(ctx, "Cannot delete account", "user_id", user.ID.Hex(), "error", err)
+		}
+	}
+
+	exports, err := ac.exportStore.GetExpiredExports(ctx, now)
+	if err != nil {
+		logger.ErrorContext(ctx, "Cannot find expired exports", "error", err)
+	}
+	for _, export := range exports {
+		if err = ac.deleteExport(ctx, export); err != nil {
+			logger.ErrorContext(ctx, "Cannot delete export", "export_id", export.ID.Hex(), "error", err)
+		}
+	}
+}
+
+// purgeUser deletes everything the user owns, the user record last so a failed purge is retried.
+func (ac *AccountController) purgeUser(ctx context.Context, userId primitive.ObjectID) error {
+	resumes, err := ac.resumeStore.GetResumesByUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, resume := range resumes {
+		versions, err := ac.resumeStore.ListResumeVersions(ctx, userId, resume.ID.Hex())
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			if err = ac.fileStorage.Delete(ctx, version.Key); err != nil {
+				return err
+			}
+		}
+		if err = ac.fileStorage.Delete(ctx, resume.Key); err != nil {
+			return err
+		}
+	}
+	if err = ac.resumeStore.DeleteResumesByUserId(ctx, userId); err != nil {
+		return err
+	}
+
+	exports, err := ac.exportStore.GetExportsByUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if err = ac.deleteExport(ctx, export); err != nil {
+			return err
+		}
+	}
+
+	if err = ac.sessionStore.DeleteUserSessions(ctx, userId); err != nil {
+		return err
+	}
+	if err = ac.apiKeyStore.DeleteUserAPIKeys(ctx, userId); err != nil {
+		return err
+	}
+	return ac.userStore.DeleteUser(ctx, userId)
+}
+
+func (ac *AccountController) deleteExport(ctx context.Context, export model.Export) error {
+	if export.Key != "" {
+		if err := ac.fileStorage.Delete(ctx, export.Key); err != nil {
+			return err
+		}
+	}
+	return ac.exportStore.DeleteExport(ctx, export.ID)
+}
@@ -0,0 +1,267 @@
+package user
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/background"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/model"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeStorage keeps the files in memory.
+type fakeStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *fakeStorage) Upload(ctx context.Context, key string, fileContent []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = fileContent
+	return nil
+}
+
+func (s *fakeStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.files[key]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return content, nil
+}
+
+func (s *fakeStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+func (s *fakeStorage) PresignedDownloadURL(ctx context.Context, key string, fileName string, expiry time.Duration) (string, error) {
+	return "https://files.example.com/" + key, nil
+}
+
+func (s *fakeStorage) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[key]
+	return ok
+}
+
+type accountDataServer struct {
+	router     *gin.Engine
+	controller *AccountController
+	users      *memory.UserStore
+	resumes    *memory.ResumeStore
+	sessions   *memory.SessionStore
+	apiKeys    *memory.APIKeyStore
+	exports    *memory.ExportStore
+	storage    *fakeStorage
+	jobs       *background.Jobs
+}
+
+// newAccountDataServer serves the deletion and export handlers, as the user in the X-Test-User header.
+func newAccountDataServer() *accountDataServer {
+	gin.SetMode(gin.TestMode)
+	s := &accountDataServer{
+		users:    memory.NewUserStore(),
+		resumes:  memory.NewResumeStore(),
+		sessions: memory.NewSessionStore(),
+		apiKeys:  memory.NewAPIKeyStore(),
+		exports:  memory.NewExportStore(),
+		storage:  &fakeStorage{files: map[string][]byte{}},
+		jobs:     background.NewJobs(),
+	}
+	s.controller = NewAccountController(s.users, s.resumes, s.sessions, s.apiKeys, s.exports, s.storage, s.jobs)
+
+	s.router = gin.New()
+	s.router.Use(apperror.Middleware())
+	authed := s.router.Group("/api", func(c *gin.Context) { c.Set("userID", c.GetHeader("X-Test-User")) })
+	authed.DELETE("/me", s.controller.DeleteMe)
+	authed.POST("/me/cancel-deletion", s.controller.CancelDeletion)
+	authed.POST("/me/export", s.controller.StartExport)
+	authed.GET("/me/export", s.controller.GetExport)
+	return s
+}
+
+// createAccount creates a user with a resume, a session, an api key and an export.
+func (s *accountDataServer) createAccount(t *testing.T, email string, fileName string) (model.User, model.Resume) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := s.users.CreateUser(ctx, model.User{Name: "Jane", Email: email, Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resume, err := s.resumes.StoreResume(ctx, model.Resume{UserID: user.ID, FileName: fileName, Key: "resume-" + email, UploadDate: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.storage.Upload(ctx, resume.Key, []byte("resume of "+email))
+	if _, err = s.sessions.CreateSession(ctx, model.Session{UserID: user.ID, RefreshTokenHash: email, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.apiKeys.CreateAPIKey(ctx, model.APIKey{UserID: user.ID, KeyHash: email}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.storage.Upload(ctx, "export-"+email, []byte("export"))
+	if _, err = s.exports.CreateExport(ctx, model.Export{UserID: user.ID, Status: model.ExportReady, Key: "export-" + email, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	return user, resume
+}
+
+func TestDeleteMe(t *testing.T) {
+	s := newAccountDataServer()
+	user, _ := s.createAccount(t, "jane@example.com", "cv.pdf")
+
+	if status, response := serve(s.router, user, http.MethodDelete, "/api/me", ""); status != http.StatusAccepted || response["deletion_scheduled_at"] == nil {
+		t.Fatalf("Expected the deletion to be scheduled, got %d %v", status, response)
+	}
+	if scheduled, _ := s.users.GetUser(context.Background(), user.ID); scheduled.DeletionScheduledAt == nil || scheduled.DeletionScheduledAt.Before(time.Now().Add(deletionGracePeriod-time.Minute)) {
+		t.Errorf("Expected the deletion after the grace period, got %v", scheduled.DeletionScheduledAt)
+	}
+
+	if status, response := serve(s.router, user, http.MethodPost, "/api/me/cancel-deletion", ""); status != http.StatusOK {
+		t.Fatalf("Expected the deletion to be cancelled, got %d %v", status, response)
+	}
+	if cancelled, _ := s.users.GetUser(context.Background(), user.ID); cancelled.DeletionScheduledAt != nil {
+		t.Errorf("Expected no deletion, got %v", cancelled.DeletionScheduledAt)
+	}
+	if status, response := serve(s.router, user, http.MethodPost, "/api/me/cancel-deletion", ""); status != http.StatusBadRequest || response["error_code"] != errNoDeletion.Code {
+		t.Errorf("Expected cancelling twice to be refused, got %d %v", status, response)
+	}
+}
+
+func TestExport(t *testing.T) {
+	s := newAccountDataServer()
+	user, resume := s.createAccount(t, "jane@example.com", `..\..\cv.pdf`)
+	other, _ := s.createAccount(t, "john@example.com", "other.pdf")
+	_ = s.storage.Upload(context.Background(), "resume-v2", []byte("second version"))
+	_, err := s.resumes.AddResumeVersion(context.Background(), user.ID, resume.ID.Hex(), model.ResumeVersion{FileName: "cv-v2.pdf", Key: "resume-v2", UploadDate: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, response := serve(s.router, user, http.MethodPost, "/api/me/export", ""); status != http.StatusAccepted {
+		t.Fatalf("Expected the export to start, got %d %v", status, response)
+	}
+	if err := s.jobs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	status, response := serve(s.router, user, http.MethodGet, "/api/me/export", "")
+	export, _ := response["export"].(map[string]any)
+	if status != http.StatusOK || export["status"] != model.ExportReady || response["download_url"] == nil {
+		t.Fatalf("Expected the export to be ready, got %d %v", status, response)
+	}
+	latest, _ := s.exports.GetLatestExport(context.Background(), user.ID)
+	content, err := s.storage.Download(context.Background(), latest.Key)
+	if err != nil {
+		t.Fatalf("Expected the archive to be uploaded, got %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, previous := "resumes/"+resume.ID.Hex()+"-cv-v2.pdf", "resumes/"+resume.ID.Hex()+"-v1-cv.pdf"
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		reader, _ := file.Open()
+		saved, _ := io.ReadAll(reader)
+		if file.Name == current && string(saved) != "second version" || file.Name == previous && string(saved) != "resume of jane@example.com" {
+			t.Errorf("Expected the file of %s in the archive, got %q", file.Name, saved)
+		}
+	}
+	sort.Strings(names)
+	expected := []string{"api_keys.json", "profile.json", "resume_versions.json", "resumes.json", current, previous, "share_links.json"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected the files %v, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected the files %v, got %v", expected, names)
+		}
+	}
+
+	if status, response := serve(s.router, other, http.MethodGet, "/api/me/export", ""); status != http.StatusOK || response["export"].(map[string]any)["id"] == export["id"] {
+		t.Errorf("Expected the other user to only see their export, got %d %v", status, response)
+	}
+}
+
+func TestExportInProgress(t *testing.T) {
+	s := newAccountDataServer()
+	user, err := s.users.CreateUser(context.Background(), model.User{Name: "Jane", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, response := serve(s.router, user, http.MethodGet, "/api/me/export", ""); status != http.StatusNotFound || response["error_code"] != errNoExport.Code {
+		t.Errorf("Expected no export yet, got %d %v", status, response)
+	}
+
+	_, _ = s.exports.CreateExport(context.Background(), model.Export{UserID: user.ID, Status: model.ExportPending, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(exportTTL)})
+	if status, response := serve(s.router, user, http.MethodPost, "/api/me/export", ""); status != http.StatusConflict || response["error_code"] != errExportInProgress.Code {
+		t.Errorf("Expected a second export to be refused, got %d %v", status, response)
+	}
+	if status, response := serve(s.router, user, http.MethodGet, "/api/me/export", ""); status != http.StatusOK || response["download_url"] != nil {
+		t.Errorf("Expected a pending export without download url, got %d %v", status, response)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	s := newAccountDataServer()
+	ctx := context.Background()
+	deleted, deletedResume := s.createAccount(t, "jane@example.com", "cv.pdf")
+	kept, keptResume := s.createAccount(t, "john@example.com", "cv.pdf")
+	if err := s.users.ScheduleDeletion(ctx, deleted.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.users.ScheduleDeletion(ctx, kept.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.storage.Upload(ctx, "expired", []byte("export"))
+	expired, _ := s.exports.CreateExport(ctx, model.Export{UserID: kept.ID, Status: model.ExportReady, Key: "expired", CreatedAt: time.Now().Add(-2 * exportTTL), ExpiresAt: time.Now().Add(-exportTTL)})
+
+	s.controller.cleanup(ctx)
+
+	if _, err := s.users.GetUser(ctx, deleted.ID); err == nil {
+		t.Errorf("Expected the user due for deletion to be deleted")
+	}
+	if resumes, _ := s.resumes.GetResumesByUserId(ctx, deleted.ID); len(resumes) != 0 {
+		t.Errorf("Expected the resumes to be deleted, got %+v", resumes)
+	}
+	if keys, _ := s.apiKeys.GetAPIKeysByUserId(ctx, deleted.ID); len(keys) != 0 {
+		t.Errorf("Expected the api keys to be deleted, got %+v", keys)
+	}
+	if exports, _ := s.exports.GetExportsByUserId(ctx, deleted.ID); len(exports) != 0 {
+		t.Errorf("Expected the exports to be deleted, got %+v", exports)
+	}
+	if active, _ := s.sessions.CountActiveSessions(ctx); active != 1 {
+		t.Errorf("Expected only the session of the kept user, got %d", active)
+	}
+	if s.storage.has(deletedResume.Key) || s.storage.has("export-jane@example.com") {
+		t.Errorf("Expected the files of the deleted user to be deleted")
+	}
+
+	if _, err := s.users.GetUser(ctx, kept.ID); err != nil {
+		t.Errorf("Expected the user still in the grace period to be kept, got %v", err)
+	}
+	if !s.storage.has(keptResume.Key) || !s.storage.has("export-john@example.com") {
+		t.Errorf("Expected the files of the kept user to be kept")
+	}
+	if exports, _ := s.exports.GetExportsByUserId(ctx, kept.ID); len(exports) != 1 || exports[0].ID == expired.ID || s.storage.has("expired") {
+		t.Errorf("Expected only the expired export and its file to be deleted, got %+v", exports)
+	}
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxAPIKeysPerUser = 20
+
+var (
+	errInvalidScope   = apperror.New(http.StatusBadRequest, "invalid_scope", "Scopes must be one or more of: read, resumes, generation")
+	errAPIKeyNotFound = apperror.New(http.StatusNotFound, "api_key_not_found", "API key not found")
+	errTooManyAPIKeys = apperror.New(http.StatusConflict, "too_many_api_keys", fmt.Sprintf("A user can have at most %d API keys", maxAPIKeysPerUser))
+)
+
+type APIKeyController struct {
+	apiKeyStore database.APIKeyRepository
+}
+
+func NewAPIKeyController(store database.APIKeyRepository) *APIKeyController {
+	return &APIKeyController{apiKeyStore: store}
+}
+
+// CreateAPIKey returns the new key, this is the only time it's shown.
+func (ac *APIKeyController) CreateAPIKey(c *gin.Context) {
+	var request struct {
+		Name   string   `json:"name" binding:"required"`
+		Scopes []string `json:"scopes" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	if len(request.Scopes) == 0 {
+		apperror.Abort(c, errInvalidScope)
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !auth.IsValidScope(scope) {
+			apperror.Abort(c, errInvalidScope)
+			return
+		}
+	}
+
+	userId := auth.GetUserIdFromContext(c)
+	keys, err := ac.apiKeyStore.GetAPIKeysByUserId(c, userId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if len(keys) >= maxAPIKeysPerUser {
+		apperror.Abort(c, errTooManyAPIKeys)
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	apiKey, err := ac.apiKeyStore.CreateAPIKey(c, model.APIKey{
+		UserID:    userId,
+		Name:      request.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    request.Scopes,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": apiKey, "key": key})
+}
+
+func (ac *APIKeyController) ListAPIKeys(c *gin.Context) {
+	keys, err := ac.apiKeyStore.GetAPIKeysByUserId(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (ac *APIKeyController) RevokeAPIKey(c *gin.Context) {
+	keyId := c.Param("key_id")
+
+	err := ac.apiKeyStore.RevokeAPIKey(c, auth.GetUserIdFromContext(c), keyId)
+	if err != nil {
+		if database.IsNotFound(err) || errors.Is(err, primitive.ErrInvalidHex) {
+			apperror.Abort(c, errAPIKeyNotFound)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
@@ -1,26 +1,47 @@
-// TODO: return text error strings instead of errors received from bCrypt / Database
 package user
 
 import (
-	"errors"
+	"context"
 	"net/http"
+	"resume-service/internal/apperror"
 	"resume-service/internal/auth"
-	"resume-service/internal/clients/email"
+	"resume-service/internal/background"
 	"resume-service/internal/database"
+	"resume-service/internal/logging"
 	"resume-service/internal/model"
-	"resume-service/internal/utils"
+	"resume-service/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	errInvalidCredentials = apperror.New(http.StatusUnauthorized, "invalid_credentials", "Email or password is incorrect")
+	errInvalidResetLink   = apperror.New(http.StatusBadRequest, "invalid_reset_link", "Reset link is invalid or expired")
+	errAccountLocked      = apperror.New(http.StatusTooManyRequests, "account_locked", "Too many failed attempts, try again later")
+)
+
+// Mailer is the part of email.EmailClient the user controllers use.
+type Mailer interface {
+	SendMail(ctx context.Context, to string, otp string) error
+	SendPasswordResetMail(ctx context.Context, to string, resetLink string) error
+	SendEmailChangeMail(ctx context.Context, to string, otp string) error
+}
+
 type UserController struct {
-	userStore   *database.UserStore
-	emailClient *email.EmailClient
+	userStore    database.UserRepository
+	sessionStore database.SessionRepository
+	emailClient  Mailer
+	lockout      *ratelimit.Lockout
+	jobs         *background.Jobs
+	appURL       string
 }
 
-func NewUserController(store *database.UserStore, emailClient *email.EmailClient) *UserController {
-	return &UserController{userStore: store, emailClient: emailClient}
+func NewUserController(store database.UserRepository, sessionStore database.SessionRepository, emailClient Mailer, lockout *ratelimit.Lockout, jobs *background.Jobs, appURL string) *UserController {
+	return &UserController{userStore: store, sessionStore: sessionStore, emailClient: emailClient, lockout: lockout, jobs: jobs, appURL: appURL}
 }
 
 func (uc *UserController) Signup(c *gin.Context) {
@@ -31,19 +52,19 @@ func (uc *UserController) Signup(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	otp, err := GenerateOTP(6)
+	otp, otpState, err := newEmailOTP(model.EmailOTP{}, time.Now())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
@@ -53,26 +74,30 @@ func (uc *UserController) Signup(c *gin.Context) {
 		Email:         request.Email,
 		Password:      request.Password,
 		EmailVerified: false,
-		EmailToken:    otp,
+		OTP:           otpState,
 	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+		if database.IsDuplicateKey(err) {
+			apperror.Abort(c, errEmailTaken)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	token, err := auth.GenerateJWTToken(newUser)
+	tokens, err := auth.IssueTokens(c, uc.sessionStore, newUser.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	err = uc.emailClient.SendMail(request.Email, otp)
+	err = uc.emailClient.SendMail(c, request.Email, otp)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, ginToken(token))
+	c.JSON(http.StatusCreated, tokens)
 }
 
 func (uc *UserController) Login(c *gin.Context) {
@@ -82,55 +107,98 @@ func (uc *UserController) Login(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&loginData); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
 
+	lockKey := "login:" + strings.ToLower(loginData.Email)
+	if uc.isLockedOut(c, lockKey) {
+		return
+	}
+
+	// unknown emails get the same answer as wrong passwords, so logins can't be used to find accounts
 	user, err := uc.userStore.GetUserByEmail(c, loginData.Email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, utils.GinError(err))
+		if !database.IsNotFound(err) {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		if !uc.registerFailure(c, lockKey) {
+			apperror.Abort(c, errInvalidCredentials)
+		}
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, utils.GinError(err))
+		if !uc.registerFailure(c, lockKey) {
+			apperror.Abort(c, errInvalidCredentials.Wrap(err))
+		}
 		return
 	}
 
-	token, err := auth.GenerateJWTToken(user)
+	_ = uc.lockout.Succeed(c, lockKey)
+	loginResponse(c, uc.sessionStore, user, http.StatusOK)
+}
+
+func (uc *UserController) RefreshToken(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	tokens, err := auth.RefreshTokens(c, uc.sessionStore, request.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ginToken(token))
+	c.JSON(http.StatusOK, tokens)
 }
 
 func (u *UserController) ResendOTP(c *gin.Context) {
 	userId := auth.GetUserIdFromContext(c)
 	user, err := u.userStore.GetUser(c, userId)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
 
 	if user.EmailVerified {
-		c.JSON(http.StatusAlreadyReported, utils.GinError(errors.New("email already verified")))
+		apperror.Abort(c, errEmailAlreadyVerified)
 		return
 	}
 
-	otp, err := GenerateOTP(6)
+	now := time.Now()
+	if err = checkResendAllowed(user.OTP, now); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	otp, otpState, err := newEmailOTP(user.OTP, now)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	err = u.emailClient.SendMail(user.Email, otp)
+	err = u.userStore.ResetEmailOTP(c, userId, otpState, user.OTP.IssuedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		if database.IsNotFound(err) {
+			// another resend won the race
+			apperror.Abort(c, errOTPResendCooldown)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 
-	err = u.userStore.ResetEmailOTP(c, userId, otp)
+	err = u.emailClient.SendMail(c, user.Email, otp)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 	c.JSON(http.StatusAccepted, gin.H{"otp_resent": true})
@@ -142,24 +210,135 @@ func (u *UserController) VerifyEmail(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+		apperror.Abort(c, apperror.InvalidRequest(err))
 		return
 	}
 
 	userId := auth.GetUserIdFromContext(c)
 
-	err := u.userStore.VerifyEmail(c, userId, request.OTP)
+	user, err := u.userStore.RegisterOTPAttempt(c, userId, maxOTPAttempts)
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errOTPLocked)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	if user.EmailVerified {
+		apperror.Abort(c, errEmailAlreadyVerified)
+		return
+	}
+
+	if err = checkOTP(user.OTP, request.OTP, time.Now()); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = u.userStore.VerifyEmail(c, userId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.GinError(err))
+		apperror.Abort(c, apperror.Internal(err))
 		return
 	}
 	c.JSON(http.StatusAccepted, gin.H{"email_verified": true})
 }
 
+// ForgotPassword always answers the same way, so it can't be used to find out which emails have an account.
+func (uc *UserController) ForgotPassword(c *gin.Context) {
+	var request struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	// sent in the background, so response time doesn't depend on the email existing either
+	logger := logging.FromContext(c)
+	uc.jobs.Go("password reset", func(ctx context.Context) {
+		uc.sendPasswordReset(logging.WithLogger(ctx, logger), request.Email)
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	user, err := uc.userStore.ResetPassword(c, auth.HashToken(request.Token), string(hashedPassword))
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errInvalidResetLink)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.sessionStore.RevokeUserSessions(c, user.ID)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successful"})
+}
+
 func (uc *UserController) Logout(c *gin.Context) {
+	err := uc.sessionStore.RevokeSession(c, auth.GetSessionIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
 
-func ginToken(token string) gin.H {
-	return gin.H{"token": token}
+func (uc *UserController) LogoutAll(c *gin.Context) {
+	err := uc.sessionStore.RevokeUserSessions(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// isLockedOut responds for keys locked after too many failed attempts.
+func (uc *UserController) isLockedOut(c *gin.Context, key string) bool {
+	locked, err := uc.lockout.Check(c, key, time.Now())
+	if err != nil || locked == 0 {
+		return false
+	}
+	lockedOutJSON(c, locked)
+	return true
+}
+
+// registerFailure counts a failed attempt, and responds if that locked the key.
+func (uc *UserController) registerFailure(c *gin.Context, key string) bool {
+	locked, err := uc.lockout.Fail(c, key, time.Now())
+	if err != nil || locked == 0 {
+		return false
+	}
+	lockedOutJSON(c, locked)
+	return true
+}
+
+func lockedOutJSON(c *gin.Context, locked time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(locked.Seconds())+1))
+	apperror.Abort(c, errAccountLocked)
 }
@@ -0,0 +1,256 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/ratelimit"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeMailer records the mails instead of sending them.
+type fakeMailer struct {
+	mu    sync.Mutex
+	mails []string
+}
+
+func (m *fakeMailer) record(kind string, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, kind+" "+to)
+	return nil
+}
+
+func (m *fakeMailer) SendMail(ctx context.Context, to string, otp string) error {
+	return m.record("otp", to)
+}
+
+func (m *fakeMailer) SendPasswordResetMail(ctx context.Context, to string, resetLink string) error {
+	return m.record("password_reset", to)
+}
+
+func (m *fakeMailer) SendEmailChangeMail(ctx context.Context, to string, otp string) error {
+	return m.record("email_change", to)
+}
+
+type userServer struct {
+	router   *gin.Engine
+	users    *memory.UserStore
+	sessions *memory.SessionStore
+	mailer   *fakeMailer
+}
+
+// newUserServer serves the login and session handlers like main does, authenticating with access tokens.
+func newUserServer() *userServer {
+	gin.SetMode(gin.TestMode)
+	auth.SetJWTSecret("test-secret")
+	s := &userServer{users: memory.NewUserStore(), sessions: memory.NewSessionStore(), mailer: &fakeMailer{}}
+	lockout := &ratelimit.Lockout{Store: ratelimit.NewMemoryStore(), Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	controller := NewUserController(s.users, s.sessions, s.mailer, lockout, nil, "https://interviewgrab.tech")
+
+	s.router = gin.New()
+	s.router.Use(apperror.Middleware())
+	public := s.router.Group("/api")
+	public.POST("/signup", controller.Signup)
+	public.POST("/login", controller.Login)
+	public.POST("/token/refresh", controller.RefreshToken)
+	authed := s.router.Group("/api", auth.Middleware(s.users, s.sessions, nil), auth.SessionOnly())
+	authed.POST("/logout", controller.Logout)
+	authed.POST("/logout-all", controller.LogoutAll)
+	authed.GET("/me", controller.GetMe)
+	return s
+}
+
+func (s *userServer) serve(method string, path string, accessToken string, body string) (int, map[string]any) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var response map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// tokens returns the access and refresh token of a response.
+func tokens(response map[string]any) (string, string) {
+	accessToken, _ := response["token"].(string)
+	refreshToken, _ := response["refresh_token"].(string)
+	return accessToken, refreshToken
+}
+
+func (s *userServer) signup(t *testing.T, email string) (string, string) {
+	t.Helper()
+	status, response := s.serve(http.MethodPost, "/api/signup", "", `{"name": "Jane", "email": "`+email+`", "password": "hunter22"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected the signup to succeed, got %d %v", status, response)
+	}
+	return tokens(response)
+}
+
+func TestSignup(t *testing.T) {
+	s := newUserServer()
+	accessToken, refreshToken := s.signup(t, "jane@example.com")
+	if accessToken == "" || refreshToken == "" {
+		t.Fatalf("Expected tokens, got %q and %q", accessToken, refreshToken)
+	}
+
+	user, err := s.users.GetUserByEmail(context.Background(), "jane@example.com")
+	if err != nil || user.EmailVerified || user.Password == "hunter22" || user.OTP.Hash == "" {
+		t.Errorf("Expected an unverified user with a hashed password and an OTP, got %+v %v", user, err)
+	}
+	if len(s.mailer.mails) != 1 || s.mailer.mails[0] != "otp jane@example.com" {
+		t.Errorf("Expected the OTP to be mailed, got %v", s.mailer.mails)
+	}
+	if status, response := s.serve(http.MethodGet, "/api/me", accessToken, ""); status != http.StatusOK {
+		t.Errorf("Expected the access token to work, got %d %v", status, response)
+	}
+
+	status, response := s.serve(http.MethodPost, "/api/signup", "", `{"name": "Other", "email": "jane@example.com", "password": "secret"}`)
+	if status != http.StatusConflict || response["error_code"] != errEmailTaken.Code {
+		t.Errorf("Expected a taken email to be refused, got %d %v", status, response)
+	}
+	if status, _ = s.serve(http.MethodPost, "/api/signup", "", `{"email": "john@example.com"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected a signup without password to be refused, got %d", status)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newUserServer()
+	s.signup(t, "jane@example.com")
+
+	status, response := s.serve(http.MethodPost, "/api/login", "", `{"email": "jane@example.com", "password": "hunter22"}`)
+	if accessToken, refreshToken := tokens(response); status != http.StatusOK || accessToken == "" || refreshToken == "" {
+		t.Fatalf("Expected tokens, got %d %v", status, response)
+	}
+
+	for _, body := range []string{
+		`{"email": "jane@example.com", "password": "wrong"}`,
+		`{"email": "nobody@example.com", "password": "hunter22"}`,
+	} {
+		status, response = s.serve(http.MethodPost, "/api/login", "", body)
+		if status != http.StatusUnauthorized || response["error_code"] != errInvalidCredentials.Code {
+			t.Errorf("Expected the same answer for wrong passwords and unknown emails, got %d %v", status, response)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		s.serve(http.MethodPost, "/api/login", "", `{"email": "jane@example.com", "password": "wrong"}`)
+	}
+	status, response = s.serve(http.MethodPost, "/api/login", "", `{"email": "jane@example.com", "password": "hunter22"}`)
+	if status != http.StatusTooManyRequests || response["error_code"] != errAccountLocked.Code {
+		t.Errorf("Expected the account to be locked after failed logins, got %d %v", status, response)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	s := newUserServer()
+	_, refreshToken := s.signup(t, "jane@example.com")
+
+	status, response := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+refreshToken+`"}`)
+	accessToken, rotated := tokens(response)
+	if status != http.StatusOK || accessToken == "" || rotated == refreshToken {
+		t.Fatalf("Expected new tokens, got %d %v", status, response)
+	}
+	if status, response = s.serve(http.MethodGet, "/api/me", accessToken, ""); status != http.StatusOK {
+		t.Errorf("Expected the new access token to work, got %d %v", status, response)
+	}
+
+	status, response = s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "not-a-token"}`)
+	if status != http.StatusUnauthorized || response["error_code"] != auth.ErrInvalidRefreshToken.Code {
+		t.Errorf("Expected a malformed token to be refused, got %d %v", status, response)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	s := newUserServer()
+	_, refreshToken := s.signup(t, "jane@example.com")
+	_, response := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+refreshToken+`"}`)
+	accessToken, rotated := tokens(response)
+	sessionId, _, _ := strings.Cut(rotated, ".")
+
+	status, response := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+sessionId+`.guessed"}`)
+	if status != http.StatusUnauthorized || response["error_code"] != auth.ErrInvalidRefreshToken.Code {
+		t.Errorf("Expected a wrong secret to be refused, got %d %v", status, response)
+	}
+	if status, response = s.serve(http.MethodGet, "/api/me", accessToken, ""); status != http.StatusOK {
+		t.Fatalf("Expected a wrong secret not to revoke the session, got %d %v", status, response)
+	}
+
+	status, response = s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+refreshToken+`"}`)
+	if status != http.StatusUnauthorized || response["error_code"] != auth.ErrRefreshTokenReused.Code {
+		t.Errorf("Expected the rotated token to be detected as reused, got %d %v", status, response)
+	}
+	if status, _ = s.serve(http.MethodGet, "/api/me", accessToken, ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected reuse to revoke the session, got %d", status)
+	}
+	if status, _ = s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+rotated+`"}`); status != http.StatusUnauthorized {
+		t.Errorf("Expected the current token of a revoked session to be refused, got %d", status)
+	}
+}
+
+func TestRefreshTokenReuseOfOlderToken(t *testing.T) {
+	s := newUserServer()
+	_, first := s.signup(t, "jane@example.com")
+	current, accessToken := first, ""
+	for i := 0; i < 3; i++ {
+		status, response := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+current+`"}`)
+		if status != http.StatusOK {
+			t.Fatalf("Expected rotation %d to succeed, got %d %v", i+1, status, response)
+		}
+		accessToken, current = tokens(response)
+	}
+
+	status, response := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+first+`"}`)
+	if status != http.StatusUnauthorized || response["error_code"] != auth.ErrRefreshTokenReused.Code {
+		t.Errorf("Expected the first token to be detected as reused, got %d %v", status, response)
+	}
+	if status, _ = s.serve(http.MethodGet, "/api/me", accessToken, ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected reuse of an older token to revoke the session, got %d", status)
+	}
+	if status, _ = s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+current+`"}`); status != http.StatusUnauthorized {
+		t.Errorf("Expected the current token of a revoked session to be refused, got %d", status)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s := newUserServer()
+	accessToken, refreshToken := s.signup(t, "jane@example.com")
+	_, response := s.serve(http.MethodPost, "/api/login", "", `{"email": "jane@example.com", "password": "hunter22"}`)
+	otherAccess, otherRefresh := tokens(response)
+
+	if status, response := s.serve(http.MethodPost, "/api/logout", accessToken, ""); status != http.StatusOK {
+		t.Fatalf("Expected the logout to succeed, got %d %v", status, response)
+	}
+	if status, response := s.serve(http.MethodGet, "/api/me", accessToken, ""); status != http.StatusUnauthorized || response["error_code"] != auth.ErrSessionRevoked.Code {
+		t.Errorf("Expected the access token of the session to stop working, got %d %v", status, response)
+	}
+	if status, _ := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+refreshToken+`"}`); status != http.StatusUnauthorized {
+		t.Errorf("Expected the refresh token of the session to stop working, got %d", status)
+	}
+	if status, response := s.serve(http.MethodGet, "/api/me", otherAccess, ""); status != http.StatusOK {
+		t.Errorf("Expected the other session to be kept, got %d %v", status, response)
+	}
+
+	if status, response := s.serve(http.MethodPost, "/api/logout-all", otherAccess, ""); status != http.StatusOK {
+		t.Fatalf("Expected logging out everywhere to succeed, got %d %v", status, response)
+	}
+	if status, _ := s.serve(http.MethodPost, "/api/token/refresh", "", `{"refresh_token": "`+otherRefresh+`"}`); status != http.StatusUnauthorized {
+		t.Errorf("Expected every session to be revoked, got %d", status)
+	}
+	if status, _ := s.serve(http.MethodPost, "/api/logout", "", ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected a logout without token to be refused, got %d", status)
+	}
+}
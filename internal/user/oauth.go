@@ -0,0 +1,196 @@
+package user
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/clients/oidc"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oauthStateTTL = 10 * time.Minute
+	// oauthStateCookie binds a login to the browser that started it, so a victim can't be made to finish a
+	// login started by someone else.
+	oauthStateCookie = "oauth_state"
+)
+
+var (
+	errUnknownProvider   = apperror.New(http.StatusNotFound, "unknown_provider", "Unknown login provider")
+	errInvalidOAuthState = apperror.New(http.StatusBadRequest, "oauth_state_invalid", "Login expired, please try again")
+	errOAuthNoEmail      = apperror.New(http.StatusConflict, "oauth_no_email", "Login provider did not share an email address")
+	errOAuthFailed       = apperror.New(http.StatusUnauthorized, "oauth_failed", "Login with the provider failed, please try again")
+	errOAuthEmailTaken   = apperror.New(http.StatusConflict, "oauth_email_taken", "An account with this email exists, log in with your password to link it")
+)
+
+type OAuthController struct {
+	userStore    database.UserRepository
+	sessionStore database.SessionRepository
+	stateStore   database.OAuthStateRepository
+	emailClient  Mailer
+	providers    map[string]*oidc.Provider
+}
+
+func NewOAuthController(store database.UserRepository, sessionStore database.SessionRepository, stateStore database.OAuthStateRepository, emailClient Mailer, providers map[string]*oidc.Provider) *OAuthController {
+	return &OAuthController{userStore: store, sessionStore: sessionStore, stateStore: stateStore, emailClient: emailClient, providers: providers}
+}
+
+// Start returns the provider url the frontend should send the user to, and sets the state cookie Callback
+// needs, so both have to be called with credentials.
+func (oc *OAuthController) Start(c *gin.Context) {
+	provider, ok := oc.providers[c.Param("provider")]
+	if !ok {
+		apperror.Abort(c, errUnknownProvider)
+		return
+	}
+
+	state, err := auth.GenerateSecureToken()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	nonce, err := auth.GenerateSecureToken()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	verifier, err := auth.GenerateSecureToken()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	authUrl, err := provider.AuthCodeURL(c, state, nonce, verifier)
+	if err != nil {
+		apperror.Abort(c, apperror.ErrUpstream.Wrap(err))
+		return
+	}
+
+	err = oc.stateStore.StoreState(c, model.OAuthState{
+		ID:        auth.HashToken(state),
+		Provider:  provider.Name(),
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, auth.HashToken(state), int(oauthStateTTL.Seconds()), "/", "", true, true)
+	c.JSON(http.StatusOK, gin.H{"auth_url": authUrl})
+}
+
+// Callback finishes the login with the code & state the provider redirected the user back with.
+func (oc *OAuthController) Callback(c *gin.Context) {
+	var request struct {
+		Code  string `json:"code" binding:"required"`
+		State string `json:"state" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	provider, ok := oc.providers[c.Param("provider")]
+	if !ok {
+		apperror.Abort(c, errUnknownProvider)
+		return
+	}
+
+	stateHash := auth.HashToken(request.State)
+	cookie, err := c.Cookie(oauthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(stateHash)) != 1 {
+		apperror.Abort(c, errInvalidOAuthState)
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, "/", "", true, true)
+
+	state, err := oc.stateStore.ConsumeState(c, stateHash, provider.Name())
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errInvalidOAuthState)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	identity, err := provider.Exchange(c, request.Code, state.Nonce, state.Verifier)
+	if err != nil {
+		apperror.Abort(c, errOAuthFailed.Wrap(err))
+		return
+	}
+
+	user, err := oc.findOrCreateUser(c, identity)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	loginResponse(c, oc.sessionStore, user, http.StatusOK)
+}
+
+// findOrCreateUser logs in the user linked to the identity. Identities are linked to existing accounts only
+// when both the provider and the account verified the email. Otherwise anyone could take over an account at a lax
+// provider, or whoever signed up first with someone's email would keep a password to their account.
+func (oc *OAuthController) findOrCreateUser(ctx context.Context, identity oidc.Identity) (model.User, error) {
+	link := model.LinkedIdentity{Provider: identity.Provider, Subject: identity.Subject}
+
+	user, err := oc.userStore.GetUserByIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !database.IsNotFound(err) {
+		return model.User{}, err
+	}
+
+	if identity.Email == "" {
+		return model.User{}, errOAuthNoEmail
+	}
+
+	user, err = oc.userStore.GetUserByEmail(ctx, identity.Email)
+	if err == nil {
+		if !identity.EmailVerified || !user.EmailVerified {
+			return model.User{}, errOAuthEmailTaken
+		}
+		return user, oc.userStore.LinkIdentity(ctx, user.ID, link)
+	}
+	if !database.IsNotFound(err) {
+		return model.User{}, err
+	}
+
+	newUser := model.User{
+		Name:          identity.Name,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Identities:    []model.LinkedIdentity{link},
+	}
+	otp := ""
+	if !identity.EmailVerified {
+		otp, newUser.OTP, err = newEmailOTP(model.EmailOTP{}, time.Now())
+		if err != nil {
+			return model.User{}, err
+		}
+	}
+
+	newUser, err = oc.userStore.CreateUser(ctx, newUser)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if otp != "" {
+		err = oc.emailClient.SendMail(ctx, newUser.Email, otp)
+	}
+	return newUser, err
+}
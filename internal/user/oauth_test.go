@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"resume-service/internal/apperror"
+	"resume-service/internal/clients/oidc"
+	"resume-service/internal/database/memory"
+	"resume-service/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFindOrCreateUserLinking(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name             string
+		accountVerified  bool
+		providerVerified bool
+		linked           bool
+	}{
+		{name: "both verified", accountVerified: true, providerVerified: true, linked: true},
+		{name: "account not verified", accountVerified: false, providerVerified: true},
+		{name: "provider not verified", accountVerified: true, providerVerified: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			store := memory.NewUserStore()
+			existing, err := store.CreateUser(ctx, model.User{Email: "jane@example.com", Password: "hash", EmailVerified: test.accountVerified})
+			if err != nil {
+				t.Fatalf("Cannot create user: %v", err)
+			}
+			controller := NewOAuthController(store, memory.NewSessionStore(), nil, &fakeMailer{}, nil)
+
+			identity := oidc.Identity{Provider: "google", Subject: "123", Email: "jane@example.com", EmailVerified: test.providerVerified}
+			user, err := controller.findOrCreateUser(ctx, identity)
+			if test.linked {
+				if err != nil || user.ID != existing.ID {
+					t.Fatalf("Expected the identity to log in the existing user, got %+v %v", user, err)
+				}
+			} else if !errors.Is(err, errOAuthEmailTaken) {
+				t.Fatalf("Expected %v, got %+v %v", errOAuthEmailTaken, user, err)
+			}
+
+			_, err = store.GetUserByIdentity(ctx, "google", "123")
+			if linked := err == nil; linked != test.linked {
+				t.Errorf("Expected the identity to be linked: %v, got %v", test.linked, linked)
+			}
+		})
+	}
+}
+
+func TestCallbackRequiresTheStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// the provider refuses every code, getting to the exchange is what counts
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer tokenServer.Close()
+	provider := oidc.NewProvider(oidc.ProviderConfig{Name: "github", AuthURL: "https://github.example/authorize", TokenURL: tokenServer.URL})
+	controller := NewOAuthController(memory.NewUserStore(), memory.NewSessionStore(), memory.NewOAuthStateStore(), &fakeMailer{}, map[string]*oidc.Provider{"github": provider})
+
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	r.GET("/api/oauth/:provider/start", controller.Start)
+	r.POST("/api/oauth/:provider/callback", controller.Callback)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/oauth/github/start", nil))
+	var response struct {
+		AuthURL string `json:"auth_url"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	authUrl, err := url.Parse(response.AuthURL)
+	if w.Code != http.StatusOK || err != nil {
+		t.Fatalf("Expected an auth url, got %d %s", w.Code, w.Body.String())
+	}
+	state := authUrl.Query().Get("state")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookie || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected an http only, same site lax state cookie, got %+v", cookies)
+	}
+
+	callback := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/oauth/github/callback", strings.NewReader(`{"code": "code", "state": "`+state+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for name, cookie := range map[string]*http.Cookie{
+		"without a cookie":              nil,
+		"with another browser's cookie": {Name: oauthStateCookie, Value: "other"},
+	} {
+		if w := callback(cookie); w.Code != errInvalidOAuthState.Status || !strings.Contains(w.Body.String(), errInvalidOAuthState.Code) {
+			t.Errorf("Expected the callback %s to be refused, got %d %s", name, w.Code, w.Body.String())
+		}
+	}
+	if w := callback(cookies[0]); w.Code != errOAuthFailed.Status || !strings.Contains(w.Body.String(), errOAuthFailed.Code) {
+		t.Errorf("Expected the callback with the cookie to exchange the code, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,89 @@
+package user
+
+import (
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/model"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	otpLength           = 6
+	otpTTL              = 10 * time.Minute
+	maxOTPAttempts      = 5
+	otpResendCooldown   = time.Minute
+	otpResendWindow     = 24 * time.Hour
+	maxOTPResendsPerDay = 5
+)
+
+// every way an OTP can be refused has its own error code
+var (
+	errOTPInvalid           = apperror.New(http.StatusBadRequest, "otp_invalid", "The code is incorrect")
+	errOTPExpired           = apperror.New(http.StatusBadRequest, "otp_expired", "The code has expired, request a new one")
+	errOTPLocked            = apperror.New(http.StatusTooManyRequests, "otp_locked", "Too many incorrect codes, request a new one later")
+	errOTPResendCooldown    = apperror.New(http.StatusTooManyRequests, "otp_resend_cooldown", "Wait a minute before requesting another code")
+	errOTPResendLimit       = apperror.New(http.StatusTooManyRequests, "otp_resend_limit", "Too many codes requested today, try again tomorrow")
+	errEmailAlreadyVerified = apperror.New(http.StatusAlreadyReported, "email_already_verified", "Email is already verified")
+)
+
+// newEmailOTP generates an OTP and the state to store for it. The resend counters and failed attempts of
+// `previous` are carried over within the resend window, otherwise every resend would allow more guesses. Pass an
+// empty EmailOTP for the first OTP of an account.
+func newEmailOTP(previous model.EmailOTP, now time.Time) (string, model.EmailOTP, error) {
+	otp, err := GenerateOTP(otpLength)
+	if err != nil {
+		return "", model.EmailOTP{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(otp), bcrypt.DefaultCost)
+	if err != nil {
+		return "", model.EmailOTP{}, err
+	}
+
+	state := model.EmailOTP{
+		Hash:              string(hash),
+		IssuedAt:          now,
+		ExpiresAt:         now.Add(otpTTL),
+		ResendCount:       previous.ResendCount,
+		ResendWindowStart: previous.ResendWindowStart,
+		Attempts:          previous.Attempts,
+	}
+	if !previous.IssuedAt.IsZero() {
+		if now.Sub(previous.ResendWindowStart) >= otpResendWindow {
+			state.ResendWindowStart = now
+			state.ResendCount = 0
+			state.Attempts = 0
+		}
+		state.ResendCount++
+	}
+	return otp, state, nil
+}
+
+func checkResendAllowed(state model.EmailOTP, now time.Time) error {
+	if now.Sub(state.IssuedAt) < otpResendCooldown {
+		return errOTPResendCooldown
+	}
+	if now.Sub(state.ResendWindowStart) < otpResendWindow && state.ResendCount >= maxOTPResendsPerDay {
+		return errOTPResendLimit
+	}
+	return nil
+}
+
+// checkOTP compares an OTP with the stored state. Attempts have to be counted before calling it.
+func checkOTP(state model.EmailOTP, otp string, now time.Time) error {
+	if state.Hash == "" {
+		return errOTPInvalid
+	}
+	if now.After(state.ExpiresAt) {
+		return errOTPExpired
+	}
+	if bcrypt.CompareHashAndPassword([]byte(state.Hash), []byte(otp)) != nil {
+		if state.Attempts >= maxOTPAttempts {
+			return errOTPLocked
+		}
+		return errOTPInvalid
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package user
+
+import (
+	"errors"
+	"resume-service/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckOTP(t *testing.T) {
+	now := time.Now()
+	otp, state, err := newEmailOTP(model.EmailOTP{}, now)
+	if err != nil {
+		t.Fatalf("Error generating otp: %v", err)
+	}
+	if state.Hash == otp || len(otp) != otpLength {
+		t.Fatalf("Otp should be %d digits and stored hashed", otpLength)
+	}
+
+	if err = checkOTP(state, otp, now); err != nil {
+		t.Errorf("Expected otp to verify, got %v", err)
+	}
+	if err = checkOTP(state, "not-it", now); !errors.Is(err, errOTPInvalid) {
+		t.Errorf("Expected %v, got %v", errOTPInvalid, err)
+	}
+	if err = checkOTP(state, otp, now.Add(otpTTL+time.Second)); !errors.Is(err, errOTPExpired) {
+		t.Errorf("Expected %v, got %v", errOTPExpired, err)
+	}
+
+	state.Attempts = maxOTPAttempts
+	if err = checkOTP(state, "not-it", now); !errors.Is(err, errOTPLocked) {
+		t.Errorf("Expected %v, got %v", errOTPLocked, err)
+	}
+}
+
+func TestCheckResendAllowed(t *testing.T) {
+	now := time.Now()
+	_, state, err := newEmailOTP(model.EmailOTP{}, now)
+	if err != nil {
+		t.Fatalf("Error generating otp: %v", err)
+	}
+
+	if err = checkResendAllowed(state, now.Add(time.Second)); !errors.Is(err, errOTPResendCooldown) {
+		t.Errorf("Expected %v, got %v", errOTPResendCooldown, err)
+	}
+
+	for i := 0; i < maxOTPResendsPerDay; i++ {
+		now = now.Add(otpResendCooldown)
+		if err = checkResendAllowed(state, now); err != nil {
+			t.Fatalf("Resend %d should be allowed, got %v", i+1, err)
+		}
+		_, state, err = newEmailOTP(state, now)
+		if err != nil {
+			t.Fatalf("Error generating otp: %v", err)
+		}
+	}
+
+	now = now.Add(otpResendCooldown)
+	if err = checkResendAllowed(state, now); !errors.Is(err, errOTPResendLimit) {
+		t.Errorf("Expected %v, got %v", errOTPResendLimit, err)
+	}
+	if err = checkResendAllowed(state, state.ResendWindowStart.Add(otpResendWindow)); err != nil {
+		t.Errorf("Expected resends to be allowed the next day, got %v", err)
+	}
+}
+
+func TestResendKeepsAttempts(t *testing.T) {
+	now := time.Now()
+	_, state, err := newEmailOTP(model.EmailOTP{}, now)
+	if err != nil {
+		t.Fatalf("Error generating otp: %v", err)
+	}
+
+	// the first resend starts the window
+	now = now.Add(otpResendCooldown)
+	if _, state, err = newEmailOTP(state, now); err != nil {
+		t.Fatalf("Error generating otp: %v", err)
+	}
+	state.Attempts = maxOTPAttempts
+	now = now.Add(otpResendCooldown)
+	if _, state, err = newEmailOTP(state, now); err != nil {
+		t.Fatalf("Error generating otp: %v", err)
+	}
+	if state.Attempts != maxOTPAttempts {
+		t.Errorf("Expected the failed attempts to be kept within the window, got %d", state.Attempts)
+	}
+
+	if _, state, err = newEmailOTP(state, state.ResendWindowStart.Add(otpResendWindow)); err != nil {
+		t.Fatalf("Error generating otp: %v", err)
+	}
+	if state.Attempts != 0 {
+		t.Errorf("Expected the failed attempts to be forgotten with the window, got %d", state.Attempts)
+	}
+}
+
+func TestRandomStringIsUniform(t *testing.T) {
+	value, err := randomString(30000, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, char := range "abc" {
+		if count := strings.Count(value, string(char)); count < 9500 || count > 10500 {
+			t.Errorf("Expected %c about 10000 times, got %d", char, count)
+		}
+	}
+	if strings.Trim(value, "abc") != "" {
+		t.Errorf("Expected only the given characters, got %q", strings.Trim(value, "abc"))
+	}
+}
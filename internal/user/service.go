@@ -1,20 +1,71 @@
 package user
 
-import "crypto/rand"
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"net/url"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/logging"
+	"time"
+)
 
-const otpChars = "1234567890"
+const (
+	otpChars = "1234567890"
+
+	passwordResetTTL     = 30 * time.Minute
+	passwordResetTimeout = 30 * time.Second
+)
 
 func GenerateOTP(length int) (string, error) {
+	return randomString(length, otpChars)
+}
+
+// randomString picks every character uniformly from chars. Taking random bytes modulo len(chars) would make
+// the first characters likelier, unless len(chars) divides 256.
+func randomString(length int, chars string) (string, error) {
 	buffer := make([]byte, length)
-	_, err := rand.Read(buffer)
+	max := big.NewInt(int64(len(chars)))
+	for i := range buffer {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buffer[i] = chars[index.Int64()]
+	}
+	return string(buffer), nil
+}
+
+func (uc *UserController) sendPasswordReset(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(ctx, passwordResetTimeout)
+	defer cancel()
+	logger := logging.FromContext(ctx)
+
+	user, err := uc.userStore.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", err
+		if !database.IsNotFound(err) {
+			logger.ErrorContext(ctx, "Cannot look up user for password reset", "error", err)
+		}
+		return
 	}
 
-	otpCharsLength := len(otpChars)
-	for i := 0; i < length; i++ {
-		buffer[i] = otpChars[int(buffer[i])%otpCharsLength]
+	token, err := auth.GenerateSecureToken()
+	if err != nil {
+		logger.ErrorContext(ctx, "Cannot generate password reset token", "error", err)
+		return
 	}
 
-	return string(buffer), nil
+	err = uc.userStore.SetPasswordResetToken(ctx, user.ID, auth.HashToken(token), time.Now().Add(passwordResetTTL))
+	if err != nil {
+		logger.ErrorContext(ctx, "Cannot store password reset token", "error", err)
+		return
+	}
+
+	// failures are logged by the email client
+	_ = uc.emailClient.SendPasswordResetMail(ctx, user.Email, uc.passwordResetLink(token))
+}
+
+func (uc *UserController) passwordResetLink(token string) string {
+	return uc.appURL + "/reset-password?token=" + url.QueryEscape(token)
 }
@@ -0,0 +1,287 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"resume-service/internal/apperror"
+	"resume-service/internal/auth"
+	"resume-service/internal/database"
+	"resume-service/internal/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	totpIssuer          = "InterviewGrab"
+	recoveryCodeCount   = 10
+	recoveryCodeLength  = 10
+	recoveryCodeChars   = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeDivider = "-"
+)
+
+var (
+	errTwoFactorInvalid        = apperror.New(http.StatusUnauthorized, "two_factor_invalid", "Invalid two factor code")
+	errTwoFactorEnabled        = apperror.New(http.StatusConflict, "two_factor_enabled", "Two factor authentication is already enabled")
+	errTwoFactorNotEnabled     = apperror.New(http.StatusBadRequest, "two_factor_not_enabled", "Two factor authentication is not enabled")
+	errTwoFactorNotEnrolling   = apperror.New(http.StatusBadRequest, "two_factor_not_enrolling", "Start two factor enrolment first")
+	errTwoFactorSecretReplaced = apperror.New(http.StatusConflict, "two_factor_secret_replaced", "Two factor enrolment was restarted, scan the new code")
+)
+
+// loginResponse issues tokens for the user, unless they use two factor authentication. In that case the
+// response is a challenge token, which LoginTwoFactor exchanges for tokens together with a code.
+func loginResponse(c *gin.Context, sessionStore database.SessionRepository, user model.User, status int) {
+	if user.Disabled {
+		apperror.Abort(c, auth.ErrAccountDisabled)
+		return
+	}
+
+	if user.TwoFactor.Enabled {
+		challenge, err := auth.GenerateChallengeToken(user.ID)
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		c.JSON(status, gin.H{"two_factor_required": true, "challenge_token": challenge})
+		return
+	}
+
+	tokens, err := auth.IssueTokens(c, sessionStore, user.ID)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(status, tokens)
+}
+
+func (uc *UserController) LoginTwoFactor(c *gin.Context) {
+	var request struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	userId, err := auth.ParseChallengeToken(request.ChallengeToken)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	user, err := uc.userStore.GetUser(c, userId)
+	if err != nil || user.Disabled {
+		apperror.Abort(c, auth.ErrInvalidChallengeToken)
+		return
+	}
+
+	lockKey := "2fa:" + user.ID.Hex()
+	if uc.isLockedOut(c, lockKey) {
+		return
+	}
+
+	if err = uc.verifySecondFactor(c, user, request.Code); err != nil {
+		if !errors.Is(err, errTwoFactorInvalid) || !uc.registerFailure(c, lockKey) {
+			apperror.Abort(c, err)
+		}
+		return
+	}
+
+	_ = uc.lockout.Succeed(c, lockKey)
+	tokens, err := auth.IssueTokens(c, uc.sessionStore, user.ID)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// EnrollTwoFactor creates a new secret, which is only enabled once ConfirmTwoFactor receives a code for it.
+func (uc *UserController) EnrollTwoFactor(c *gin.Context) {
+	user, err := uc.userStore.GetUser(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if user.TwoFactor.Enabled {
+		apperror.Abort(c, errTwoFactorEnabled)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.userStore.SetPendingTwoFactor(c, user.ID, secret)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two factor authentication and returns the recovery codes, the only time they are shown.
+func (uc *UserController) ConfirmTwoFactor(c *gin.Context) {
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	user, err := uc.userStore.GetUser(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	if user.TwoFactor.Enabled {
+		apperror.Abort(c, errTwoFactorEnabled)
+		return
+	}
+	if user.TwoFactor.PendingSecret == "" {
+		apperror.Abort(c, errTwoFactorNotEnrolling)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(user.TwoFactor.PendingSecret, request.Code, time.Now())
+	if !ok {
+		apperror.Abort(c, errTwoFactorInvalid)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.userStore.EnableTwoFactor(c, user.ID, user.TwoFactor.PendingSecret, hashes, step)
+	if err != nil {
+		if database.IsNotFound(err) {
+			apperror.Abort(c, errTwoFactorSecretReplaced)
+			return
+		}
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"two_factor_enabled": true, "recovery_codes": codes})
+}
+
+func (uc *UserController) DisableTwoFactor(c *gin.Context) {
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	user, err := uc.userStore.GetUser(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	if err = uc.verifySecondFactor(c, user, request.Code); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	err = uc.userStore.DisableTwoFactor(c, user.ID)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"two_factor_enabled": false})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, for users who used up or lost theirs.
+func (uc *UserController) RegenerateRecoveryCodes(c *gin.Context) {
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Abort(c, apperror.InvalidRequest(err))
+		return
+	}
+
+	user, err := uc.userStore.GetUser(c, auth.GetUserIdFromContext(c))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	if err = uc.verifySecondFactor(c, user, request.Code); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+
+	err = uc.userStore.SetRecoveryCodes(c, user.ID, hashes)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// verifySecondFactor accepts either a TOTP code or one of the user's unused recovery codes.
+func (uc *UserController) verifySecondFactor(ctx context.Context, user model.User, code string) error {
+	if !user.TwoFactor.Enabled {
+		return errTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := auth.ValidateTOTP(user.TwoFactor.Secret, code, time.Now()); ok {
+		err := uc.userStore.UseTOTPStep(ctx, user.ID, step)
+		if database.IsNotFound(err) {
+			return errTwoFactorInvalid
+		}
+		return err
+	}
+
+	err := uc.userStore.UseRecoveryCode(ctx, user.ID, auth.HashToken(normalizeRecoveryCode(code)))
+	if database.IsNotFound(err) {
+		return errTwoFactorInvalid
+	}
+	return err
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		chars, err := randomString(recoveryCodeLength, recoveryCodeChars)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := chars[:recoveryCodeLength/2] + recoveryCodeDivider + chars[recoveryCodeLength/2:]
+		codes = append(codes, code)
+		hashes = append(hashes, auth.HashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, recoveryCodeDivider, ""))
+}
@@ -2,127 +2,334 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"resume-service/internal/admin"
+	"resume-service/internal/apperror"
 	"resume-service/internal/auth"
+	"resume-service/internal/background"
 	"resume-service/internal/clients/email"
 	"resume-service/internal/clients/filestore"
 	"resume-service/internal/clients/mlclient"
-	"resume-service/internal/clients/parameters"
+	"resume-service/internal/clients/oidc"
+	"resume-service/internal/config"
 	"resume-service/internal/database"
+	"resume-service/internal/health"
+	"resume-service/internal/humanverify"
+	"resume-service/internal/logging"
+	"resume-service/internal/metrics"
+	"resume-service/internal/ratelimit"
 	"resume-service/internal/resume"
+	"resume-service/internal/tracing"
 	"resume-service/internal/user"
-	"resume-service/internal/utils"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
-var service_params = []string{utils.KEY_MONGO_URI, utils.KEY_OPENAI_API_KEY, utils.KEY_SENDER_EMAIL, utils.KEY_SENDER_PASS}
+const readinessTimeout = 3 * time.Second
 
 func main() {
-	err := godotenv.Load(".keys")
-	if err != nil {
-		log.Println("Cannot load env file", err)
-	}
-	err = godotenv.Load(".env")
-	if err != nil {
-		log.Println("Cannot load env file", err)
-	}
+	// the level is only known once the config is loaded, which already logs
+	logLevel := new(slog.LevelVar)
+	logging.Setup(os.Stdout, logLevel)
 
-	paramClient, err := parameters.NewParamClient(os.Getenv("REGION"))
+	cfg, err := config.Load()
 	if err != nil {
-		log.Println("Cannot create param client", err)
-	}
-
-	for _, param := range service_params {
-		paramValue, err := paramClient.GetStringParam(param)
-		if err != nil {
-			log.Println("Cannot read param: ", err)
-		} else {
-			err = os.Setenv(param, paramValue)
-			if err != nil {
-				log.Println("Cannot set param: ", err)
-			}
-		}
+		fatal("Invalid config", err)
 	}
+	logLevel.Set(cfg.LogLevel)
+	slog.Info("Loaded config", "config", cfg)
+	auth.SetJWTSecret(cfg.JWTSecret.Value())
 
-	for _, param := range service_params {
-		if os.Getenv(param) == "" {
-			log.Fatalf("Param: %s not found", param)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrate(cfg, os.Args[2:]); err != nil {
+			fatal("Migration failed", err)
 		}
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	startupCtx, cancelStartup := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelStartup()
 
-	store, err := database.NewClient(ctx)
+	flushTraces, err := tracing.Setup(startupCtx, cfg.Tracing)
 	if err != nil {
-		log.Fatal("Cannot connect to DB", err)
+		fatal("Cannot set up tracing", err)
 	}
-	defer func() { _ = store.Disconnect(ctx) }()
 
-	fileStore := filestore.NewStorageClient()
-	mlClient := mlclient.NewMLClient()
+	store, err := database.NewClient(startupCtx, cfg.Mongo)
+	if err != nil {
+		fatal("Cannot connect to DB", err)
+	}
+	// has its own deadline, it may wait for another instance to finish migrating
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancelMigrate()
+	if err = migrateOnStartup(migrateCtx, store, cfg.Mongo.MigrateOnStartup); err != nil {
+		fatal("Cannot start with this DB schema", err)
+	}
+
+	admin.BootstrapAdmins(startupCtx, &store.User, cfg.AdminEmails)
+
+	fileStore := filestore.NewStorageClient(cfg.Storage)
+	mlClient := mlclient.NewMLClient(cfg.OpenAI)
 	if mlClient == nil {
-		log.Fatal("Cannot create ML client")
+		fatal("Cannot create ML client", errors.New("no OpenAI api key"))
 	}
 
-	mailClient, err := email.NewClient()
+	mailClient, err := email.NewClient(cfg.Email)
 	if mailClient == nil {
-		log.Fatal("Cannot create mail client", err)
+		fatal("Cannot create mail client", err)
 	}
 
+	oauthProviders, err := oidc.NewProviders(cfg.OAuth)
+	if err != nil {
+		fatal("Cannot configure oauth providers", err)
+	}
+
+	jobs := background.NewJobs()
+	checker := health.NewChecker(readinessTimeout,
+		health.Check{Name: "database", Check: store.Ping},
+		health.Check{Name: "storage", Check: fileStore.Ping, CacheFor: 30 * time.Second},
+		// logging in to the mail server on every probe could get the account throttled
+		health.Check{Name: "email", Check: mailClient.Ping, CacheFor: time.Minute},
+	)
+
 	// Initialize Gin
-	r := gin.Default()
+	r := gin.New()
+	// handlers pass the gin context on as their context, it has to reach the request's logger
+	r.ContextWithFallback = true
+	r.Use(logging.Middleware(), tracing.Middleware(), metrics.Middleware(), apperror.Recovery(), apperror.Middleware())
+	r.NoRoute(apperror.NoRoute)
+	// the network load balancer passes the client address through, so forwarded headers are only client input
+	// and must not be trusted (rate limits are keyed by client ip)
+	if err = r.SetTrustedProxies(nil); err != nil {
+		fatal("Cannot set trusted proxies", err)
+	}
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"POST", "GET", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"POST", "GET", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Human-Verification", logging.RequestIDHeader, "traceparent", "tracestate"},
+		ExposeHeaders:    []string{"Content-Length", logging.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Rate limits are kept per instance, unless the mongo store shares them
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == config.RateLimitStoreMongo {
+		limitStore = &store.RateLimit
+	}
+	loginLockout := &ratelimit.Lockout{Store: limitStore, Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: 24 * time.Hour}
+
+	signupLimit := rateLimit(cfg.RateLimit, limitStore, "signup", ratelimit.Limit{Requests: 5, Per: time.Hour}, ratelimit.ByIP)
+	loginLimit := rateLimit(cfg.RateLimit, limitStore, "login", ratelimit.Limit{Requests: 10, Per: time.Minute}, ratelimit.ByIP, ratelimit.ByEmail)
+	passwordLimit := rateLimit(cfg.RateLimit, limitStore, "password", ratelimit.Limit{Requests: 5, Per: time.Hour}, ratelimit.ByIP, ratelimit.ByEmail)
+	tokenLimit := rateLimit(cfg.RateLimit, limitStore, "token", ratelimit.Limit{Requests: 30, Per: time.Minute}, ratelimit.ByIP)
+	verifyEmailLimit := rateLimit(cfg.RateLimit, limitStore, "verify_email", ratelimit.Limit{Requests: 10, Per: time.Hour}, ratelimit.ByIP, ratelimit.ByUser)
+	publicUploadLimit := rateLimit(cfg.RateLimit, limitStore, "public_upload", ratelimit.Limit{Requests: 10, Per: time.Hour}, ratelimit.ByIP)
+	shareLimit := rateLimit(cfg.RateLimit, limitStore, "share", ratelimit.Limit{Requests: 30, Per: time.Minute}, ratelimit.ByIP)
+	publicGenerationLimit := rateLimit(cfg.RateLimit, limitStore, "public_generation", ratelimit.Limit{Requests: 5, Per: time.Hour}, ratelimit.ByIP)
+
+	humanVerifier, err := humanverify.New(cfg.HumanVerification, limitStore)
+	if err != nil {
+		fatal("Cannot configure human verification", err)
+	}
+	humanCheck := humanverify.Middleware(humanVerifier)
+	signupChecks := []gin.HandlerFunc{signupLimit}
+	if cfg.HumanVerification.OnSignup {
+		signupChecks = append(signupChecks, humanCheck)
+	}
+
 	// Initialize controllers
-	userController := user.NewUserController(&store.User, mailClient)
-	resumeController := resume.NewResumeController(fileStore, &store.Resume, mlClient)
+	userController := user.NewUserController(&store.User, &store.Session, mailClient, loginLockout, jobs, cfg.AppURL)
+	oauthController := user.NewOAuthController(&store.User, &store.Session, &store.OAuthState, mailClient, oauthProviders)
+	apiKeyController := user.NewAPIKeyController(&store.APIKey)
+	resumeAnalytics := resume.Analytics{ViewerHashKey: []byte(cfg.ViewerHashKey.Value()), Users: &store.User, Mailer: mailClient, Jobs: jobs}
+	resumeController := resume.NewResumeController(fileStore, &store.Resume, mlClient, cfg.ResumeVersionsKept, resumeAnalytics)
+	accountController := user.NewAccountController(&store.User, &store.Resume, &store.Session, &store.APIKey, &store.Export, fileStore, jobs)
+	adminController := admin.NewAdminController(&store.User, &store.Resume, &store.Session, &store.APIKey, &store.Audit)
 
 	// Set up routes
+	r.GET("/healthz", checker.Live)
+	r.GET("/readyz", checker.Ready)
+
 	userPublicRoutes := r.Group("/api")
 	{
-		userPublicRoutes.POST("/signup", userController.Signup)
-		userPublicRoutes.POST("/login", userController.Login)
+		userPublicRoutes.POST("/signup", append(signupChecks, userController.Signup)...)
+		userPublicRoutes.POST("/login", loginLimit, userController.Login)
+		userPublicRoutes.POST("/login/2fa", loginLimit, userController.LoginTwoFactor)
+		userPublicRoutes.POST("/token/refresh", tokenLimit, userController.RefreshToken)
+		userPublicRoutes.POST("/password/forgot", passwordLimit, userController.ForgotPassword)
+		userPublicRoutes.POST("/password/reset", passwordLimit, userController.ResetPassword)
+		userPublicRoutes.GET("/oauth/:provider/start", oauthController.Start)
+		userPublicRoutes.POST("/oauth/:provider/callback", oauthController.Callback)
 	}
 
-	userAuthedRoutes := r.Group("/api", auth.Middleware())
+	userAuthedRoutes := r.Group("/api", auth.Middleware(&store.User, &store.Session, &store.APIKey), auth.SessionOnly())
 	{
 		userAuthedRoutes.POST("/logout", userController.Logout)
-		userAuthedRoutes.POST("/verify-email", userController.VerifyEmail)
+		userAuthedRoutes.POST("/logout-all", userController.LogoutAll)
+		userAuthedRoutes.POST("/verify-email", verifyEmailLimit, userController.VerifyEmail)
 		userAuthedRoutes.GET("/resend-otp", userController.ResendOTP)
+		userAuthedRoutes.POST("/2fa/enroll", userController.EnrollTwoFactor)
+		userAuthedRoutes.POST("/2fa/confirm", userController.ConfirmTwoFactor)
+		userAuthedRoutes.POST("/2fa/disable", userController.DisableTwoFactor)
+		userAuthedRoutes.POST("/2fa/recovery-codes", userController.RegenerateRecoveryCodes)
+		userAuthedRoutes.GET("/me", userController.GetMe)
+		userAuthedRoutes.PATCH("/me", userController.UpdateMe)
+		userAuthedRoutes.POST("/me/password", userController.ChangePassword)
+		userAuthedRoutes.POST("/me/email", userController.RequestEmailChange)
+		userAuthedRoutes.POST("/me/email/verify", userController.ConfirmEmailChange)
+		userAuthedRoutes.DELETE("/me", accountController.DeleteMe)
+		userAuthedRoutes.POST("/me/cancel-deletion", accountController.CancelDeletion)
+		userAuthedRoutes.POST("/me/export", accountController.StartExport)
+		userAuthedRoutes.GET("/me/export", accountController.GetExport)
+		userAuthedRoutes.POST("/api-keys", apiKeyController.CreateAPIKey)
+		userAuthedRoutes.GET("/api-keys", apiKeyController.ListAPIKeys)
+		userAuthedRoutes.DELETE("/api-keys/:key_id", apiKeyController.RevokeAPIKey)
+	}
+
+	resumeAuthedRoutes := r.Group("/api", auth.Middleware(&store.User, &store.Session, &store.APIKey), auth.EmailVerified(&store.User))
+
+	resumeReadRoutes := resumeAuthedRoutes.Group("", auth.RequireScope(auth.ScopeRead))
+	{
+		resumeReadRoutes.GET("/list-resumes", resumeController.ListResumes)
+		resumeReadRoutes.GET("/download-resume/:resume_id", resumeController.DownloadResume)
+		resumeReadRoutes.GET("/tags", resumeController.ListTags)
+		resumeReadRoutes.GET("/resumes/:resume_id/versions", resumeController.ListResumeVersions)
+		resumeReadRoutes.GET("/resumes/:resume_id/versions/:version/file", resumeController.DownloadResumeVersion)
+		resumeReadRoutes.GET("/resumes/:resume_id/diff", resumeController.DiffResumes)
+		resumeReadRoutes.GET("/share-links", resumeController.ListShareLinks)
+		resumeReadRoutes.GET("/resumes/:resume_id/analytics", resumeController.ResumeAnalytics)
+	}
+
+	resumeWriteRoutes := resumeAuthedRoutes.Group("", auth.RequireScope(auth.ScopeResumes))
+	{
+		resumeWriteRoutes.PUT("/upload-resume", resumeController.UploadResume)
+		resumeWriteRoutes.DELETE("/delete-resume/:resume_id", resumeController.DeleteResume)
+		resumeWriteRoutes.POST("/update-resume-visibility/:resume_id", resumeController.UpdateResumeVisibility)
+		resumeWriteRoutes.PATCH("/resumes/:resume_id", resumeController.UpdateResume)
+		resumeWriteRoutes.PUT("/resumes/:resume_id/file", resumeController.UploadResumeVersion)
+		resumeWriteRoutes.POST("/resumes/:resume_id/versions/:version/restore", resumeController.RestoreResumeVersion)
+		resumeWriteRoutes.DELETE("/resumes/:resume_id/versions", resumeController.PruneResumeVersions)
+		resumeWriteRoutes.PUT("/tags/:tag", resumeController.RenameTag)
+		resumeWriteRoutes.DELETE("/tags/:tag", resumeController.DeleteTag)
+		resumeWriteRoutes.POST("/resumes/:resume_id/share-links", resumeController.CreateShareLink)
+		resumeWriteRoutes.DELETE("/share-links/:link_id", resumeController.RevokeShareLink)
 	}
 
-	resumeAuthedRoutes := r.Group("/api", auth.Middleware(), auth.EmailVerified(&store.User))
+	generationRoutes := resumeAuthedRoutes.Group("", auth.RequireScope(auth.ScopeGeneration))
 	{
-		resumeAuthedRoutes.PUT("/upload-resume", resumeController.UploadResume)
-		resumeAuthedRoutes.GET("/list-resumes", resumeController.ListResumes)
-		resumeAuthedRoutes.GET("/download-resume/:resume_id", resumeController.DownloadResume)
-		resumeAuthedRoutes.DELETE("/delete-resume/:resume_id", resumeController.DeleteResume)
-		resumeAuthedRoutes.POST("/update-resume-visibility/:resume_id", resumeController.UpdateResumeVisibility)
-		resumeAuthedRoutes.POST("/generate-cover-letter", resumeController.GenerateCoverletter)
+		generationRoutes.POST("/generate-cover-letter", resumeController.GenerateCoverletter)
 	}
 
+	r.GET("/api/human-verification/challenge", humanverify.ChallengeHandler(humanVerifier))
+
+	// the limits come before the human check, so checking proofs and captchas is rate limited too
 	resumePublicRoutes := r.Group("/api")
 	{
-		resumePublicRoutes.PUT("/upload-resume-public", resumeController.UploadResumePublic)
-		resumePublicRoutes.POST("/generate-cover-letter-public", resumeController.GenerateCoverletterPublic)
+		resumePublicRoutes.PUT("/upload-resume-public", publicUploadLimit, humanCheck, resumeController.UploadResumePublic)
+		resumePublicRoutes.POST("/generate-cover-letter-public", publicGenerationLimit, humanCheck, resumeController.GenerateCoverletterPublic)
+	}
+
+	// share links are for people without an account, the token is all they need
+	sharedRoutes := r.Group("/api/share", shareLimit)
+	{
+		sharedRoutes.GET("/:token", resumeController.DownloadSharedResume)
+		sharedRoutes.POST("/:token", resumeController.DownloadSharedResume)
+	}
+
+	adminRoutes := r.Group("/api/admin", auth.Middleware(&store.User, &store.Session, &store.APIKey), auth.SessionOnly(), auth.EmailVerified(&store.User), admin.Audit(&store.Audit))
+	{
+		adminRoutes.GET("/users", auth.RequirePermission(&store.User, auth.PermissionReadUsers), adminController.ListUsers)
+		adminRoutes.GET("/users/:user_id", auth.RequirePermission(&store.User, auth.PermissionReadUsers), adminController.GetUser)
+		adminRoutes.GET("/users/:user_id/resumes", auth.RequirePermission(&store.User, auth.PermissionReadResumes), adminController.ListUserResumes)
+		adminRoutes.POST("/users/:user_id/verify-email", auth.RequirePermission(&store.User, auth.PermissionManageUsers), adminController.VerifyUserEmail)
+		adminRoutes.POST("/users/:user_id/disable", auth.RequirePermission(&store.User, auth.PermissionManageUsers), adminController.DisableUser)
+		adminRoutes.POST("/users/:user_id/enable", auth.RequirePermission(&store.User, auth.PermissionManageUsers), adminController.EnableUser)
+		adminRoutes.PUT("/users/:user_id/role", auth.RequirePermission(&store.User, auth.PermissionManageRoles), adminController.SetUserRole)
+		adminRoutes.GET("/usage", auth.RequirePermission(&store.User, auth.PermissionReadUsage), adminController.Usage)
+		adminRoutes.GET("/audit", auth.RequirePermission(&store.User, auth.PermissionReadAudit), adminController.ListAuditEvents)
 	}
 
+	jobs.Go("cleanup", func(ctx context.Context) { accountController.RunCleanup(ctx, jobs.Stopping()) })
+	jobs.Go("backfill resume text", func(ctx context.Context) { resumeController.BackfillContent(ctx, jobs.Stopping()) })
+
 	// Start server
-	err = r.Run("0.0.0.0:8080")
-	if err != nil {
-		log.Fatal("Error starting server", err)
+	if cfg.MetricsPort != 0 {
+		// left running until the process exits, so scrapes during shutdown still see the draining
+		go serveMetrics(cfg.MetricsPort)
+	}
+	server := &http.Server{Addr: fmt.Sprintf("0.0.0.0:%d", cfg.Port), Handler: r}
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.ListenAndServe() }()
+
+	stop, cancelStop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancelStop()
+	select {
+	case err = <-serverErr:
+		fatal("Error starting server", err)
+	case <-stop.Done():
+	}
+
+	shutdown(cfg.ShutdownDrainDelay, cfg.ShutdownTimeout, server, checker, jobs, store, flushTraces)
+}
+
+// shutdown fails /readyz and keeps serving for drainDelay, until the load balancer stops sending requests. Then it
+// stops taking requests and waits for in-flight requests and background jobs before closing the database and
+// flushing traces, all within timeout.
+func shutdown(drainDelay time.Duration, timeout time.Duration, server *http.Server, checker *health.Checker, jobs *background.Jobs, store *database.DB, flushTraces func(context.Context) error) {
+	slog.Info("Shutting down", "drain_delay", drainDelay)
+	checker.SetShuttingDown()
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Requests still running at shutdown", "error", err)
+	}
+	if err := jobs.Shutdown(ctx); err != nil {
+		slog.Warn("Background jobs still running at shutdown", "error", err)
 	}
+
+	// the database gets its own deadline, it should be closed even when draining used up the timeout
+	disconnectCtx, cancelDisconnect := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDisconnect()
+	if err := store.Disconnect(disconnectCtx); err != nil {
+		slog.Error("Cannot disconnect from DB", "error", err)
+	}
+	if err := flushTraces(disconnectCtx); err != nil {
+		slog.Error("Cannot flush traces", "error", err)
+	}
+	slog.Info("Shut down")
+}
+
+func serveMetrics(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", port), mux)
+	slog.Error("Metrics server stopped", "error", err)
+}
+
+// rateLimit builds the middleware for a limit, which the config can override by name (see ratelimit.ParseLimit).
+func rateLimit(limits config.RateLimit, store ratelimit.Store, name string, fallback ratelimit.Limit, keys ...ratelimit.KeyFunc) gin.HandlerFunc {
+	limit := fallback
+	if value, ok := limits.Limits[name]; ok {
+		var err error
+		if limit, err = ratelimit.ParseLimit(value); err != nil {
+			fatal("Cannot read rate limit "+name, err)
+		}
+	}
+	return ratelimit.Middleware(store, ratelimit.Rule{Name: name, Limit: limit, Keys: keys})
+}
+
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"resume-service/internal/config"
+	"resume-service/internal/database"
+	"resume-service/internal/database/migrate"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up [n]     apply the next n pending migrations, all of them without n
+  down [n]   roll back the last n applied migrations, 1 without n
+  status     list the migrations and when they were applied`
+
+// migrateTimeout leaves room to wait for another instance holding the migration lock.
+const migrateTimeout = 15 * time.Minute
+
+// runMigrate is the migrate command, for applying and rolling back migrations outside of a deployment.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+	steps := 0
+	if len(args) == 2 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+			return fmt.Errorf("n must be a positive number, got %q\n%s", args[1], migrateUsage)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+	store, err := database.NewClient(ctx, cfg.Mongo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Disconnect(context.Background()) }()
+	migrator, err := store.Migrator()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx, steps)
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(os.Stdout, statuses)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printStatus(w io.Writer, statuses []migrate.Status) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.Unknown {
+			applied += " (unknown to this build)"
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	return table.Flush()
+}
+
+// migrateOnStartup brings the schema up to date, or refuses to serve when it isn't and can't be.
+func migrateOnStartup(ctx context.Context, store *database.DB, enabled bool) error {
+	migrator, err := store.Migrator()
+	if err != nil {
+		return err
+	}
+	err = migrator.Check(ctx)
+	if !errors.Is(err, migrate.ErrPending) || !enabled {
+		return err
+	}
+	slog.Info("Applying pending migrations", "latest", migrator.Latest())
+	return migrator.Up(ctx, 0)
+}